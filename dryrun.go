@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/discovery"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exporter"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// priceColumns orders the well known price labels, anything else such as
+// target labels is printed after them.
+var priceColumns = []string{"url", "ship", "cruiseid", "itinerary", "datelabel", "departureday", "days", "departureport", "stateroomclass"}
+
+// dryRun scrapes every target once into a private registry and prints the
+// price series that would be exported, after filters and relabeling.
+func dryRun(w io.Writer, cfg *config.Config, features royalapi.Features) error {
+	reg := prometheus.NewRegistry()
+	opts := append(exporterOptions(cfg, features),
+		exporter.WithRegistry(reg),
+		exporter.WithServeMux(http.NewServeMux()),
+		// a dry run must not tell anyone about what it saw
+		exporter.WithNotifier(nil),
+		exporter.WithAlertmanager(nil, ""),
+	)
+	e, err := exporter.NewExporter(context.Background(), opts...)
+	if err != nil {
+		return err
+	}
+	for i, sd := range cfg.FileSDConfigs {
+		e.SetDiscoveredTargets(fmt.Sprintf("file_sd/%d", i), discovery.NewFileSD(sd, log.Default()).Targets())
+	}
+	e.ScrapeOnce()
+
+	families, err := reg.Gather()
+	if err != nil {
+		return err
+	}
+	var rows []map[string]string
+	seen := map[string]bool{}
+	for _, mf := range families {
+		if mf.GetName() != "royal_external_price" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			row := map[string]string{"price": fmt.Sprintf("%.0f", m.GetGauge().GetValue())}
+			for _, l := range m.GetLabel() {
+				row[l.GetName()] = l.GetValue()
+				seen[l.GetName()] = true
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	var columns []string
+	for _, c := range priceColumns {
+		if seen[c] {
+			columns = append(columns, c)
+			delete(seen, c)
+		}
+	}
+	var extra []string
+	for c := range seen {
+		extra = append(extra, c)
+	}
+	sort.Strings(extra)
+	columns = append(append(columns, extra...), "price")
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, c := range columns {
+			if rows[i][c] != rows[j][c] {
+				return rows[i][c] < rows[j][c]
+			}
+		}
+		return false
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, c := range columns {
+			values[i] = row[c]
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d price series would be exported\n", len(rows))
+	return nil
+}
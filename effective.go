@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"gopkg.in/yaml.v2"
+)
+
+// secretFlags are flags whose value is never printed.
+var secretFlags = map[string]bool{"debug-token": true}
+
+// loadedConfig is the config file as last loaded, served on /-/config.
+var loadedConfig atomic.Value
+
+// flagValues returns every flag with its value, secrets redacted.
+func flagValues(fs *flag.FlagSet) map[string]string {
+	values := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		v := f.Value.String()
+		if secretFlags[f.Name] && v != "" {
+			v = config.Secret(v).String()
+		}
+		values[f.Name] = v
+	})
+	return values
+}
+
+// writeEffectiveConfig prints the flags and the config file as loaded, with
+// defaults applied and secrets redacted.
+func writeEffectiveConfig(w io.Writer, cfg *config.Config) error {
+	b, err := yaml.Marshal(struct {
+		Flags  map[string]string `yaml:"flags"`
+		Config *config.Config    `yaml:"config"`
+	}{flagValues(flag.CommandLine), cfg})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func serveEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	cfg, _ := loadedConfig.Load().(*config.Config)
+	w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
+	if err := writeEffectiveConfig(w, cfg); err != nil {
+		log.Printf("error writing effective config: %s\n", err)
+	}
+}
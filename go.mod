@@ -1,7 +1,10 @@
 module github.com/invertedorigin/royalcaribbean-prometheus-exporter
+
 go 1.16
 
 require (
 	github.com/prometheus/client_golang v1.12.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/stretchr/testify v1.7.1
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// listenAddress is where the exporter serves its metrics.
+const listenAddress = ":2112"
+
+// healthcheck asks the exporter running on this host whether it is ready,
+// for container health checks in images without curl.
+func healthcheck() error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://localhost" + listenAddress + "/readyz")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
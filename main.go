@@ -2,29 +2,78 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/discovery"
 	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exporter"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/leader"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/redis"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/systemd"
 )
 
 type urlArrayFlags []string
 
+// durationListFlags is a comma separated list of durations.
+type durationListFlags []time.Duration
+
 var (
+	config_file          string
 	healthcheck_interval time.Duration
+	scrape_budget        time.Duration
+	max_backoff          time.Duration
+	cache_ttl            time.Duration
+	page_concurrency     int
+	page_size            int
+	sort_by              string
+	request_budget       int
 	urls                 urlArrayFlags
+	filters              string
+	query_features       string
+	persisted_queries    bool
+	open_metrics         bool
+	sample_timestamps    bool
+	debug_token          string
+	debug_token_file     string
+	debug_responses      int
+	max_series           int
+	series_limit_action  string
+	price_taxes          string
+	log_mode             string
+	log_throttle         time.Duration
+	series_ttl           int
+	success_windows      = durationListFlags{time.Hour, 24 * time.Hour}
+	cabin_guests         int
+	date_label_format    string
+	date_label_timezone  string
+	validate             bool
+	dry_run              bool
+	print_config         bool
+	gc_percent           int
+	memory_limit         string
+	use_systemd          bool
+	shutdown_grace       time.Duration
 )
 
 func getConfig(fs *flag.FlagSet) []string {
+	values := flagValues(fs)
 	cfg := make([]string, 0, 10)
 	fs.VisitAll(func(f *flag.Flag) {
-		cfg = append(cfg, fmt.Sprintf("%s:%q", f.Name, f.Value.String()))
+		cfg = append(cfg, fmt.Sprintf("%s:%q", f.Name, values[f.Name]))
 	})
 	return cfg
 }
@@ -38,36 +87,550 @@ func (i *urlArrayFlags) Set(value string) error {
 	return nil
 }
 
+func (d *durationListFlags) String() string {
+	s := make([]string, 0, len(*d))
+	for _, w := range *d {
+		s = append(s, w.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (d *durationListFlags) Set(value string) error {
+	var windows durationListFlags
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		w, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		windows = append(windows, w)
+	}
+	*d = windows
+	return nil
+}
+
 func init() {
+	flag.StringVar(
+		&config_file,
+		"config",
+		"",
+		"Path to an optional YAML config file, reloaded when it changes",
+	)
+	flag.BoolVar(
+		&print_config,
+		"print-config",
+		false,
+		"Print the flags and config file as loaded, with defaults applied and secrets redacted, and exit. Also served on /-/config",
+	)
 	flag.DurationVar(
 		&healthcheck_interval,
 		"interval",
 		60*time.Second,
 		"Interval for the healthchecks",
 	)
+	flag.DurationVar(
+		&max_backoff,
+		"max-backoff",
+		15*time.Minute,
+		"Longest interval scrape cycles back off to while every target fails, doubling from -interval. 0 disables the backoff",
+	)
+	flag.DurationVar(
+		&scrape_budget,
+		"scrape-budget",
+		0,
+		"Maximum time a scrape of one target may take before pagination stops and the scrape is flagged partial, 0 for no limit",
+	)
+	flag.IntVar(
+		&request_budget,
+		"daily-request-budget",
+		0,
+		"Maximum number of requests sent to a target per day, scraping pauses until midnight once it is used up. 0 for no limit, targets in the config file may set their own",
+	)
+	flag.IntVar(
+		&page_size,
+		"page-size",
+		100,
+		"Maximum number of cruises requested per page, lowered per target down to 20 when a target rejects or truncates larger pages",
+	)
+	flag.StringVar(
+		&sort_by,
+		"sort",
+		royalapi.SortRecommended,
+		"Order of the search results, one of "+strings.Join(royalapi.Sorts, ", ")+". RECOMMENDED reshuffles between pages, DATE and PRICE paginate deterministically. Targets in the config file may set their own",
+	)
+	flag.IntVar(
+		&page_concurrency,
+		"page-concurrency",
+		1,
+		"Number of pages of a target fetched at the same time after the first one",
+	)
+	flag.DurationVar(
+		&cache_ttl,
+		"cache-ttl",
+		0,
+		"How long parsed pages are reused instead of asking the API again, 0 to disable the cache",
+	)
 	flag.Var(
 		&urls,
 		"url",
-		"URLs to perform health checks against. Can be included multiple times for additonal URLs",
+		"URLs to perform health checks against. Can be included multiple times for additonal URLs. Named targets with labels can be added in the config file",
+	)
+	flag.StringVar(
+		&filters,
+		"filters",
+		"",
+		"cruiseSearch filters to narrow the scraped catalog, e.g. ship:WN",
+	)
+	flag.StringVar(
+		&query_features,
+		"query-features",
+		"all",
+		"Comma separated optional query fields to request: media, ports, tours, staterooms, lowest-price, or all",
+	)
+	flag.BoolVar(
+		&persisted_queries,
+		"persisted-queries",
+		false,
+		"Send the query as a persisted query hash, falling back to the full query when the server doesn't know it",
+	)
+	flag.BoolVar(
+		&open_metrics,
+		"openmetrics",
+		true,
+		"Serve /metrics as OpenMetrics to scrapers asking for it, with the scrape and request IDs of slow requests as exemplars",
+	)
+	flag.BoolVar(
+		&sample_timestamps,
+		"sample-timestamps",
+		false,
+		"Export the prices with the time they were fetched, so the TSDB records when they were observed rather than scraped",
+	)
+	flag.StringVar(
+		&debug_token,
+		"debug-token",
+		"",
+		"Bearer token required by /debug/last-response. The endpoint is disabled when empty",
+	)
+	flag.StringVar(
+		&debug_token_file,
+		"debug-token-file",
+		"",
+		"File to read the /debug/last-response token from instead of -debug-token, reloaded when it changes",
+	)
+	flag.IntVar(
+		&debug_responses,
+		"debug-responses",
+		5,
+		"Number of raw responses to keep per target for /debug/last-response",
+	)
+	flag.IntVar(
+		&max_series,
+		"max-series",
+		0,
+		"Maximum number of series per metric, 0 for unlimited",
+	)
+	flag.StringVar(
+		&series_limit_action,
+		"series-limit-action",
+		exporter.SeriesLimitDrop,
+		"What to do when a metric reaches -max-series: drop the series set the longest ago or refuse the scrape",
+	)
+	flag.IntVar(
+		&series_ttl,
+		"series-ttl",
+		0,
+		"Number of scrapes after which series that weren't updated are removed, even if their target isn't scraped anymore. Scrapes skipped outside the scrape window or while backing off don't count. 0 keeps them until the target is removed",
+	)
+	flag.Var(
+		&success_windows,
+		"success-windows",
+		"Comma separated windows over which the share of successful scrapes of every target is exported, empty to disable",
+	)
+	flag.StringVar(
+		&price_taxes,
+		"price-taxes",
+		exporter.TaxesAsQuoted,
+		"Export prices as-quoted by each market, or normalized to base prices without taxes and fees or to total prices including them",
+	)
+	flag.StringVar(
+		&log_mode,
+		"log-mode",
+		exporter.LogModeFull,
+		"Log the progress of every scrape, or delta to only log changed prices and new or removed sailings",
+	)
+	flag.DurationVar(
+		&log_throttle,
+		"log-throttle",
+		0,
+		"Interval within which identical log messages are logged once, with the number held back, for example 1m. 0 logs every message",
+	)
+	flag.IntVar(
+		&cabin_guests,
+		"cabin-guests",
+		0,
+		"Number of guests sharing a cabin for royal_external_cabin_total_price, the per person price times this count. 0 disables the metric",
+	)
+	flag.StringVar(
+		&date_label_format,
+		"date-label-format",
+		"",
+		"Go time layout of the datelabel label, e.g. 2006-01-02, so sail dates of every market look alike. Empty exports them as returned. month rollups need a layout starting with 2006-01",
+	)
+	flag.StringVar(
+		&date_label_timezone,
+		"date-label-timezone",
+		"UTC",
+		"Time zone sail dates are converted to before formatting, dates without one are taken to be in it",
+	)
+	flag.BoolVar(
+		&validate,
+		"validate",
+		false,
+		"Check the flags and config file, print every problem found and exit. Same as the check-config command",
+	)
+	flag.BoolVar(
+		&dry_run,
+		"dry-run",
+		false,
+		"Scrape every target once, print the prices that would be exported as a table and exit",
+	)
+	flag.DurationVar(
+		&shutdown_grace,
+		"shutdown-grace",
+		30*time.Second,
+		"How long to wait on shutdown for running scrapes, the history file and queued notifications",
+	)
+	flag.BoolVar(
+		&use_systemd,
+		"systemd",
+		false,
+		"Notify systemd once serving and ping its watchdog while scrapes don't hang, for units with Type=notify and WatchdogSec",
+	)
+	flag.IntVar(
+		&gc_percent,
+		"gc-percent",
+		0,
+		"Garbage collection target percentage like GOGC, lower trades CPU for memory on large catalogs. 0 keeps the default, -1 disables the collector",
+	)
+	flag.StringVar(
+		&memory_limit,
+		"memory-limit",
+		"",
+		"Soft memory limit like GOMEMLIMIT, for example 96MiB. The collector runs more often as the heap gets close to it",
 	)
 
 	flag.Parse()
 	log.Printf("app.config %v\n", getConfig(flag.CommandLine))
 }
 
+// exporterOptions turns the flags and config file into exporter options.
+func exporterOptions(cfg *config.Config, features royalapi.Features) []exporter.Option {
+	var sdLabelNames []string
+	for _, sd := range cfg.FileSDConfigs {
+		sdLabelNames = append(sdLabelNames, sd.LabelNames...)
+	}
+	opts := []exporter.Option{
+		exporter.WithInterval(healthcheck_interval),
+		exporter.WithScrapeBudget(scrape_budget),
+		exporter.WithMaxBackoff(max_backoff),
+		exporter.WithMaxPageSize(page_size),
+		exporter.WithRequestBudget(request_budget),
+		exporter.WithPageConcurrency(page_concurrency),
+		exporter.WithResponseCache(cache_ttl),
+		exporter.WithTargets(append(flagTargets(), cfg.Targets...)...),
+		exporter.WithStaticLabelNames(sdLabelNames...),
+		exporter.WithFilters(filters),
+		exporter.WithQueryFeatures(features),
+		exporter.WithSort(sort_by),
+		exporter.WithPersistedQueries(persisted_queries),
+		exporter.WithOpenMetrics(open_metrics),
+		exporter.WithSampleTimestamps(sample_timestamps),
+		exporter.WithRelabelConfigs(cfg.RelabelConfigs),
+		exporter.WithSeriesLimit(max_series, series_limit_action),
+		exporter.WithSeriesTTL(series_ttl),
+		exporter.WithSuccessWindows(success_windows...),
+		exporter.WithTaxes(price_taxes),
+		exporter.WithLogMode(log_mode),
+		exporter.WithLogThrottle(log_throttle),
+		exporter.WithCabinGuests(cabin_guests),
+		exporter.WithDateLabels(date_label_format, date_label_timezone),
+		exporter.WithDebug(debug_token, debug_responses),
+	}
+	if len(cfg.Notifiers) > 0 {
+		notifiers := make([]notify.Notifier, 0, len(cfg.Notifiers))
+		for _, n := range cfg.Notifiers {
+			// templates are validated by config.Load
+			templates, _ := n.Templates()
+			var notifier notify.Notifier = notify.NewWebhook(n.Name, string(n.WebhookURL)).WithTemplates(templates)
+			if len(n.Exec) > 0 {
+				notifier = notify.NewExec(n.Name, n.Exec).WithTemplates(templates)
+			}
+			if n.RateLimit != nil {
+				notifier = notify.RateLimit(notifier, n.RateLimit.Count, n.RateLimit.Interval)
+			}
+			notifiers = append(notifiers, notifier)
+		}
+		// names are validated by config.Load
+		dispatcher, _ := notify.NewDispatcher(log.Default(), notifiers...)
+		for _, n := range cfg.Notifiers {
+			p := notify.Policy{MinPriority: n.MinPriority}
+			if n.QuietHours != nil {
+				p.Quiet = n.QuietHours.Allows
+			}
+			dispatcher.SetPolicy(n.Name, p)
+		}
+		for _, r := range cfg.Routes {
+			if err := dispatcher.AddRoutes(notify.Route{Kinds: r.Kinds, Rules: r.Rules, MinPriority: r.MinPriority, Notify: r.Notify}); err != nil {
+				log.Fatalf("invalid routes: %s\n", err)
+			}
+		}
+		opts = append(opts, exporter.WithNotifier(dispatcher))
+	}
+	if cfg.History != nil {
+		// main replaces this with the persisted store, dry runs and checks
+		// must not touch the history file
+		opts = append(opts, exporter.WithHistory(history.NewStore(cfg.History.Retention, cfg.History.Resolution), ""))
+	}
+	if cfg.Anomaly != nil {
+		opts = append(opts, exporter.WithAnomalyDetection(*cfg.Anomaly))
+	}
+	if len(cfg.Operations) > 0 {
+		opts = append(opts, exporter.WithOperations(cfg.Operations...))
+	}
+	if len(cfg.DerivedMetrics) > 0 {
+		opts = append(opts, exporter.WithDerivedMetrics(cfg.DerivedMetrics...))
+	}
+	if cfg.SuperCategories != nil {
+		opts = append(opts, exporter.WithSuperCategories(*cfg.SuperCategories))
+	}
+	if cfg.PricingCalendar != nil {
+		opts = append(opts, exporter.WithPricingCalendar(*cfg.PricingCalendar))
+	}
+	if cfg.Trend != nil {
+		opts = append(opts, exporter.WithTrend(*cfg.Trend))
+	}
+	if cfg.ScrapeWindow != nil {
+		opts = append(opts, exporter.WithScrapeWindow(*cfg.ScrapeWindow))
+	}
+	if cfg.Rollups != nil {
+		opts = append(opts, exporter.WithRollups(*cfg.Rollups))
+	}
+	if cfg.Digest != nil {
+		opts = append(opts, exporter.WithDigest(*cfg.Digest))
+	}
+	if len(cfg.Users) > 0 {
+		opts = append(opts, exporter.WithUsers(cfg.Users...))
+	}
+	if cfg.ExportLegacyMetricNames() {
+		log.Printf("exporting the deprecated metric names too, which legacy_metric_names: false turns off and a future release will stop")
+		opts = append(opts, exporter.WithLegacyMetricNames(true))
+	}
+	opts = append(opts, exporter.WithWatches(cfg.Watches...), exporter.WithHolidays(cfg.Holidays...))
+	if cfg.WatchesFile != "" {
+		opts = append(opts, exporter.WithWatchesFile(cfg.WatchesFile))
+	}
+	if am := cfg.Alertmanager; am != nil {
+		opts = append(opts, exporter.WithAlertmanager(notify.NewAlertmanager(string(am.URL), am.Timeout), am.ExternalURL))
+	}
+	if cfg.ItineraryChanges != nil {
+		opts = append(opts, exporter.WithItineraryChangeNotifications(*cfg.ItineraryChanges))
+	}
+	if cfg.HTTPClient.Session != nil {
+		opts = append(opts, exporter.WithSession(*cfg.HTTPClient.Session))
+	}
+	if cfg.HTTPClient.DNSCache != nil {
+		opts = append(opts, exporter.WithDNSCache(cfg.HTTPClient.DNSCache.TTL))
+	}
+	if cfg.HTTPClient.SourceAddress != "" || cfg.HTTPClient.Interface != "" {
+		opts = append(opts, exporter.WithSource(cfg.HTTPClient.SourceAddress, cfg.HTTPClient.Interface))
+	}
+	if cfg.HTTPClient.ProxyURL != "" {
+		// validated by config.Load
+		proxy, _ := url.Parse(string(cfg.HTTPClient.ProxyURL))
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxy)
+		opts = append(opts, exporter.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+	return opts
+}
+
 func main() {
+	if flag.Arg(0) == "config-schema" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(config.Schema())
+		return
+	}
+	if flag.Arg(0) == "healthcheck" {
+		if err := healthcheck(); err != nil {
+			fmt.Fprintf(os.Stderr, "unhealthy: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "selftest" {
+		ok, err := selftest(os.Stdout)
+		if err != nil {
+			log.Fatalf("selftest failed: %s\n", err)
+		}
+		if !ok {
+			fmt.Println("selftest FAILED")
+			os.Exit(1)
+		}
+		fmt.Println("selftest OK")
+		return
+	}
+	if flag.Arg(0) == "generate-rules" {
+		cfg := &config.Config{}
+		if config_file != "" {
+			var err error
+			if cfg, err = config.Load(config_file); err != nil {
+				log.Fatalf("error loading config: %s\n", err)
+			}
+		}
+		watches := cfg.Watches
+		if cfg.WatchesFile != "" {
+			managed, err := config.LoadWatches(cfg.WatchesFile)
+			if err != nil {
+				log.Fatalf("error loading watches: %s\n", err)
+			}
+			watches = append(watches, managed...)
+		}
+		problems, err := writeRules(os.Stdout, cfg, watches)
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		if err != nil {
+			log.Fatalf("error writing rules: %s\n", err)
+		}
+		return
+	}
+	if validate || flag.Arg(0) == "check-config" {
+		problems := checkConfig()
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		fmt.Println("config OK")
+		return
+	}
+
+	if err := tuneMemory(); err != nil {
+		log.Fatalf("invalid -memory-limit: %s\n", err)
+	}
 
 	// Create context and http server for prom metrics
 	ctx, cancel := context.WithCancel(context.Background())
 
+	cfg := &config.Config{}
+	if config_file != "" {
+		var err error
+		if cfg, err = config.Load(config_file); err != nil {
+			log.Fatalf("error loading config: %s\n", err)
+		}
+	}
+
+	features, err := royalapi.ParseFeatures(query_features)
+	if err != nil {
+		log.Fatalf("invalid -query-features: %s\n", err)
+	}
+
+	if debug_token_file != "" {
+		if debug_token, err = readSecretFile(debug_token_file); err != nil {
+			log.Fatalf("error reading debug token: %s\n", err)
+		}
+	}
+
+	if print_config {
+		if err := writeEffectiveConfig(os.Stdout, cfg); err != nil {
+			log.Fatalf("error printing config: %s\n", err)
+		}
+		return
+	}
+	loadedConfig.Store(cfg)
+	http.HandleFunc("/-/config", serveEffectiveConfig)
+
+	if dry_run {
+		if err := dryRun(os.Stdout, cfg, features); err != nil {
+			log.Fatalf("dry run failed: %s\n", err)
+		}
+		return
+	}
+
+	opts := exporterOptions(cfg, features)
+	if cfg.History != nil {
+		store := history.NewStore(cfg.History.Retention, cfg.History.Resolution)
+		if cfg.History.File != "" {
+			if err := store.Load(cfg.History.File); err != nil {
+				log.Fatalf("error loading history: %s\n", err)
+			}
+		}
+		opts = append(opts, exporter.WithHistory(store, cfg.History.File))
+	}
+	if cfg.WatchStateFile != "" {
+		opts = append(opts, exporter.WithWatchState(cfg.WatchStateFile))
+	}
+	if cfg.AuditLogFile != "" {
+		opts = append(opts, exporter.WithAuditLog(cfg.AuditLogFile))
+	}
+	if cfg.Outbox != nil {
+		outbox, err := notify.LoadOutbox(cfg.Outbox.File, cfg.Outbox.MaxAge)
+		if err != nil {
+			log.Fatalf("error loading notification outbox: %s\n", err)
+		}
+		opts = append(opts, exporter.WithNotificationOutbox(outbox, cfg.Outbox.RetryInterval))
+	}
+
+	var client *redis.Client
+	if cfg.Redis != nil {
+		client = redis.NewClient(cfg.Redis.Address, string(cfg.Redis.Password), cfg.Redis.DB)
+		defer client.Close()
+		if cfg.Redis.ShareCatalog {
+			opts = append(opts, exporter.WithCatalogStore(client, cfg.Redis.KeyPrefix))
+		}
+	}
+
+	if le := cfg.LeaderElection; le != nil {
+		switch le.Backend {
+		case config.LeaderElectionRedis:
+			elector := leader.NewRedisLease(client, cfg.Redis.KeyPrefix+"leader", le.Identity, le.LeaseDuration, log.Default())
+			elector.Acquire(ctx)
+			go elector.Run(ctx)
+			opts = append(opts, exporter.WithLeaderElection(elector))
+		default:
+			elector := leader.NewFileLease(le.LeaseFile, le.Identity, le.LeaseDuration, log.Default())
+			elector.Acquire()
+			go elector.Run(ctx)
+			opts = append(opts, exporter.WithLeaderElection(elector))
+		}
+	}
+
 	// Start the collector
-	exporter := exporter.NewExporter(ctx, healthcheck_interval, urls)
+	exporter, err := exporter.NewExporter(ctx, opts...)
+	if err != nil {
+		log.Fatalf("error creating exporter: %s\n", err)
+	}
+	for i, sd := range cfg.FileSDConfigs {
+		source := fmt.Sprintf("file_sd/%d", i)
+		go discovery.NewFileSD(sd, log.Default()).Run(ctx, func(targets []config.Target) {
+			exporter.SetDiscoveredTargets(source, targets)
+		})
+	}
+	go watchConfig(ctx, exporter, cfg)
 	exporter.StartCollector()
 
 	// start the http server
-	server := &http.Server{Addr: ":2112", Handler: nil}
+	server := &http.Server{Addr: listenAddress, Handler: nil}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("http server failed to listen: %s\n", err)
+	}
 	go func() {
-		err := server.ListenAndServe()
+		err := server.Serve(listener)
 		if err == http.ErrServerClosed {
 			log.Printf("http server shutdown/closed: %s\n", err)
 		} else if err != nil {
@@ -77,18 +640,26 @@ func main() {
 		}
 	}()
 
-	// Signal to safely shutdown for interrupts and force quit for SIGTERM
+	notifySystemd(systemd.Ready)
+	go runWatchdog(ctx, exporter)
+
+	// Shut down gracefully on the first signal and force quit on the second
 	signalChannel := make(chan os.Signal, 2)
 	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
 	sig := <-signalChannel
-	switch sig {
-	case os.Interrupt:
-		log.Println("received interrupt, shutting down.")
-		cancel()
-		server.Shutdown(context.Background())
-	case syscall.SIGTERM:
-		log.Println("received sigterm, force quitting.")
+	notifySystemd(systemd.Stopping)
+	log.Printf("received %s, shutting down within %s", sig, shutdown_grace)
+	go func() {
+		sig := <-signalChannel
+		log.Printf("received %s again, force quitting.", sig)
 		os.Exit(1)
+	}()
+	cancel()
+	shutdownCtx, stop := context.WithTimeout(context.Background(), shutdown_grace)
+	defer stop()
+	if err := exporter.Shutdown(shutdownCtx); err != nil {
+		log.Printf("error shutting down: %s", err)
 	}
+	server.Shutdown(shutdownCtx)
 
 }
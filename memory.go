@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// byteUnits are the suffixes -memory-limit accepts, as in GOMEMLIMIT.
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TiB", 1 << 40},
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"B", 1},
+}
+
+// parseBytes parses a size such as 96MiB, a plain number being bytes.
+func parseBytes(s string) (int64, error) {
+	num, size := strings.TrimSpace(s), int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num, size = strings.TrimSuffix(num, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, use a number of bytes with an optional KiB, MiB, GiB or TiB suffix", s)
+	}
+	return n * size, nil
+}
+
+// tuneMemory applies -gc-percent and -memory-limit. Both leave the runtime
+// defaults, including GOGC and GOMEMLIMIT from the environment, when unset.
+func tuneMemory() error {
+	if gc_percent != 0 {
+		log.Printf("setting GC percent to %d (was %d)", gc_percent, debug.SetGCPercent(gc_percent))
+	}
+	if memory_limit == "" {
+		return nil
+	}
+	limit, err := parseBytes(memory_limit)
+	if err != nil {
+		return err
+	}
+	return setMemoryLimit(limit)
+}
@@ -0,0 +1,14 @@
+//go:build go1.19
+// +build go1.19
+
+package main
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+func setMemoryLimit(limit int64) error {
+	log.Printf("setting memory limit to %d bytes (was %d)", limit, debug.SetMemoryLimit(limit))
+	return nil
+}
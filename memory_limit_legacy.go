@@ -0,0 +1,10 @@
+//go:build !go1.19
+// +build !go1.19
+
+package main
+
+import "fmt"
+
+func setMemoryLimit(limit int64) error {
+	return fmt.Errorf("-memory-limit needs a binary built with Go 1.19 or later")
+}
@@ -0,0 +1,74 @@
+package calendar
+
+import (
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+)
+
+// DateLayout is the usual layout of sailDate, startDate and endDate.
+const DateLayout = "2006-01-02"
+
+// Calendar tells which holidays a sailing overlaps.
+type Calendar struct {
+	holidays []config.HolidayConfig
+}
+
+func New(holidays []config.HolidayConfig) *Calendar {
+	return &Calendar{holidays: holidays}
+}
+
+// Holidays returns the names of the holidays overlapping start to end, both
+// inclusive.
+func (c *Calendar) Holidays(start, end time.Time) []string {
+	var names []string
+	for i := range c.holidays {
+		h := &c.holidays[i]
+		years := []int{0}
+		if h.Yearly() {
+			// the previous year for ranges wrapping the new year
+			years = nil
+			for y := start.Year() - 1; y <= end.Year(); y++ {
+				years = append(years, y)
+			}
+		}
+		for _, y := range years {
+			from, to := h.Range(y)
+			if !start.After(to) && !end.Before(from) {
+				names = append(names, h.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// SailingDates returns the first and last day of a sailing, falling back to
+// the sail date plus the number of nights when start or end are missing.
+func SailingDates(sailDate, startDate, endDate string, nights int) (time.Time, time.Time, bool) {
+	start, ok := ParseSailDate(startDate, time.UTC)
+	if !ok {
+		if start, ok = ParseSailDate(sailDate, time.UTC); !ok {
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	end, ok := ParseSailDate(endDate, time.UTC)
+	if !ok {
+		end = start.AddDate(0, 0, nights)
+	}
+	return start, end, true
+}
+
+// sailDateLayouts are the sail date layouts markets were seen to return.
+var sailDateLayouts = []string{DateLayout, time.RFC3339, "2006-01-02T15:04:05", "20060102"}
+
+// ParseSailDate parses a sail date in any known layout. Dates without a time
+// zone are taken to be in loc, the others are converted to it.
+func ParseSailDate(sailDate string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range sailDateLayouts {
+		if t, err := time.ParseInLocation(layout, sailDate, loc); err == nil {
+			return t.In(loc), true
+		}
+	}
+	return time.Time{}, false
+}
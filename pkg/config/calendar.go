@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// HolidayConfig is a named date range such as a school break. Dates are
+// either YYYY-MM-DD or MM-DD for a range recurring every year.
+type HolidayConfig struct {
+	Name string `yaml:"name"`
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+func (h *HolidayConfig) Validate() error {
+	if h.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	from, fromYearly, err := parseHolidayDate(h.From)
+	if err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	to, toYearly, err := parseHolidayDate(h.To)
+	if err != nil {
+		return fmt.Errorf("to: %w", err)
+	}
+	if fromYearly != toYearly {
+		return fmt.Errorf("from and to must both be YYYY-MM-DD or both MM-DD")
+	}
+	if !fromYearly && to.Before(from) {
+		return fmt.Errorf("to is before from")
+	}
+	return nil
+}
+
+// Yearly reports whether the range recurs every year.
+func (h *HolidayConfig) Yearly() bool {
+	_, yearly, _ := parseHolidayDate(h.From)
+	return yearly
+}
+
+// Range returns the dates of the range, in the given year for yearly ones.
+// Yearly ranges wrapping the new year end in the following year.
+func (h *HolidayConfig) Range(year int) (time.Time, time.Time) {
+	from, yearly, _ := parseHolidayDate(h.From)
+	to, _, _ := parseHolidayDate(h.To)
+	if !yearly {
+		return from, to
+	}
+	from = from.AddDate(year-from.Year(), 0, 0)
+	to = to.AddDate(year-to.Year(), 0, 0)
+	if to.Before(from) {
+		to = to.AddDate(1, 0, 0)
+	}
+	return from, to
+}
+
+func parseHolidayDate(s string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, false, nil
+	}
+	// a leap year so 02-29 parses
+	t, err := time.Parse("2006-01-02", "2000-"+s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or MM-DD", s)
+	}
+	return t, true, nil
+}
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Errors are the problems found validating a config, every one of them
+// rather than the first.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// err returns e, or nil without problems.
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+type Config struct {
+	Targets          []Target                `yaml:"targets"`
+	FileSDConfigs    []FileSDConfig          `yaml:"file_sd_configs"`
+	RelabelConfigs   []RelabelConfig         `yaml:"relabel_configs"`
+	HTTPClient       HTTPClientConfig        `yaml:"http_client"`
+	Notifiers        []NotifierConfig        `yaml:"notifiers"`
+	Routes           []RouteConfig           `yaml:"routes"`
+	Outbox           *OutboxConfig           `yaml:"notification_outbox"`
+	History          *HistoryConfig          `yaml:"history"`
+	Anomaly          *AnomalyConfig          `yaml:"anomaly"`
+	Trend            *TrendConfig            `yaml:"trend"`
+	Digest           *DigestConfig           `yaml:"digest"`
+	ItineraryChanges *ItineraryChangesConfig `yaml:"itinerary_changes"`
+	Watches          []WatchConfig           `yaml:"watches"`
+	WatchStateFile   string                  `yaml:"watch_state_file,omitempty"`
+	WatchesFile      string                  `yaml:"watches_file,omitempty"`
+	AuditLogFile     string                  `yaml:"audit_log_file,omitempty"`
+	Users            []UserConfig            `yaml:"users"`
+	Alertmanager     *AlertmanagerConfig     `yaml:"alertmanager"`
+	Holidays         []HolidayConfig         `yaml:"holidays"`
+	Rollups          *RollupsConfig          `yaml:"rollups"`
+	LeaderElection   *LeaderElectionConfig   `yaml:"leader_election"`
+	Redis            *RedisConfig            `yaml:"redis"`
+	ScrapeWindow     *TimeWindowConfig       `yaml:"scrape_window"`
+	Operations       []OperationConfig       `yaml:"operations"`
+	DerivedMetrics   []DerivedMetricConfig   `yaml:"derived_metrics"`
+	PricingCalendar  *PricingCalendarConfig  `yaml:"pricing_calendar"`
+	SuperCategories  *SuperCategoriesConfig  `yaml:"super_categories"`
+	// LegacyMetricNames exports the metrics renamed to follow the Prometheus
+	// naming conventions under their old names too, see
+	// ExportLegacyMetricNames.
+	LegacyMetricNames *bool `yaml:"legacy_metric_names,omitempty"`
+}
+
+// Load reads and validates the YAML config file at path. ${VAR} references
+// are replaced with environment variables and *_file fields are read
+// relative to the config file, so secrets don't have to live in it.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = expandEnv(b); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if problems := checkSchema(Schema(), doc, ""); len(problems) > 0 {
+		return nil, fmt.Errorf("parsing %s: %s", path, strings.Join(problems, "; "))
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	var errs Errors
+	if err := cfg.HTTPClient.loadSecrets(filepath.Dir(path)); err != nil {
+		errs = append(errs, fmt.Errorf("http_client: %w", err))
+	}
+	for i := range cfg.Notifiers {
+		if err := cfg.Notifiers[i].loadSecrets(filepath.Dir(path)); err != nil {
+			errs = append(errs, fmt.Errorf("notifiers[%d]: %w", i, err))
+		}
+	}
+	for i := range cfg.Users {
+		if err := cfg.Users[i].loadSecrets(filepath.Dir(path)); err != nil {
+			errs = append(errs, fmt.Errorf("users[%d]: %w", i, err))
+		}
+	}
+	if cfg.Redis != nil {
+		if err := cfg.Redis.loadSecrets(filepath.Dir(path)); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		}
+	}
+	if cfg.Alertmanager != nil {
+		if err := cfg.Alertmanager.loadSecrets(filepath.Dir(path)); err != nil {
+			errs = append(errs, fmt.Errorf("alertmanager: %w", err))
+		}
+	}
+	// a secret that failed to load fails validation too, once is enough
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("loading secrets of %s: %w", path, errs)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validating %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the config, returning every problem found as Errors.
+func (c *Config) Validate() error {
+	var errs Errors
+	if err := ValidateTargets(c.Targets); err != nil {
+		errs = append(errs, err.(Errors)...)
+	}
+	for i := range c.FileSDConfigs {
+		if err := c.FileSDConfigs[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("file_sd_configs[%d]: %w", i, err))
+		}
+	}
+	for i := range c.RelabelConfigs {
+		if err := c.RelabelConfigs[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("relabel_configs[%d]: %w", i, err))
+		}
+	}
+	if err := c.HTTPClient.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("http_client: %w", err))
+	}
+	seen := map[string]bool{}
+	for i := range c.Notifiers {
+		if err := c.Notifiers[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("notifiers[%d]: %w", i, err))
+		}
+		if seen[c.Notifiers[i].Name] {
+			errs = append(errs, fmt.Errorf("notifiers[%d]: duplicate notifier name %q", i, c.Notifiers[i].Name))
+		}
+		seen[c.Notifiers[i].Name] = true
+	}
+	for i := range c.Routes {
+		if err := c.Routes[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("routes[%d]: %w", i, err))
+		}
+		if err := c.checkNotifierNames(c.Routes[i].Notify); err != nil {
+			errs = append(errs, fmt.Errorf("routes[%d]: %w", i, err))
+		}
+	}
+	if c.Outbox != nil {
+		if len(c.Notifiers) == 0 {
+			errs = append(errs, fmt.Errorf("notification_outbox: requires notifiers to be configured"))
+		}
+		if err := c.Outbox.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("notification_outbox: %w", err))
+		}
+	}
+	if c.History != nil {
+		if err := c.History.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("history: %w", err))
+		}
+	}
+	if c.Anomaly != nil {
+		if c.History == nil {
+			errs = append(errs, fmt.Errorf("anomaly: requires history to be configured"))
+		}
+		if err := c.Anomaly.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("anomaly: %w", err))
+		}
+		if err := c.checkNotifierNames(c.Anomaly.Notify); err != nil {
+			errs = append(errs, fmt.Errorf("anomaly: %w", err))
+		}
+	}
+	if c.Trend != nil {
+		if c.History == nil {
+			errs = append(errs, fmt.Errorf("trend: requires history to be configured"))
+		}
+		if err := c.Trend.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("trend: %w", err))
+		}
+	}
+	if c.Digest != nil {
+		if c.History == nil {
+			errs = append(errs, fmt.Errorf("digest: requires history to be configured"))
+		}
+		if err := c.Digest.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("digest: %w", err))
+		}
+		if err := c.checkNotifierNames(c.Digest.Notify); err != nil {
+			errs = append(errs, fmt.Errorf("digest: %w", err))
+		}
+	}
+	if c.ItineraryChanges != nil {
+		if err := c.checkNotifierNames(c.ItineraryChanges.Notify); err != nil {
+			errs = append(errs, fmt.Errorf("itinerary_changes: %w", err))
+		}
+	}
+	users := map[string]*UserConfig{}
+	for i := range c.Users {
+		if err := c.Users[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("users[%d]: %w", i, err))
+		}
+		if users[c.Users[i].Name] != nil {
+			errs = append(errs, fmt.Errorf("users[%d]: duplicate user name %q", i, c.Users[i].Name))
+		}
+		users[c.Users[i].Name] = &c.Users[i]
+		if err := c.checkNotifierNames(c.Users[i].Notify); err != nil {
+			errs = append(errs, fmt.Errorf("users[%d]: %w", i, err))
+		}
+	}
+	watches := map[string]bool{}
+	for i := range c.Watches {
+		if user := c.Watches[i].User; user != "" {
+			if users[user] == nil {
+				errs = append(errs, fmt.Errorf("watches[%d]: unknown user %q", i, user))
+			} else if err := users[user].OwnWatch(&c.Watches[i]); err != nil {
+				errs = append(errs, fmt.Errorf("watches[%d]: %w", i, err))
+			}
+		}
+		if err := c.Watches[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("watches[%d]: %w", i, err))
+		}
+		// an unscoped watch would only see the scoped products
+		if c.Watches[i].Scoped() != c.Watches[0].Scoped() {
+			errs = append(errs, fmt.Errorf("watches[%d]: either all watches or none set itinerary or product", i))
+		}
+		if watches[c.Watches[i].Name] {
+			errs = append(errs, fmt.Errorf("watches[%d]: duplicate watch name %q", i, c.Watches[i].Name))
+		}
+		watches[c.Watches[i].Name] = true
+		if err := c.checkNotifierNames(c.Watches[i].Notify); err != nil {
+			errs = append(errs, fmt.Errorf("watches[%d]: %w", i, err))
+		}
+	}
+	if c.Alertmanager != nil {
+		if err := c.Alertmanager.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("alertmanager: %w", err))
+		}
+	}
+	for i := range c.Holidays {
+		if err := c.Holidays[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("holidays[%d]: %w", i, err))
+		}
+	}
+	if c.Rollups != nil {
+		if err := c.Rollups.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rollups: %w", err))
+		}
+	}
+	if c.ScrapeWindow != nil {
+		if err := c.ScrapeWindow.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("scrape_window: %w", err))
+		}
+	}
+	metrics := map[string]bool{}
+	for i := range c.Operations {
+		if err := c.Operations[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("operations[%d]: %w", i, err))
+		}
+		for _, m := range c.Operations[i].Metrics {
+			if metrics[m.Name] {
+				errs = append(errs, fmt.Errorf("operations[%d]: duplicate metric name %q", i, m.Name))
+			}
+			metrics[m.Name] = true
+		}
+	}
+	for i := range c.DerivedMetrics {
+		if err := c.DerivedMetrics[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("derived_metrics[%d]: %w", i, err))
+		}
+		if metrics[c.DerivedMetrics[i].Name] {
+			errs = append(errs, fmt.Errorf("derived_metrics[%d]: duplicate metric name %q", i, c.DerivedMetrics[i].Name))
+		}
+		metrics[c.DerivedMetrics[i].Name] = true
+	}
+	if c.SuperCategories != nil {
+		if err := c.SuperCategories.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("super_categories: %w", err))
+		}
+	}
+	if c.PricingCalendar != nil {
+		if err := c.PricingCalendar.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("pricing_calendar: %w", err))
+		}
+	}
+	if c.Redis != nil {
+		if err := c.Redis.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		}
+	}
+	if c.LeaderElection != nil {
+		if err := c.LeaderElection.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("leader_election: %w", err))
+		}
+		if c.LeaderElection.Backend == LeaderElectionRedis && c.Redis == nil {
+			errs = append(errs, fmt.Errorf("leader_election: the redis backend requires the redis config"))
+		}
+	}
+	return errs.err()
+}
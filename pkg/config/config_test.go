@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	c := &Config{
+		Targets: []Target{{Name: "carib"}, {Name: "carib", URL: "https://www.royalcaribbean.com/graph"}},
+		Anomaly: &AnomalyConfig{},
+		Users:   []UserConfig{{Name: "alice"}},
+		Watches: []WatchConfig{{Name: "cheap", User: "bob", Below: 500, Product: "WN07RCI"}},
+	}
+	err := c.Validate()
+	var errs Errors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, []string{
+		"targets[0]: url is required",
+		"targets[1]: duplicate target name \"carib\"",
+		"anomaly: requires history to be configured",
+		"anomaly: window must be positive",
+		"users[0]: password is required",
+		"watches[0]: unknown user \"bob\"",
+	}, messages(errs))
+}
+
+func TestLoadReportsEverySecret(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`targets:
+  - url: https://www.royalcaribbean.com/graph
+users:
+  - name: alice
+    password_file: alice.txt
+    notify: [hook]
+  - name: bob
+    password_file: bob.txt
+    notify: [hook]
+`), 0644))
+	_, err := Load(path)
+	var errs Errors
+	require.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 2)
+}
+
+func messages(errs Errors) []string {
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return msgs
+}
+
+func TestSecretFiles(t *testing.T) {
+	c := &Config{
+		Users:        []UserConfig{{Name: "alice", PasswordFile: "alice.txt"}, {Name: "bob", Password: "secret"}},
+		Alertmanager: &AlertmanagerConfig{URLFile: "/run/secrets/alertmanager"},
+	}
+	assert.Equal(t, []string{"/etc/exporter/alice.txt", "/run/secrets/alertmanager"}, c.SecretFiles("/etc/exporter/config.yml"))
+}
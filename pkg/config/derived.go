@@ -0,0 +1,23 @@
+package config
+
+import "fmt"
+
+// DerivedMetricConfig is a gauge computed for every price series from an
+// expression over its SeriesVariables, like price / nights / guests.
+type DerivedMetricConfig struct {
+	// Name is exported as royal_external_<name>, with the labels of
+	// royal_external_price.
+	Name  string      `yaml:"name"`
+	Help  string      `yaml:"help,omitempty"`
+	Value *Expression `yaml:"value"`
+}
+
+func (c *DerivedMetricConfig) Validate() error {
+	if !metricNameRE.MatchString(c.Name) {
+		return fmt.Errorf("invalid metric name %q", c.Name)
+	}
+	if c.Value == nil {
+		return fmt.Errorf("value is required")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestConfig sends a scheduled summary of the price history: the biggest
+// drops, new lowest-ever prices and new sailings of the listed ships.
+type DigestConfig struct {
+	Schedule string `yaml:"schedule"`
+	// At is the local time of day the digest is sent, as HH:MM.
+	At string `yaml:"at"`
+	// Weekday is the day weekly digests are sent.
+	Weekday string `yaml:"weekday"`
+	// Top limits every section of the digest.
+	Top int `yaml:"top"`
+	// Ships are the ship names or codes new sailings are reported for.
+	Ships  []string `yaml:"ships,omitempty"`
+	Notify []string `yaml:"notify,omitempty"`
+}
+
+func (c *DigestConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain DigestConfig
+	*c = DigestConfig{Schedule: DigestWeekly, At: "08:00", Weekday: "monday", Top: 10}
+	return unmarshal((*plain)(c))
+}
+
+func (c *DigestConfig) Validate() error {
+	if c.Schedule != DigestDaily && c.Schedule != DigestWeekly {
+		return fmt.Errorf("schedule must be %s or %s, got %q", DigestDaily, DigestWeekly, c.Schedule)
+	}
+	if _, err := time.Parse("15:04", c.At); err != nil {
+		return fmt.Errorf("at must be a time of day like 08:00, got %q", c.At)
+	}
+	if _, err := c.ParseWeekday(); err != nil {
+		return err
+	}
+	if c.Top <= 0 {
+		return fmt.Errorf("top must be positive")
+	}
+	return nil
+}
+
+func (c *DigestConfig) ParseWeekday() (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), c.Weekday) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", c.Weekday)
+}
+
+// Period is how much history one digest covers.
+func (c *DigestConfig) Period() time.Duration {
+	if c.Schedule == DigestDaily {
+		return 24 * time.Hour
+	}
+	return 7 * 24 * time.Hour
+}
+
+// Next returns the first time after now a digest is due.
+func (c *DigestConfig) Next(now time.Time) time.Time {
+	at, _ := time.Parse("15:04", c.At)
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	weekday, _ := c.ParseWeekday()
+	for !next.After(now) || (c.Schedule == DigestWeekly && next.Weekday() != weekday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
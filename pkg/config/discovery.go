@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// FileSDConfig discovers targets from JSON or YAML files in the same format
+// as Prometheus file_sd: a list of groups, each with a list of target URLs
+// and the labels they share.
+type FileSDConfig struct {
+	Files           []string      `yaml:"files"`
+	RefreshInterval time.Duration `yaml:"refresh_interval,omitempty"`
+	// LabelNames declares the labels discovered targets may carry, since the
+	// label set of every metric is fixed at startup.
+	LabelNames []string `yaml:"label_names,omitempty"`
+}
+
+func (c *FileSDConfig) Validate() error {
+	if len(c.Files) == 0 {
+		return fmt.Errorf("files are required")
+	}
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = 5 * time.Minute
+	}
+	for _, name := range c.LabelNames {
+		if !labelNameRE.MatchString(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	return nil
+}
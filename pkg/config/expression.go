@@ -0,0 +1,45 @@
+package config
+
+import "github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/expr"
+
+// SeriesVariables describes the variables the expressions over a price
+// series can refer to besides its labels, which are strings named after
+// them.
+var SeriesVariables = map[string]string{
+	"price":        "the price of the series",
+	"nights":       "the length of the cruise, the days label as a number",
+	"guests":       "the guests of the cabin, see -cabin-guests, 2 by default",
+	"sail_date":    "the sail date as YYYY-MM-DD",
+	"days_to_sail": "the days until the sail date",
+}
+
+// Expression is an expression of the expr package, kept as its source in
+// the config file.
+type Expression struct {
+	*expr.Expr
+}
+
+func NewExpression(s string) (*Expression, error) {
+	e, err := expr.Compile(s)
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{e}, nil
+}
+
+func (e *Expression) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	parsed, err := NewExpression(s)
+	if err != nil {
+		return err
+	}
+	*e = *parsed
+	return nil
+}
+
+func (e Expression) MarshalYAML() (interface{}, error) {
+	return e.String(), nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// HistoryConfig enables the price history the anomaly detector and other
+// features build on. It is kept in memory and optionally saved to File.
+type HistoryConfig struct {
+	Retention  time.Duration `yaml:"retention"`
+	Resolution time.Duration `yaml:"resolution"`
+	File       string        `yaml:"file,omitempty"`
+}
+
+func (c *HistoryConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain HistoryConfig
+	*c = HistoryConfig{Retention: 14 * 24 * time.Hour, Resolution: time.Hour}
+	return unmarshal((*plain)(c))
+}
+
+func (c *HistoryConfig) Validate() error {
+	if c.Retention <= 0 || c.Resolution <= 0 {
+		return fmt.Errorf("retention and resolution must be positive")
+	}
+	return nil
+}
+
+// AnomalyConfig flags prices that jump away from their recent history, which
+// catches both pricing errors and flash sales.
+type AnomalyConfig struct {
+	// Window is how much history a price is compared against.
+	Window time.Duration `yaml:"window"`
+	// MinSamples is the history needed before a series is judged at all.
+	MinSamples int `yaml:"min_samples"`
+	// ZScore flags prices this many standard deviations from the mean.
+	ZScore float64 `yaml:"z_score"`
+	// PercentChange flags prices that moved by this much since the previous
+	// sample, 0 to disable.
+	PercentChange float64 `yaml:"percent_change"`
+	// Notify lists the notifiers told about new anomalies.
+	Notify []string `yaml:"notify,omitempty"`
+}
+
+func (c *AnomalyConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain AnomalyConfig
+	*c = AnomalyConfig{Window: 7 * 24 * time.Hour, MinSamples: 5, ZScore: 3, PercentChange: 20}
+	return unmarshal((*plain)(c))
+}
+
+func (c *AnomalyConfig) Validate() error {
+	if c.Window <= 0 {
+		return fmt.Errorf("window must be positive")
+	}
+	if c.MinSamples < 2 {
+		return fmt.Errorf("min_samples must be at least 2")
+	}
+	if c.ZScore <= 0 && c.PercentChange <= 0 {
+		return fmt.Errorf("at least one of z_score and percent_change must be set")
+	}
+	return nil
+}
+
+// TrendConfig exports the linear trend of every price over a window.
+type TrendConfig struct {
+	Window     time.Duration `yaml:"window"`
+	MinSamples int           `yaml:"min_samples"`
+}
+
+func (c *TrendConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain TrendConfig
+	*c = TrendConfig{Window: 7 * 24 * time.Hour, MinSamples: 3}
+	return unmarshal((*plain)(c))
+}
+
+func (c *TrendConfig) Validate() error {
+	if c.Window <= 0 {
+		return fmt.Errorf("window must be positive")
+	}
+	if c.MinSamples < 2 {
+		return fmt.Errorf("min_samples must be at least 2")
+	}
+	return nil
+}
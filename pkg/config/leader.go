@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	LeaderElectionFile  = "file"
+	LeaderElectionRedis = "redis"
+)
+
+// LeaderElectionConfig lets only one of several replicas scrape.
+type LeaderElectionConfig struct {
+	// Backend is file or redis, which uses the redis config.
+	Backend string `yaml:"backend"`
+	// LeaseFile must be on storage shared by all replicas, which creates
+	// files with O_EXCL atomically for the lock file next to it.
+	LeaseFile     string        `yaml:"lease_file,omitempty"`
+	LeaseDuration time.Duration `yaml:"lease_duration"`
+	// Identity defaults to the hostname, which is the pod name on Kubernetes.
+	Identity string `yaml:"identity,omitempty"`
+}
+
+func (c *LeaderElectionConfig) Validate() error {
+	switch c.Backend {
+	case "", LeaderElectionFile:
+		c.Backend = LeaderElectionFile
+		if c.LeaseFile == "" {
+			return fmt.Errorf("lease_file is required")
+		}
+	case LeaderElectionRedis:
+	default:
+		return fmt.Errorf("unknown backend %q", c.Backend)
+	}
+	if c.LeaseDuration == 0 {
+		c.LeaseDuration = 30 * time.Second
+	}
+	if c.LeaseDuration < 3*time.Second {
+		return fmt.Errorf("lease_duration must be at least 3s")
+	}
+	if c.Identity == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("identity is required: %w", err)
+		}
+		c.Identity = host
+	}
+	return nil
+}
+
+// RedisConfig is a Redis server shared by the replicas for leader election
+// and the latest catalog of every target.
+type RedisConfig struct {
+	Address      string `yaml:"address"`
+	Password     Secret `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+	DB           int    `yaml:"db,omitempty"`
+	KeyPrefix    string `yaml:"key_prefix,omitempty"`
+	// ShareCatalog stores the catalog the leader scraped so the other
+	// replicas export the same data without scraping.
+	ShareCatalog bool `yaml:"share_catalog,omitempty"`
+}
+
+func (c *RedisConfig) loadSecrets(dir string) error {
+	return loadSecretFile(&c.Password, c.PasswordFile, dir, "password")
+}
+
+func (c *RedisConfig) Validate() error {
+	if c.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = "royal:"
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package config
+
+// LegacyMetricNamesDefault tells whether the metric names renamed to follow
+// the Prometheus naming conventions are exported next to the new ones when
+// legacy_metric_names is unset. A release turns it off, and the release
+// after removes the legacy names.
+const LegacyMetricNamesDefault = true
+
+// ExportLegacyMetricNames reports whether the legacy metric names are
+// exported: as set by legacy_metric_names, else LegacyMetricNamesDefault.
+func (c *Config) ExportLegacyMetricNames() bool {
+	if c.LegacyMetricNames != nil {
+		return *c.LegacyMetricNames
+	}
+	return LegacyMetricNamesDefault
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+)
+
+// NotifierConfig is a notification channel events can be routed to by name.
+type NotifierConfig struct {
+	Name           string `yaml:"name"`
+	WebhookURL     Secret `yaml:"webhook_url,omitempty"`
+	WebhookURLFile string `yaml:"webhook_url_file,omitempty"`
+	// Exec runs the command, with its arguments, for every event instead of
+	// posting it to a webhook. The event is written to its stdin.
+	Exec []string `yaml:"exec,omitempty"`
+	// RateLimit caps the notifications sent through the channel.
+	RateLimit *RateLimitConfig `yaml:"rate_limit,omitempty"`
+	// The templates are Go templates executed with the notify.Event, e.g.
+	// "{{.Labels.ship}} at {{price .Price}}". BodyTemplate replaces the JSON
+	// body, to post to a chat or mail relay that expects its own format.
+	TitleTemplate string `yaml:"title_template,omitempty"`
+	TextTemplate  string `yaml:"text_template,omitempty"`
+	BodyTemplate  string `yaml:"body_template,omitempty"`
+	ContentType   string `yaml:"content_type,omitempty"`
+	// MinPriority drops events below low, normal or high.
+	MinPriority string `yaml:"min_priority,omitempty"`
+	// QuietHours only lets high priority events through during the hours.
+	QuietHours *TimeWindowConfig `yaml:"quiet_hours,omitempty"`
+}
+
+func (c *NotifierConfig) loadSecrets(dir string) error {
+	return loadSecretFile(&c.WebhookURL, c.WebhookURLFile, dir, "webhook_url")
+}
+
+func (c *NotifierConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if c.WebhookURL == "" && len(c.Exec) == 0 {
+		return fmt.Errorf("webhook_url or exec is required")
+	}
+	if c.WebhookURL != "" && len(c.Exec) > 0 {
+		return fmt.Errorf("webhook_url and exec are mutually exclusive")
+	}
+	if len(c.Exec) > 0 && c.Exec[0] == "" {
+		return fmt.Errorf("exec: command is empty")
+	}
+	if c.RateLimit != nil {
+		if err := c.RateLimit.Validate(); err != nil {
+			return fmt.Errorf("rate_limit: %w", err)
+		}
+	}
+	if _, err := c.Templates(); err != nil {
+		return err
+	}
+	if err := notify.ValidatePriority(c.MinPriority); err != nil {
+		return fmt.Errorf("min_priority: %w", err)
+	}
+	if c.QuietHours != nil {
+		if err := c.QuietHours.Validate(); err != nil {
+			return fmt.Errorf("quiet_hours: %w", err)
+		}
+	}
+	return nil
+}
+
+// Templates parses the templates of the channel, nil when there are none.
+func (c *NotifierConfig) Templates() (*notify.Templates, error) {
+	if c.TitleTemplate == "" && c.TextTemplate == "" && c.BodyTemplate == "" {
+		return nil, nil
+	}
+	return notify.ParseTemplates(c.TitleTemplate, c.TextTemplate, c.BodyTemplate, c.ContentType)
+}
+
+// RateLimitConfig allows at most Count notifications per Interval, those
+// beyond are dropped.
+type RateLimitConfig struct {
+	Count    int           `yaml:"count"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+func (c *RateLimitConfig) Validate() error {
+	if c.Count <= 0 {
+		return fmt.Errorf("count must be positive")
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	return nil
+}
+
+// OutboxConfig retries failed notifications with a growing backoff until
+// they are MaxAge old. They are kept in File, if set, across restarts.
+type OutboxConfig struct {
+	File          string        `yaml:"file,omitempty"`
+	MaxAge        time.Duration `yaml:"max_age"`
+	RetryInterval time.Duration `yaml:"retry_interval"`
+}
+
+func (c *OutboxConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain OutboxConfig
+	*c = OutboxConfig{MaxAge: 24 * time.Hour, RetryInterval: 10 * time.Second}
+	return unmarshal((*plain)(c))
+}
+
+func (c *OutboxConfig) Validate() error {
+	if c.MaxAge <= 0 || c.RetryInterval <= 0 {
+		return fmt.Errorf("max_age and retry_interval must be positive")
+	}
+	return nil
+}
+
+// checkNotifierNames reports names that don't refer to a configured notifier.
+func (c *Config) checkNotifierNames(names []string) error {
+	for _, name := range names {
+		found := false
+		for _, n := range c.Notifiers {
+			found = found || n.Name == name
+		}
+		if !found {
+			return fmt.Errorf("unknown notifier %q", name)
+		}
+	}
+	return nil
+}
+
+// RouteConfig sends the events matching it to notifiers on top of those the
+// rule that raised them names. Empty fields match every event.
+type RouteConfig struct {
+	// Kinds are event kinds like watch, stateroom_available or
+	// price_anomaly.
+	Kinds []string `yaml:"kinds,omitempty"`
+	// Rules are the names of the watches raising the events.
+	Rules       []string `yaml:"rules,omitempty"`
+	MinPriority string   `yaml:"min_priority,omitempty"`
+	Notify      []string `yaml:"notify"`
+}
+
+func (c *RouteConfig) Validate() error {
+	if len(c.Notify) == 0 {
+		return fmt.Errorf("notify is required")
+	}
+	if err := notify.ValidatePriority(c.MinPriority); err != nil {
+		return fmt.Errorf("min_priority: %w", err)
+	}
+	return nil
+}
+
+// ItineraryChangesConfig routes itinerary change events to notifiers.
+type ItineraryChangesConfig struct {
+	Notify []string `yaml:"notify,omitempty"`
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/jsonpath"
+)
+
+var metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// OperationConfig is a GraphQL operation sent to the targets on every scrape
+// besides the cruise search, with the metrics read from its response.
+type OperationConfig struct {
+	Name string `yaml:"name"`
+	// OperationName is sent as the operationName, Name by default.
+	OperationName string                 `yaml:"operation_name,omitempty"`
+	Query         string                 `yaml:"query"`
+	Variables     map[string]interface{} `yaml:"variables,omitempty"`
+	// Targets are the names of the targets sent the operation, all of them
+	// by default.
+	Targets []string                `yaml:"targets,omitempty"`
+	Metrics []OperationMetricConfig `yaml:"metrics"`
+}
+
+// OperationMetricConfig maps a response field to a metric.
+type OperationMetricConfig struct {
+	// Name is exported as royal_external_<name>.
+	Name string `yaml:"name"`
+	Help string `yaml:"help,omitempty"`
+	// Items is the path of the objects to export one series each for, like
+	// data.calendar.days[*], the whole response by default. Value and the
+	// labels are paths relative to an item.
+	Items  string            `yaml:"items,omitempty"`
+	Value  string            `yaml:"value"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+func (c *OperationConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if c.OperationName == "" {
+		c.OperationName = c.Name
+	}
+	if c.Query == "" {
+		return fmt.Errorf("query is required")
+	}
+	if len(c.Metrics) == 0 {
+		return fmt.Errorf("metrics is required")
+	}
+	for i := range c.Metrics {
+		if err := c.Metrics[i].Validate(); err != nil {
+			return fmt.Errorf("metrics[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *OperationMetricConfig) Validate() error {
+	if !metricNameRE.MatchString(c.Name) {
+		return fmt.Errorf("invalid metric name %q", c.Name)
+	}
+	if c.Value == "" {
+		return fmt.Errorf("value is required")
+	}
+	paths := []string{c.Items, c.Value}
+	for name, path := range c.Labels {
+		if !labelNameRE.MatchString(name) || name == "url" {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		if _, err := jsonpath.Parse(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JSONVariables returns the variables with the nested mappings YAML decodes
+// turned into objects encoding/json can marshal.
+func (c *OperationConfig) JSONVariables() map[string]interface{} {
+	vars := make(map[string]interface{}, len(c.Variables))
+	for k, v := range c.Variables {
+		vars[k] = jsonValue(v)
+	}
+	return vars
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = jsonValue(e)
+		}
+		return list
+	}
+	return v
+}
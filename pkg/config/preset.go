@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Presets are named bundles of target settings for common use, settings a
+// target sets itself win over those of its preset.
+var Presets = map[string]Target{
+	// light keeps a small catalog current with little traffic.
+	"light": {
+		PageSize:           50,
+		PageConcurrency:    1,
+		QueryFeatures:      "lowest-price",
+		DailyRequestBudget: 500,
+	},
+	// full-catalog exports everything the website shows, as fast as the
+	// target allows.
+	"full-catalog": {
+		PageSize:        100,
+		PageConcurrency: 4,
+		QueryFeatures:   "all",
+	},
+	// stealth sends few small requests, like a single visitor browsing.
+	"stealth": {
+		PageSize:           20,
+		PageConcurrency:    1,
+		QueryFeatures:      "lowest-price",
+		DailyRequestBudget: 100,
+	},
+}
+
+// applyPreset fills the settings the target leaves unset from its preset.
+func (t *Target) applyPreset() error {
+	if t.Preset == "" {
+		return nil
+	}
+	p, ok := Presets[t.Preset]
+	if !ok {
+		names := make([]string, 0, len(Presets))
+		for name := range Presets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown preset %q, expected one of %s", t.Preset, strings.Join(names, ", "))
+	}
+	if t.PageSize == 0 {
+		t.PageSize = p.PageSize
+	}
+	if t.PageConcurrency == 0 {
+		t.PageConcurrency = p.PageConcurrency
+	}
+	if t.QueryFeatures == "" {
+		t.QueryFeatures = p.QueryFeatures
+	}
+	if t.DailyRequestBudget == 0 {
+		t.DailyRequestBudget = p.DailyRequestBudget
+	}
+	return nil
+}
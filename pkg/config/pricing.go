@@ -0,0 +1,29 @@
+package config
+
+import "fmt"
+
+// PricingCalendarConfig exports the month view pricing calendar of some
+// itineraries, a request per itinerary and month instead of paging through
+// the whole catalog.
+type PricingCalendarConfig struct {
+	// Itineraries are itinerary codes like the itinerary price label.
+	Itineraries []string `yaml:"itineraries"`
+	// Months is the number of months fetched, starting with the current
+	// one. 12 by default.
+	Months int `yaml:"months,omitempty"`
+	// Targets are the names of the targets asked, all of them by default.
+	Targets []string `yaml:"targets,omitempty"`
+}
+
+func (c *PricingCalendarConfig) Validate() error {
+	if len(c.Itineraries) == 0 {
+		return fmt.Errorf("itineraries is required")
+	}
+	if c.Months == 0 {
+		c.Months = 12
+	}
+	if c.Months < 0 {
+		return fmt.Errorf("months must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	RelabelReplace   = "replace"
+	RelabelKeep      = "keep"
+	RelabelDrop      = "drop"
+	RelabelLabelDrop = "labeldrop"
+)
+
+// RelabelConfig mirrors the subset of Prometheus metric_relabel_configs the
+// exporter applies before exposition. The metric name is available as the
+// __name__ source label.
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    string   `yaml:"separator"`
+	Regex        Regexp   `yaml:"regex"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  string   `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+}
+
+func (r *RelabelConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain RelabelConfig
+	*r = RelabelConfig{
+		Separator:   ";",
+		Regex:       MustNewRegexp("(.*)"),
+		Replacement: "$1",
+		Action:      RelabelReplace,
+	}
+	return unmarshal((*plain)(r))
+}
+
+func (r *RelabelConfig) Validate() error {
+	if r.Regex.Regexp == nil {
+		r.Regex = MustNewRegexp("(.*)")
+	}
+	switch r.Action {
+	case RelabelReplace:
+		if r.TargetLabel == "" {
+			return fmt.Errorf("target_label is required for action %q", r.Action)
+		}
+		if len(r.SourceLabels) == 0 {
+			return fmt.Errorf("source_labels are required for action %q", r.Action)
+		}
+	case RelabelKeep, RelabelDrop:
+		if len(r.SourceLabels) == 0 {
+			return fmt.Errorf("source_labels are required for action %q", r.Action)
+		}
+	case RelabelLabelDrop:
+		if len(r.SourceLabels) > 0 || r.TargetLabel != "" {
+			return fmt.Errorf("source_labels and target_label are not allowed for action %q", r.Action)
+		}
+	default:
+		return fmt.Errorf("unknown relabel action %q", r.Action)
+	}
+	return nil
+}
+
+// Regexp is a regular expression anchored at both ends, as in Prometheus.
+type Regexp struct {
+	*regexp.Regexp
+	original string
+}
+
+func NewRegexp(s string) (Regexp, error) {
+	re, err := regexp.Compile("^(?:" + s + ")$")
+	return Regexp{Regexp: re, original: s}, err
+}
+
+func MustNewRegexp(s string) Regexp {
+	re, err := NewRegexp(s)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+func (re *Regexp) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	r, err := NewRegexp(s)
+	if err != nil {
+		return err
+	}
+	*re = r
+	return nil
+}
+
+func (re Regexp) MarshalYAML() (interface{}, error) {
+	return re.original, nil
+}
+
+func (re Regexp) String() string {
+	return re.original
+}
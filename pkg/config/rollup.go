@@ -0,0 +1,59 @@
+package config
+
+import "fmt"
+
+// RollupDimensions are the labels rollups can group by besides the price
+// labels. month is the YYYY-MM of the sail date, super_category that of the
+// stateroom class.
+var RollupDimensions = map[string]bool{
+	"month": true, "super_category": true, "ship": true, "shipcode": true, "cruiseid": true, "itinerary": true, "stateroomclass": true,
+	"datelabel": true, "departureday": true, "departureport": true, "days": true, "destinationcode": true,
+}
+
+// RollupAggregations are the supported aggregation functions.
+var RollupAggregations = map[string]bool{"min": true, "max": true, "avg": true, "count": true}
+
+// RollupsConfig exports compact aggregations of the prices of every scrape,
+// alongside or instead of the per sailing series.
+type RollupsConfig struct {
+	// DropRaw stops exporting royal_external_price. Watches, history and
+	// everything else still see every price.
+	DropRaw bool           `yaml:"drop_raw,omitempty"`
+	Rules   []RollupConfig `yaml:"rules"`
+}
+
+// RollupConfig is exported as royal_external_price_rollup_<name> with one
+// series per group and aggregation.
+type RollupConfig struct {
+	Name         string   `yaml:"name"`
+	By           []string `yaml:"by"`
+	Aggregations []string `yaml:"aggregations"`
+}
+
+func (c *RollupsConfig) Validate() error {
+	names := map[string]bool{}
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if !labelNameRE.MatchString(r.Name) {
+			return fmt.Errorf("rules[%d]: invalid name %q", i, r.Name)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rules[%d]: duplicate name %q", i, r.Name)
+		}
+		names[r.Name] = true
+		for _, by := range r.By {
+			if !RollupDimensions[by] {
+				return fmt.Errorf("rules[%d]: cannot group by %q", i, by)
+			}
+		}
+		if len(r.Aggregations) == 0 {
+			r.Aggregations = []string{"min"}
+		}
+		for _, a := range r.Aggregations {
+			if !RollupAggregations[a] {
+				return fmt.Errorf("rules[%d]: unknown aggregation %q", i, a)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	durationType   = reflect.TypeOf(time.Duration(0))
+	regexpType     = reflect.TypeOf(Regexp{})
+	expressionType = reflect.TypeOf(Expression{})
+)
+
+// Schema returns the JSON Schema of the config file, generated from Config
+// so it can't drift from what Load accepts.
+func Schema() map[string]interface{} {
+	s := typeSchema(reflect.TypeOf(Config{}))
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "royalcaribbean-prometheus-exporter config file"
+	return s
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case durationType:
+		return map[string]interface{}{"type": "string", "pattern": `^-?(0|([0-9]*(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)$`}
+	case regexpType:
+		return map[string]interface{}{"type": "string", "format": "regex"}
+	case expressionType:
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Interface:
+		// any value
+		return map[string]interface{}{}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if f.PkgPath != "" || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			props[name] = typeSchema(f.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": props, "additionalProperties": false}
+	}
+	panic(fmt.Sprintf("config: no schema for %s", t))
+}
+
+// checkSchema reports where the YAML document v, as decoded into an
+// interface{}, doesn't match the schema s. Null is allowed anywhere, it keeps
+// the default, and like yaml.v2 any scalar is a valid string.
+func checkSchema(s map[string]interface{}, v interface{}, path string) []string {
+	if v == nil {
+		return nil
+	}
+	at := path
+	if at == "" {
+		at = "top level"
+	}
+	mismatch := func() []string {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", at, s["type"], yamlKind(v))}
+	}
+	switch s["type"] {
+	case "string":
+		switch v.(type) {
+		case []interface{}, map[interface{}]interface{}:
+			return mismatch()
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch()
+		}
+	case "integer":
+		switch v.(type) {
+		case int, int64, uint64:
+		default:
+			return mismatch()
+		}
+	case "number":
+		switch v.(type) {
+		case int, int64, uint64, float64:
+		default:
+			return mismatch()
+		}
+	case "array":
+		list, ok := v.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		var problems []string
+		for i, item := range list {
+			problems = append(problems, checkSchema(s["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return problems
+	case "object":
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			return mismatch()
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(m))
+		values := map[string]interface{}{}
+		for k, value := range m {
+			keys = append(keys, fmt.Sprint(k))
+			values[fmt.Sprint(k)] = value
+		}
+		sort.Strings(keys)
+		var problems []string
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			prop, known := props[k].(map[string]interface{})
+			if !known {
+				extra, ok := s["additionalProperties"].(map[string]interface{})
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%s: unknown key %q%s", at, k, suggestKey(k, props)))
+					continue
+				}
+				prop = extra
+			}
+			problems = append(problems, checkSchema(prop, values[k], child)...)
+		}
+		return problems
+	}
+	return nil
+}
+
+func yamlKind(v interface{}) string {
+	switch v.(type) {
+	case []interface{}:
+		return "a list"
+	case map[interface{}]interface{}:
+		return "a mapping"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// suggestKey returns a hint naming the known key closest to a misspelled one.
+func suggestKey(key string, props map[string]interface{}) string {
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	best, bestDistance := "", 3
+	for _, name := range names {
+		if d := editDistance(key, name); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
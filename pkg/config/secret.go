@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Secret is a config value such as a password, token or URL with
+// credentials. It is never printed.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "<secret>"
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// envRE only matches the braced form so $1 in relabel replacements and
+// ${1} group references are left alone.
+var envRE = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} with the value of the environment variable VAR.
+// Referencing an unset variable is an error rather than an empty string.
+func expandEnv(b []byte) ([]byte, error) {
+	missing := map[string]bool{}
+	b = envRE.ReplaceAllFunc(b, func(m []byte) []byte {
+		name := string(envRE.FindSubmatch(m)[1])
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing[name] = true
+		}
+		return []byte(v)
+	})
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("environment variables not set: %s", strings.Join(names, ", "))
+	}
+	return b, nil
+}
+
+// loadSecretFile sets s from the contents of file, which is resolved
+// relative to dir. Setting both the value and the file is an error.
+func loadSecretFile(s *Secret, file, dir, field string) error {
+	if file == "" {
+		return nil
+	}
+	if *s != "" {
+		return fmt.Errorf("at most one of %s and %s_file can be set", field, field)
+	}
+	b, err := os.ReadFile(secretPath(file, dir))
+	if err != nil {
+		return fmt.Errorf("%s_file: %w", field, err)
+	}
+	*s = Secret(strings.TrimSpace(string(b)))
+	return nil
+}
+
+func secretPath(file, dir string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
+
+// SecretFiles returns the *_file secrets of the config loaded from path, the
+// files a reload has to watch next to the config file itself.
+func (c *Config) SecretFiles(path string) []string {
+	files := []string{c.HTTPClient.ProxyURLFile}
+	for _, n := range c.Notifiers {
+		files = append(files, n.WebhookURLFile)
+	}
+	for _, u := range c.Users {
+		files = append(files, u.PasswordFile)
+	}
+	if c.Redis != nil {
+		files = append(files, c.Redis.PasswordFile)
+	}
+	if c.Alertmanager != nil {
+		files = append(files, c.Alertmanager.URLFile)
+	}
+	var paths []string
+	for _, f := range files {
+		if f != "" {
+			paths = append(paths, secretPath(f, filepath.Dir(path)))
+		}
+	}
+	return paths
+}
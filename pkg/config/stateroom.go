@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+// SuperCategoriesConfig exports the cheapest price of every stateroom super
+// category per sailing.
+type SuperCategoriesConfig struct {
+	// Classes maps stateroom class ids to interior, oceanview, balcony or
+	// suite, on top of the ids the exporter knows.
+	Classes map[string]string `yaml:"classes,omitempty"`
+}
+
+func (c *SuperCategoriesConfig) Validate() error {
+	for class, category := range c.Classes {
+		known := false
+		for _, k := range royalapi.SuperCategories {
+			known = known || k == category
+		}
+		if !known {
+			return fmt.Errorf("classes: unknown super category %q for %q", category, class)
+		}
+	}
+	return nil
+}
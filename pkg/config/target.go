@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Target is a GraphQL endpoint to scrape. Labels are attached to every
+// series exported for the target.
+type Target struct {
+	Name   string            `yaml:"name"`
+	URL    string            `yaml:"url"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+	// DailyRequestBudget caps the requests sent to the target per day,
+	// overriding -daily-request-budget. 0 means the flag applies.
+	DailyRequestBudget int `yaml:"daily_request_budget,omitempty"`
+	// PageSize, PageConcurrency and QueryFeatures override the flags of the
+	// same name for the target, 0 or empty means the flag applies.
+	PageSize        int    `yaml:"page_size,omitempty"`
+	PageConcurrency int    `yaml:"page_concurrency,omitempty"`
+	QueryFeatures   string `yaml:"query_features,omitempty"`
+	// Sort overrides the -sort flag for the target, one of royalapi.Sorts.
+	Sort string `yaml:"sort,omitempty"`
+	// Preset is one of Presets, light, full-catalog or stealth.
+	Preset string `yaml:"preset,omitempty"`
+	// Hosts pins host names to IP addresses for the connections to the
+	// target, like /etc/hosts, e.g. to stay on one CDN edge.
+	Hosts map[string]string `yaml:"hosts,omitempty"`
+	// IPVersion forces connections to the target over IPv4 or IPv6, 4 or 6.
+	// 0 means either.
+	IPVersion int `yaml:"ip_version,omitempty"`
+	// SourceAddress or Interface bind the connections to the target to a
+	// local IP address or the address of a network interface, overriding
+	// those of http_client.
+	SourceAddress string `yaml:"source_address,omitempty"`
+	Interface     string `yaml:"interface,omitempty"`
+}
+
+// Validate checks the target and defaults its name to the URL.
+func (t *Target) Validate() error {
+	if t.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if u, err := url.Parse(t.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid url %q", t.URL)
+	}
+	if t.Name == "" {
+		t.Name = t.URL
+	}
+	if err := t.applyPreset(); err != nil {
+		return err
+	}
+	if t.DailyRequestBudget < 0 {
+		return fmt.Errorf("daily_request_budget must not be negative")
+	}
+	// the smallest page size every target accepts, see the exporter
+	if t.PageSize != 0 && t.PageSize < 20 {
+		return fmt.Errorf("page_size must be at least 20")
+	}
+	if t.PageConcurrency < 0 {
+		return fmt.Errorf("page_concurrency must not be negative")
+	}
+	if _, err := royalapi.ParseFeatures(t.QueryFeatures); err != nil {
+		return fmt.Errorf("query_features: %w", err)
+	}
+	if t.Sort != "" {
+		if err := royalapi.ValidateSort(t.Sort); err != nil {
+			return fmt.Errorf("sort: %w", err)
+		}
+	}
+	if t.IPVersion != 0 && t.IPVersion != 4 && t.IPVersion != 6 {
+		return fmt.Errorf("ip_version must be 4 or 6")
+	}
+	if err := ValidateSource(t.SourceAddress, t.Interface); err != nil {
+		return err
+	}
+	if ip := net.ParseIP(t.SourceAddress); ip != nil && (t.IPVersion == 4 && ip.To4() == nil || t.IPVersion == 6 && ip.To4() != nil) {
+		return fmt.Errorf("source_address %s is not an IPv%d address", ip, t.IPVersion)
+	}
+	for host, ip := range t.Hosts {
+		addr := net.ParseIP(ip)
+		if addr == nil {
+			return fmt.Errorf("hosts: invalid IP address %q for %s", ip, host)
+		}
+		if t.IPVersion == 4 && addr.To4() == nil || t.IPVersion == 6 && addr.To4() != nil {
+			return fmt.Errorf("hosts: %s of %s is not an IPv%d address", ip, host, t.IPVersion)
+		}
+	}
+	for name := range t.Labels {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	return nil
+}
+
+// ValidateSource checks a source_address and interface, at most one of
+// which may be set.
+func ValidateSource(address, iface string) error {
+	if address != "" && iface != "" {
+		return fmt.Errorf("source_address and interface are mutually exclusive")
+	}
+	if address != "" && net.ParseIP(address) == nil {
+		return fmt.Errorf("invalid source_address %q", address)
+	}
+	return nil
+}
+
+// ValidateTargets validates every target and checks their names are unique.
+func ValidateTargets(targets []Target) error {
+	var errs Errors
+	seen := map[string]bool{}
+	for i := range targets {
+		if err := targets[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("targets[%d]: %w", i, err))
+		}
+		if seen[targets[i].Name] {
+			errs = append(errs, fmt.Errorf("targets[%d]: duplicate target name %q", i, targets[i].Name))
+		}
+		seen[targets[i].Name] = true
+	}
+	return errs.err()
+}
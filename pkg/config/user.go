@@ -0,0 +1,52 @@
+package config
+
+import "fmt"
+
+// UserConfig is someone sharing the exporter, with watches and notifiers of
+// their own. Users sign in to the watches API and the web UI with basic
+// auth.
+type UserConfig struct {
+	Name         string `yaml:"name"`
+	Password     Secret `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+	// Notify are the notifiers the watches of the user go to when they name
+	// none, and the only ones they may name.
+	Notify []string `yaml:"notify"`
+}
+
+func (u *UserConfig) loadSecrets(dir string) error {
+	return loadSecretFile(&u.Password, u.PasswordFile, dir, "password")
+}
+
+func (u *UserConfig) Validate() error {
+	if u.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if u.Password == "" {
+		return fmt.Errorf("password is required")
+	}
+	if len(u.Notify) == 0 {
+		// a watch without notifiers would notify everyone
+		return fmt.Errorf("notify is required")
+	}
+	return nil
+}
+
+// OwnWatch checks the watch only uses notifiers of the user, defaulting them
+// to those of the user.
+func (u *UserConfig) OwnWatch(w *WatchConfig) error {
+	if len(w.Notify) == 0 {
+		w.Notify = append([]string(nil), u.Notify...)
+		return nil
+	}
+	allowed := map[string]bool{}
+	for _, name := range u.Notify {
+		allowed[name] = true
+	}
+	for _, name := range w.Notify {
+		if !allowed[name] {
+			return fmt.Errorf("notifier %q is not one of user %s", name, u.Name)
+		}
+	}
+	return nil
+}
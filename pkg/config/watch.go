@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PollInterval is how often watched files are checked for changes.
+const PollInterval = 5 * time.Second
+
+// Watcher detects changes to the files matching a set of glob patterns by
+// comparing their size and modification time between calls. Files are
+// stat'ed through their symlinks, so the atomic symlink swaps Kubernetes uses
+// to update mounted ConfigMaps and Secrets are picked up.
+type Watcher struct {
+	patterns []string
+	last     string
+}
+
+func NewWatcher(patterns ...string) *Watcher {
+	w := &Watcher{patterns: patterns}
+	w.last = w.state()
+	return w
+}
+
+// Changed reports whether any matching file was added, removed or modified
+// since the previous call.
+func (w *Watcher) Changed() bool {
+	state := w.state()
+	changed := state != w.last
+	w.last = state
+	return changed
+}
+
+func (w *Watcher) state() string {
+	var entries []string
+	for _, p := range w.patterns {
+		files, _ := filepath.Glob(p)
+		for _, f := range files {
+			fi, err := os.Stat(f)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, f+":"+strconv.FormatInt(fi.Size(), 10)+":"+strconv.FormatInt(fi.ModTime().UnixNano(), 10))
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, "\n")
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+)
+
+// WatchConfig fires for every price series whose labels match and whose
+// price is below the threshold, e.g. a balcony on a given ship under 1500.
+type WatchConfig struct {
+	Name string `yaml:"name"`
+	// Match holds exact label values, see the royal_external_price labels.
+	Match map[string]string `yaml:"match,omitempty"`
+	// Itinerary and Product scope the watch to an itinerary code or a
+	// product id, the itinerary and cruiseid labels. When watches are
+	// scoped the exporter only searches for their products instead of
+	// paging through the whole catalog.
+	Itinerary string `yaml:"itinerary,omitempty"`
+	Product   string `yaml:"product,omitempty"`
+	// Below is the price threshold, 0 to fire whenever the series is priced.
+	Below float64 `yaml:"below,omitempty"`
+	// Condition must hold as well for the watch to fire, see
+	// SeriesVariables and WatchVariables for what it can refer to, e.g.
+	// price < 0.9 * min_observed && nights >= 7.
+	Condition *Expression `yaml:"condition,omitempty"`
+	Notify    []string    `yaml:"notify,omitempty"`
+	// Priority is low, normal or high, for routes and quiet hours.
+	Priority string `yaml:"priority,omitempty"`
+	// ResendInterval sends a reminder while the watch keeps firing, 0 only
+	// notifies when it starts.
+	ResendInterval time.Duration `yaml:"resend_interval,omitempty"`
+	// DedupWindow drops notifications about a price already notified for
+	// the same series within the window, so a flapping price notifies once.
+	DedupWindow time.Duration `yaml:"dedup_window,omitempty"`
+	// RateLimit caps the notifications of the watch over all series.
+	RateLimit *RateLimitConfig `yaml:"rate_limit,omitempty"`
+	// User owns the watch, see UserConfig. Watches without one are shared.
+	User string `yaml:"user,omitempty"`
+}
+
+func (w *WatchConfig) Validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(w.Match) == 0 && !w.Scoped() && w.Condition == nil {
+		return fmt.Errorf("match, itinerary, product or condition is required")
+	}
+	if strings.ContainsAny(w.Itinerary+w.Product, ",|") {
+		return fmt.Errorf("itinerary and product must not contain commas or pipes")
+	}
+	for name := range w.Match {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	if w.Below < 0 {
+		return fmt.Errorf("below must not be negative")
+	}
+	if err := notify.ValidatePriority(w.Priority); err != nil {
+		return err
+	}
+	if w.ResendInterval < 0 {
+		return fmt.Errorf("resend_interval must not be negative")
+	}
+	if w.DedupWindow < 0 {
+		return fmt.Errorf("dedup_window must not be negative")
+	}
+	if w.RateLimit != nil {
+		if err := w.RateLimit.Validate(); err != nil {
+			return fmt.Errorf("rate_limit: %w", err)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether labels include every label of the match.
+func (w *WatchConfig) Matches(labels map[string]string) bool {
+	if w.Itinerary != "" && labels["itinerary"] != w.Itinerary {
+		return false
+	}
+	if w.Product != "" && labels["cruiseid"] != w.Product {
+		return false
+	}
+	for k, v := range w.Match {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// WatchVariables describes the variables of watch conditions besides the
+// SeriesVariables.
+var WatchVariables = map[string]string{
+	"previous_price": "the price of the previous scrape, 0 for a new series",
+	"min_observed":   "the lowest price seen before, 0 for a new series",
+}
+
+// AlertmanagerConfig sends firing watches to an Alertmanager as alerts.
+type AlertmanagerConfig struct {
+	URL     Secret `yaml:"url"`
+	URLFile string `yaml:"url_file,omitempty"`
+	// ExternalURL is the base of the generatorURL when a sailing has no
+	// absolute booking link.
+	ExternalURL string        `yaml:"external_url,omitempty"`
+	Timeout     time.Duration `yaml:"timeout,omitempty"`
+}
+
+func (c *AlertmanagerConfig) loadSecrets(dir string) error {
+	return loadSecretFile(&c.URL, c.URLFile, dir, "url")
+}
+
+func (c *AlertmanagerConfig) Validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 10 * time.Second
+	}
+	return nil
+}
+
+// Scoped reports whether the watch names an itinerary or product.
+func (w *WatchConfig) Scoped() bool {
+	return w.Itinerary != "" || w.Product != ""
+}
+
+// LoadWatches reads watches saved with SaveWatches. A missing file holds no
+// watches.
+func LoadWatches(path string) ([]WatchConfig, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var watches []WatchConfig
+	if err := yaml.UnmarshalStrict(b, &watches); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for i := range watches {
+		if err := watches[i].Validate(); err != nil {
+			return nil, fmt.Errorf("%s: watches[%d]: %w", path, i, err)
+		}
+	}
+	return watches, nil
+}
+
+// SaveWatches replaces the watches saved at path, in the format of the
+// watches section of the config file.
+func SaveWatches(path string, watches []WatchConfig) error {
+	b, err := yaml.Marshal(watches)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindowConfig is a set of hours of the day, like the hours scraping is
+// allowed or the quiet hours of a notifier.
+type TimeWindowConfig struct {
+	// Timezone is an IANA name like Europe/London, the local one by default.
+	Timezone string `yaml:"timezone,omitempty"`
+	// Hours are ranges like 07:00-23:00, a range ending before it starts
+	// runs past midnight.
+	Hours []string `yaml:"hours"`
+}
+
+func (c *TimeWindowConfig) Validate() error {
+	if _, err := c.location(); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+	}
+	if len(c.Hours) == 0 {
+		return fmt.Errorf("hours is required")
+	}
+	for _, h := range c.Hours {
+		if _, _, err := parseHours(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *TimeWindowConfig) location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+// parseHours returns the range as minutes since midnight.
+func parseHours(h string) (from, to int, err error) {
+	parts := strings.Split(h, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("hours must be a range like 07:00-23:00, got %q", h)
+	}
+	var minutes [2]int
+	for i, p := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(p))
+		if err != nil {
+			return 0, 0, fmt.Errorf("hours must be a range like 07:00-23:00, got %q", h)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	return minutes[0], minutes[1], nil
+}
+
+// Allows reports whether now falls within one of the ranges.
+func (c *TimeWindowConfig) Allows(now time.Time) bool {
+	if loc, err := c.location(); err == nil {
+		now = now.In(loc)
+	}
+	m := now.Hour()*60 + now.Minute()
+	for _, h := range c.Hours {
+		from, to, err := parseHours(h)
+		if err != nil {
+			continue
+		}
+		if from <= to && m >= from && m < to {
+			return true
+		}
+		if from > to && (m >= from || m < to) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"gopkg.in/yaml.v2"
+)
+
+// Group is one entry of a file_sd file.
+type Group struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+// FileSD reads targets from the files matching the configured patterns and
+// re-reads them whenever they change.
+type FileSD struct {
+	cfg    config.FileSDConfig
+	logger *log.Logger
+	watch  *config.Watcher
+}
+
+func NewFileSD(cfg config.FileSDConfig, logger *log.Logger) *FileSD {
+	return &FileSD{cfg: cfg, logger: logger, watch: config.NewWatcher(cfg.Files...)}
+}
+
+// Run calls update with the full list of discovered targets at startup and
+// after every change, until ctx is cancelled. Files are checked for changes
+// every few seconds and fully re-read every refresh interval.
+func (d *FileSD) Run(ctx context.Context, update func([]config.Target)) {
+	var last []config.Target
+	refresh := func() {
+		targets := d.read()
+		if !equalTargets(last, targets) {
+			d.logger.Printf("file_sd: discovered %d targets", len(targets))
+			update(targets)
+			last = targets
+		}
+	}
+	refresh()
+
+	poll := time.NewTicker(config.PollInterval)
+	defer poll.Stop()
+	ticker := time.NewTicker(d.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-poll.C:
+			if d.watch.Changed() {
+				refresh()
+			}
+		case <-ticker.C:
+			refresh()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Targets reads the files once and returns the valid targets.
+func (d *FileSD) Targets() []config.Target {
+	return d.read()
+}
+
+// read returns the valid targets of all matching files. Invalid files are
+// logged and skipped so one bad file doesn't remove every target.
+func (d *FileSD) read() []config.Target {
+	var targets []config.Target
+	seen := map[string]bool{}
+	for _, p := range d.cfg.Files {
+		files, err := filepath.Glob(p)
+		if err != nil {
+			d.logger.Printf("file_sd: invalid pattern %s: %s", p, err)
+			continue
+		}
+		for _, f := range files {
+			groups, err := readFile(f)
+			if err != nil {
+				d.logger.Printf("file_sd: skipping %s: %s", f, err)
+				continue
+			}
+			for _, g := range groups {
+				for _, u := range g.Targets {
+					t := config.Target{Name: u, URL: u, Labels: g.Labels}
+					if err := t.Validate(); err != nil {
+						d.logger.Printf("file_sd: skipping target in %s: %s", f, err)
+						continue
+					}
+					if seen[t.Name] {
+						continue
+					}
+					seen[t.Name] = true
+					targets = append(targets, t)
+				}
+			}
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets
+}
+
+func readFile(name string) ([]Group, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var groups []Group
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		err = json.Unmarshal(b, &groups)
+	case ".yml", ".yaml":
+		err = yaml.UnmarshalStrict(b, &groups)
+	default:
+		err = fmt.Errorf("unsupported file extension")
+	}
+	return groups, err
+}
+
+func equalTargets(a, b []config.Target) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].URL != b[i].URL || len(a[i].Labels) != len(b[i].Labels) {
+			return false
+		}
+		for k, v := range a[i].Labels {
+			if b[i].Labels[k] != v {
+				return false
+			}
+		}
+	}
+	return true
+}
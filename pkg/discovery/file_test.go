@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func TestTargets(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "carib.json", `[{"targets":["https://b.example/graph","https://a.example/graph"],"labels":{"region":"carib"}}]`)
+	writeFile(t, dir, "alaska.yml", "- targets: [https://c.example/graph, https://a.example/graph, not a url]\n  labels: {region: alaska}\n")
+	writeFile(t, dir, "broken.json", `{"targets":`)
+	writeFile(t, dir, "notes.txt", "not a target file")
+
+	var logs bytes.Buffer
+	d := NewFileSD(config.FileSDConfig{Files: []string{filepath.Join(dir, "*")}}, log.New(&logs, "", 0))
+	targets := d.Targets()
+
+	var names []string
+	for _, tg := range targets {
+		names = append(names, tg.Name)
+	}
+	assert.Equal(t, []string{"https://a.example/graph", "https://b.example/graph", "https://c.example/graph"}, names, "sorted, with the first file listing a target winning")
+	assert.Equal(t, map[string]string{"region": "alaska"}, targets[0].Labels)
+	assert.Contains(t, logs.String(), "skipping target in "+filepath.Join(dir, "alaska.yml"))
+	assert.Contains(t, logs.String(), "skipping "+filepath.Join(dir, "broken.json"))
+	assert.Contains(t, logs.String(), "skipping "+filepath.Join(dir, "notes.txt")+": unsupported file extension")
+}
+
+func TestRunUpdatesOnChange(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "targets.json", `[{"targets":["https://a.example/graph"]}]`)
+	d := NewFileSD(config.FileSDConfig{Files: []string{filepath.Join(dir, "*.json")}, RefreshInterval: time.Hour}, log.New(&bytes.Buffer{}, "", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan []config.Target, 4)
+	go d.Run(ctx, func(targets []config.Target) { updates <- targets })
+
+	first := <-updates
+	require.Len(t, first, 1)
+
+	writeFile(t, dir, "more.json", `[{"targets":["https://b.example/graph"]}]`)
+	select {
+	case second := <-updates:
+		assert.Len(t, second, 2)
+	case <-time.After(3 * config.PollInterval):
+		t.Fatal("no update after adding a file")
+	}
+}
+
+func TestEqualTargets(t *testing.T) {
+	a := []config.Target{{Name: "a", URL: "https://a.example", Labels: map[string]string{"region": "carib"}}}
+	b := []config.Target{{Name: "a", URL: "https://a.example", Labels: map[string]string{"region": "alaska"}}}
+	assert.True(t, equalTargets(a, a))
+	assert.False(t, equalTargets(a, b))
+	assert.False(t, equalTargets(a, nil))
+}
@@ -0,0 +1,73 @@
+package exporter
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (hc *Exporter) checkAnomaly(labels prometheus.Labels, past []history.Sample, price float64) error {
+	if len(past) < hc.anomaly.MinSamples {
+		return nil
+	}
+	reason := anomalyReason(past, price, hc.anomaly.ZScore, hc.anomaly.PercentChange)
+	value := 0.0
+	if reason != "" {
+		value = 1
+	}
+	if err := hc.priceAnomaly.set(labels, value); err != nil {
+		return err
+	}
+
+	key := history.Key(labels)
+	hc.anomalousMu.Lock()
+	was := hc.anomalous[key]
+	if reason != "" {
+		hc.anomalous[key] = true
+	} else {
+		delete(hc.anomalous, key)
+	}
+	hc.anomalousMu.Unlock()
+
+	if reason != "" && !was {
+		hc.logger.Printf("price anomaly for %s %s %s: %s", labels["ship"], labels["datelabel"], labels["stateroomclass"], reason)
+		hc.notify(hc.anomaly.Notify, notify.Event{
+			Kind:          "price_anomaly",
+			Title:         fmt.Sprintf("Price anomaly on %s sailing %s", labels["ship"], labels["datelabel"]),
+			Text:          fmt.Sprintf("Stateroom class %s is now %.0f: %s", labels["stateroomclass"], price, reason),
+			Labels:        labels,
+			Price:         price,
+			PreviousPrice: past[len(past)-1].Value,
+		})
+	}
+	return nil
+}
+
+// anomalyReason explains why price is anomalous compared to past, or
+// returns "" when it isn't.
+func anomalyReason(past []history.Sample, price, zScore, percentChange float64) string {
+	if percentChange > 0 {
+		last := past[len(past)-1].Value
+		if change := (price - last) / last * 100; last > 0 && math.Abs(change) >= percentChange {
+			return fmt.Sprintf("%+.1f%% since the previous price of %.0f", change, last)
+		}
+	}
+	if zScore > 0 {
+		var sum, sq float64
+		for _, s := range past {
+			sum += s.Value
+		}
+		mean := sum / float64(len(past))
+		for _, s := range past {
+			sq += (s.Value - mean) * (s.Value - mean)
+		}
+		stddev := math.Sqrt(sq / float64(len(past)))
+		if z := (price - mean) / stddev; stddev > 0 && math.Abs(z) >= zScore {
+			return fmt.Sprintf("%.1f standard deviations from the mean of %.0f", z, mean)
+		}
+	}
+	return ""
+}
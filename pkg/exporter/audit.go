@@ -0,0 +1,151 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+)
+
+// Audited actions.
+const (
+	AuditNotificationSent   = "notification_sent"
+	AuditNotificationFailed = "notification_failed"
+	AuditConfigReload       = "config_reload"
+	AuditWatchAdded         = "watch_added"
+	AuditWatchDeleted       = "watch_deleted"
+)
+
+// AuditEntry is a line of the audit log.
+type AuditEntry struct {
+	Time    time.Time         `json:"time"`
+	Action  string            `json:"action"`
+	User    string            `json:"user,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// auditLog appends the entries as JSON lines to a file.
+type auditLog struct {
+	mu   sync.Mutex
+	file string
+}
+
+func (a *auditLog) append(e AuditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// read returns the entries accepted by keep, oldest first.
+func (a *auditLog) read(keep func(AuditEntry) bool) ([]AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.Open(a.file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if keep(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Audit records an action in the audit log, if any. user is whoever took it,
+// empty for the exporter itself.
+func (hc *Exporter) Audit(action, user string, details map[string]string) {
+	if hc.audit == nil {
+		return
+	}
+	err := hc.audit.append(AuditEntry{Time: time.Now().UTC(), Action: action, User: user, Details: details})
+	if err != nil {
+		hc.logger.Printf("error writing audit log: %s", err)
+	}
+}
+
+func (hc *Exporter) auditDelivery(channel string, e notify.Event, err error) {
+	details := map[string]string{"notifier": channel, "kind": e.Kind, "title": e.Title}
+	if e.Rule != "" {
+		details["rule"] = e.Rule
+	}
+	action := AuditNotificationSent
+	if err != nil {
+		action = AuditNotificationFailed
+		details["error"] = err.Error()
+	}
+	hc.Audit(action, e.User, details)
+}
+
+// serveAudit lists the audit log, filtered by the action and since
+// parameters and limited to the last limit entries, 100 by default. Signed
+// in users only see their own entries.
+func (hc *Exporter) serveAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var since time.Time
+	if s := q.Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 100
+	if s := q.Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+			http.Error(w, "invalid limit "+strconv.Quote(s), http.StatusBadRequest)
+			return
+		}
+	}
+	action := q.Get("action")
+	u := userOf(r)
+	entries, err := hc.audit.read(func(e AuditEntry) bool {
+		return (action == "" || e.Action == action) &&
+			!e.Time.Before(since) &&
+			(u == nil || e.User == u.Name)
+	})
+	if err != nil {
+		hc.logger.Printf("error reading audit log: %s", err)
+		http.Error(w, "error reading audit log", http.StatusInternalServerError)
+		return
+	}
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Entries []AuditEntry `json:"entries"`
+	}{entries})
+}
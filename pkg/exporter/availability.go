@@ -0,0 +1,44 @@
+package exporter
+
+import (
+	"fmt"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+// checkAvailability notifies about stateroom classes of watched sailings
+// that were sold out in the previous scrape and are priced again. Released
+// inventory is often briefly cheap.
+func (hc *Exporter) checkAvailability(t config.Target, c royalapi.Cruise, s royalapi.Sailing, old sailingMeta) {
+	for _, p := range s.StateroomClassPricing {
+		class := p.StateroomClass.ID
+		if p.Price.Value <= 0 || !old.classes[class] || old.priced[class] {
+			continue
+		}
+		hc.stateroomsReleased.WithLabelValues(t.Name).Inc()
+		labels := hc.newPriceMetric(t, c, s, p).priceLabels()
+		watches, limits := hc.currentWatchLimits()
+		for i := range watches {
+			w := &watches[i]
+			if !w.Matches(labels) {
+				continue
+			}
+			text := fmt.Sprintf("Stateroom class %s of %s sailing %s is available again at %d", class, labels["ship"], s.SailDate, p.Price.Value)
+			hc.logger.Printf("watch %s: %s", w.Name, text)
+			key := fmt.Sprintf("available\x00%s\x00%s\x00%d", w.Name, history.Key(labels), p.Price.Value)
+			hc.notifyWatch(w, limits[w.Name], key, notify.Event{
+				Kind:     "stateroom_available",
+				Rule:     w.Name,
+				Title:    fmt.Sprintf("%s: %s back on sale on %s sailing %s", w.Name, class, labels["ship"], s.SailDate),
+				Text:     text,
+				Priority: notify.PriorityHigh,
+				Labels:   labels,
+				URL:      hc.absoluteLink(s.BookingLink),
+				Price:    float64(p.Price.Value),
+			})
+		}
+	}
+}
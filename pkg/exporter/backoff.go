@@ -0,0 +1,62 @@
+package exporter
+
+import (
+	"time"
+)
+
+// backingOff reports whether the cycle due now is skipped because the
+// previous ones all failed.
+func (hc *Exporter) backingOff(now time.Time) bool {
+	hc.backoffMu.Lock()
+	defer hc.backoffMu.Unlock()
+	return now.Before(hc.nextCycle)
+}
+
+// recordCycle doubles the interval between cycles, up to maxBackoff, for
+// every cycle in a row in which every scraped target failed, and goes back to
+// the configured interval after the first cycle that got data.
+func (hc *Exporter) recordCycle(report ScrapeReport, now time.Time) {
+	if hc.maxBackoff <= 0 {
+		return
+	}
+	scraped, failed := 0, 0
+	for _, t := range report.Targets {
+		if t.Skipped {
+			continue
+		}
+		scraped++
+		if t.Error != "" {
+			failed++
+		}
+	}
+	if scraped == 0 {
+		return
+	}
+
+	hc.backoffMu.Lock()
+	defer hc.backoffMu.Unlock()
+	if failed < scraped {
+		if hc.failedCycles > 0 {
+			hc.logger.Printf("scrapes succeed again, back to an interval of %s", hc.healthcheck_invertval)
+		}
+		hc.failedCycles = 0
+		hc.nextCycle = time.Time{}
+		hc.effectiveInterval.Set(hc.healthcheck_invertval.Seconds())
+		return
+	}
+	hc.failedCycles++
+	interval := hc.healthcheck_invertval
+	for i := 0; i < hc.failedCycles && interval < hc.maxBackoff; i++ {
+		interval *= 2
+	}
+	if interval > hc.maxBackoff {
+		interval = hc.maxBackoff
+	}
+	if interval < hc.healthcheck_invertval {
+		interval = hc.healthcheck_invertval
+	}
+	hc.logger.Printf("every target failed %d cycles in a row, backing off to an interval of %s", hc.failedCycles, interval)
+	// the ticker keeps its pace, cycles due before then are skipped
+	hc.nextCycle = report.Start.Add(interval).Add(-hc.healthcheck_invertval / 2)
+	hc.effectiveInterval.Set(interval.Seconds())
+}
@@ -0,0 +1,119 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exportertest"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// benchPageSize is the page size of a full-catalog scrape.
+const benchPageSize = 100
+
+// benchCruises returns n cruises of four sailings priced in three stateroom
+// classes each.
+func benchCruises(n int) []exportertest.Cruise {
+	ships := []struct{ name, code string }{{"Wonder of the Seas", "WN"}, {"Icon of the Seas", "IC"}, {"Oasis of the Seas", "OA"}}
+	cruises := make([]exportertest.Cruise, n)
+	for i := range cruises {
+		ship := ships[i%len(ships)]
+		itinerary := fmt.Sprintf("%s07W%03d", ship.code, i)
+		c := exportertest.Cruise{
+			ID:            fmt.Sprintf("%s07RCI-%d", ship.code, i),
+			Ship:          ship.name,
+			ShipCode:      ship.code,
+			DeparturePort: "Port Canaveral",
+			Destination:   "CARIB",
+			Nights:        7,
+			Taxes:         163.12,
+		}
+		for j := 0; j < 4; j++ {
+			c.Sailings = append(c.Sailings, exportertest.Sailing{
+				ID:        fmt.Sprintf("%s-%d", c.ID, j),
+				Itinerary: itinerary,
+				SailDate:  fmt.Sprintf("2036-%02d-%02d", j%12+1, i%28+1),
+				Prices:    map[string]int{"I": 899 + i, "O": 1049 + i, "B": 1349 + i},
+			})
+		}
+		cruises[i] = c
+	}
+	return cruises
+}
+
+func benchExporter(b *testing.B, opts ...Option) *Exporter {
+	b.Helper()
+	opts = append([]Option{
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithLogger(log.New(io.Discard, "", 0)),
+	}, opts...)
+	e, err := NewExporter(context.Background(), opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return e
+}
+
+func BenchmarkDecodePage(b *testing.B) {
+	body, err := json.Marshal(exportertest.Response(1000, benchCruises(benchPageSize)...))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := royalapi.Parse(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFetchPage(b *testing.B) {
+	srv := exportertest.NewServer(benchCruises(benchPageSize)...)
+	defer srv.Close()
+	t := config.Target{Name: "bench", URL: srv.URL}
+	e := benchExporter(b, WithTargets(t))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, err := e.fetchPage(context.Background(), t, "", 0, benchPageSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(data.Cruises()) != benchPageSize {
+			b.Fatalf("got %d cruises", len(data.Cruises()))
+		}
+	}
+}
+
+func BenchmarkExportCruises(b *testing.B) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(exportertest.Response(1000, benchCruises(benchPageSize)...)); err != nil {
+		b.Fatal(err)
+	}
+	data, err := royalapi.Parse(buf.Bytes())
+	if err != nil {
+		b.Fatal(err)
+	}
+	t := config.Target{Name: "bench", URL: "http://bench.invalid"}
+	e := benchExporter(b, WithTargets(t))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := e.exportCruises(t, data.Cruises(), newScrapeState(), &TargetReport{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
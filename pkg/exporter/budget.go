@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"errors"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+)
+
+var errBudgetExhausted = errors.New("daily request budget exhausted")
+
+// budgetWindow counts the requests sent to a target on one day.
+type budgetWindow struct {
+	day  time.Time
+	used int
+}
+
+// dailyBudget is the number of requests the target may receive per day, 0
+// for no limit.
+func (hc *Exporter) dailyBudget(t config.Target) int {
+	if t.DailyRequestBudget > 0 {
+		return t.DailyRequestBudget
+	}
+	return hc.requestBudget
+}
+
+// window returns the budget window of the target for today, starting a new
+// one at midnight. hc.budgetsMu must be held.
+func (hc *Exporter) window(t config.Target, now time.Time) *budgetWindow {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	w, ok := hc.budgets[t.Name]
+	if !ok || !w.day.Equal(today) {
+		w = &budgetWindow{day: today}
+		hc.budgets[t.Name] = w
+	}
+	return w
+}
+
+// takeRequest uses up one request of the budget of the target, returning
+// errBudgetExhausted when none is left.
+func (hc *Exporter) takeRequest(t config.Target) error {
+	budget := hc.dailyBudget(t)
+	if budget == 0 {
+		return nil
+	}
+	hc.budgetsMu.Lock()
+	defer hc.budgetsMu.Unlock()
+	w := hc.window(t, time.Now())
+	if w.used >= budget {
+		return errBudgetExhausted
+	}
+	w.used++
+	hc.budgetRemaining.WithLabelValues(t.Name).Set(float64(budget - w.used))
+	return nil
+}
+
+// budgetExhausted reports whether the target has no requests left today and
+// when the budget resets.
+func (hc *Exporter) budgetExhausted(t config.Target) (bool, time.Time) {
+	budget := hc.dailyBudget(t)
+	if budget == 0 {
+		return false, time.Time{}
+	}
+	hc.budgetsMu.Lock()
+	defer hc.budgetsMu.Unlock()
+	w := hc.window(t, time.Now())
+	hc.budgetRemaining.WithLabelValues(t.Name).Set(float64(budget - w.used))
+	return w.used >= budget, w.day.AddDate(0, 0, 1)
+}
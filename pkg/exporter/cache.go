@@ -0,0 +1,61 @@
+package exporter
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+// pageCache holds parsed pages until they expire. A nil cache stores nothing.
+type pageCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cachedPage
+}
+
+type cachedPage struct {
+	data    *royalapi.Response
+	expires time.Time
+}
+
+func newPageCache(ttl time.Duration) *pageCache {
+	return &pageCache{ttl: ttl, entries: map[string]cachedPage{}}
+}
+
+// pageKey identifies a page by the target it came from and the variables
+// of the query.
+func pageKey(t config.Target, v royalapi.Variables) string {
+	b, _ := json.Marshal(v)
+	return t.URL + "\x00" + string(b)
+}
+
+func (c *pageCache) get(key string) (*royalapi.Response, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.data, true
+}
+
+func (c *pageCache) put(key string, data *royalapi.Response) {
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedPage{data: data, expires: now.Add(c.ttl)}
+}
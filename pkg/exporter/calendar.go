@@ -0,0 +1,30 @@
+package exporter
+
+import (
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/calendar"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tagHolidays exports an info series for every holiday the sailing
+// overlaps, to be joined with royal_external_price on cruiseid and datelabel.
+func (hc *Exporter) tagHolidays(t config.Target, c royalapi.Cruise, s royalapi.Sailing) error {
+	if hc.calendar == nil {
+		return nil
+	}
+	start, end, ok := calendar.SailingDates(s.SailDate, s.StartDate, s.EndDate, c.MasterSailing.Itinerary.TotalNights)
+	if !ok {
+		return nil
+	}
+	for _, holiday := range hc.calendar.Holidays(start, end) {
+		labels := prometheus.Labels{"url": t.URL, "cruiseid": c.ID, "datelabel": hc.dateLabel(s.SailDate), "holiday": holiday}
+		for k, v := range hc.targetLabels(t) {
+			labels[k] = v
+		}
+		if err := hc.holidayInfo.set(labels, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
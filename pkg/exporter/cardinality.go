@@ -0,0 +1,84 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// activeSeries reports the number of series every royal_external metric
+// holds when collected.
+type activeSeries struct {
+	desc   *prometheus.Desc
+	guards []*seriesGuard
+}
+
+func newActiveSeries() *activeSeries {
+	return &activeSeries{
+		desc: prometheus.NewDesc("royal_exporter_active_series",
+			"Number of series the metric currently holds, to watch cardinality grow.",
+			[]string{"metric"}, nil),
+	}
+}
+
+func (a *activeSeries) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.desc
+}
+
+func (a *activeSeries) Collect(ch chan<- prometheus.Metric) {
+	for _, g := range a.guards {
+		g.mu.Lock()
+		n := len(g.series)
+		g.mu.Unlock()
+		ch <- prometheus.MustNewConstMetric(a.desc, prometheus.GaugeValue, float64(n), g.name)
+	}
+}
+
+// expireSeries removes the series of every metric that weren't set by any of
+// the last seriesTTL cycles that scraped their target, whether or not the
+// target is still scraped. Cycles that skipped the target, and time spent
+// outside the scrape window or backing off, don't age its series. Series of
+// targets gone, or without a url label, age with every cycle that scraped.
+func (hc *Exporter) expireSeries(report ScrapeReport) {
+	if hc.seriesTTL <= 0 {
+		return
+	}
+	current := map[string]bool{"": true}
+	for _, t := range hc.currentTargets() {
+		current[t.URL] = true
+	}
+	hc.scrapeStartsMu.Lock()
+	if hc.scrapeStarts == nil {
+		hc.scrapeStarts = map[string][]time.Time{}
+	}
+	for _, t := range report.Targets {
+		if !t.Skipped {
+			hc.scrapeStarts[t.URL] = append(hc.scrapeStarts[t.URL], report.Start)
+		}
+	}
+	hc.scrapeStarts[""] = append(hc.scrapeStarts[""], report.Start)
+	// targets without seriesTTL scrapes yet keep their series
+	cutoffs := make(map[string]time.Time, len(current))
+	for url := range current {
+		cutoffs[url] = time.Time{}
+	}
+	for url, starts := range hc.scrapeStarts {
+		if !current[url] {
+			delete(hc.scrapeStarts, url)
+			continue
+		}
+		if len(starts) >= hc.seriesTTL {
+			starts = starts[len(starts)-hc.seriesTTL:]
+			hc.scrapeStarts[url] = starts
+			cutoffs[url] = starts[0]
+		}
+	}
+	hc.scrapeStartsMu.Unlock()
+
+	for _, g := range hc.activeSeries.guards {
+		if n := g.expire(cutoffs); n > 0 {
+			hc.logger.Printf("expired %d series of %s not updated for %d scrapes", n, g.name, hc.seriesTTL)
+			hc.seriesExpired.WithLabelValues(g.name).Add(float64(n))
+		}
+	}
+}
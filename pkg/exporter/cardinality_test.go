@@ -0,0 +1,48 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpireSeriesCountsScrapesOfTheTarget(t *testing.T) {
+	e, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithTargets(config.Target{Name: "a", URL: "http://a.invalid"}, config.Target{Name: "b", URL: "http://b.invalid"}),
+		WithSeriesTTL(2),
+	)
+	require.NoError(t, err)
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "g"}, []string{"url"})
+	g := newSeriesGuard("g", vec, nil, e.seriesDropped, nil)
+	e.activeSeries.guards = []*seriesGuard{g}
+
+	// last set before a day outside the scrape window
+	for _, url := range []string{"http://a.invalid", "http://b.invalid", "http://gone.invalid"} {
+		require.NoError(t, g.set(prometheus.Labels{"url": url}, 899))
+		g.series[seriesKey(prometheus.Labels{"url": url})].updated = time.Now().Add(-24 * time.Hour)
+	}
+	cycle := func() ScrapeReport {
+		return ScrapeReport{Start: time.Now(), Targets: []TargetReport{
+			{Name: "a", URL: "http://a.invalid"},
+			{Name: "b", URL: "http://b.invalid", Skipped: true},
+		}}
+	}
+
+	e.expireSeries(cycle())
+	assert.Len(t, g.series, 3, "a single scrape must not expire series of a TTL of 2")
+
+	e.expireSeries(cycle())
+	var left []string
+	for _, s := range g.series {
+		left = append(left, s.labels["url"])
+	}
+	assert.Equal(t, []string{"http://b.invalid"}, left, "cycles skipping b must not age its series")
+}
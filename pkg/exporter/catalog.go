@@ -0,0 +1,130 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/redis"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+// catalog is the parsed result of a complete scrape of one target as shared
+// between replicas.
+type catalog struct {
+	Scraped time.Time         `json:"scraped"`
+	Timing  catalogTiming     `json:"timing"`
+	Cruises []royalapi.Cruise `json:"cruises"`
+}
+
+type catalogTiming struct {
+	DNSMS       float64 `json:"dns_ms"`
+	ConnectMS   float64 `json:"connect_ms"`
+	FirstbyteMS float64 `json:"firstbyte_ms"`
+	TotalMS     float64 `json:"total_ms"`
+	Status      float64 `json:"status"`
+}
+
+// milliseconds converts d to the timing of a catalog, which keeps its
+// fraction of a millisecond.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func fromMilliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+func (hc *Exporter) catalogKey(t config.Target) string {
+	return hc.catalogPrefix + "catalog:" + t.Name
+}
+
+// saveCatalog shares the catalog of a complete scrape with the other replicas.
+func (hc *Exporter) saveCatalog(t config.Target, st *scrapeState) {
+	if hc.catalogs == nil {
+		return
+	}
+	c := catalog{
+		Scraped: time.Now(),
+		Timing: catalogTiming{
+			DNSMS:       milliseconds(st.timing.dns),
+			ConnectMS:   milliseconds(st.timing.connect),
+			FirstbyteMS: milliseconds(st.timing.firstbyte),
+			TotalMS:     milliseconds(st.timing.total),
+			Status:      st.timing.status,
+		},
+		Cruises: st.cruises,
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		hc.logger.Printf("error encoding catalog of %s: %s", t.Name, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(hc.ctx, 10*time.Second)
+	defer cancel()
+	if err := hc.catalogs.Set(ctx, hc.catalogKey(t), string(data), 0); err != nil {
+		hc.logger.Printf("error saving catalog of %s: %s", t.Name, err)
+		return
+	}
+	hc.catalogMu.Lock()
+	hc.catalogApplied[t.Name] = c.Scraped
+	hc.catalogMu.Unlock()
+}
+
+// syncCatalog exports the catalog the leader shared for the target, unless it
+// was already exported.
+func (hc *Exporter) syncCatalog(t config.Target) (report TargetReport) {
+	report = TargetReport{Name: t.Name, URL: t.URL}
+	defer func(began time.Time) {
+		report.DurationSeconds = time.Since(began).Seconds()
+	}(time.Now())
+
+	ctx, cancel := context.WithTimeout(hc.ctx, 10*time.Second)
+	defer cancel()
+	data, err := hc.catalogs.Get(ctx, hc.catalogKey(t))
+	if errors.Is(err, redis.ErrNil) {
+		return report
+	}
+	if err != nil {
+		hc.logger.Printf("error loading catalog of %s: %s", t.Name, err)
+		report.Error = err.Error()
+		return report
+	}
+	var c catalog
+	if err := json.Unmarshal([]byte(data), &c); err != nil {
+		hc.logger.Printf("error decoding catalog of %s: %s", t.Name, err)
+		report.Error = err.Error()
+		return report
+	}
+
+	hc.catalogMu.Lock()
+	applied := hc.catalogApplied[t.Name]
+	hc.catalogMu.Unlock()
+	if !c.Scraped.After(applied) {
+		return report
+	}
+
+	st := newScrapeState()
+	st.timing = urlTiming{
+		dns:       fromMilliseconds(c.Timing.DNSMS),
+		connect:   fromMilliseconds(c.Timing.ConnectMS),
+		firstbyte: fromMilliseconds(c.Timing.FirstbyteMS),
+		total:     fromMilliseconds(c.Timing.TotalMS),
+		status:    c.Timing.Status,
+	}
+	if err := hc.exportCruises(t, c.Cruises, st, &report); err != nil {
+		hc.logger.Printf("refusing catalog of %s: %s", t.Name, err)
+		report.Error = err.Error()
+		return report
+	}
+	hc.scrapePartial.WithLabelValues(t.Name).Set(0)
+	hc.finishScrape(t, st, &report)
+
+	hc.catalogMu.Lock()
+	hc.catalogApplied[t.Name] = c.Scraped
+	hc.catalogMu.Unlock()
+	hc.logger.Printf("applied catalog of %s scraped at %s", t.Name, c.Scraped.Format(time.RFC3339))
+	return report
+}
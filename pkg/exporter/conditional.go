@@ -0,0 +1,60 @@
+package exporter
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+var errNotModified = errors.New("not modified")
+
+// validator is what the target sent to revalidate a page with, kept with
+// the page it parsed to.
+type validator struct {
+	etag         string
+	lastModified string
+	data         *royalapi.Response
+}
+
+// setHeaders makes req conditional on the page being changed.
+func (v *validator) setHeaders(req *http.Request) {
+	if v == nil {
+		return
+	}
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+}
+
+// validatorCache keeps the latest page of every key the target sent an
+// ETag or Last-Modified header for.
+type validatorCache struct {
+	mu      sync.Mutex
+	entries map[string]*validator
+}
+
+func newValidatorCache() *validatorCache {
+	return &validatorCache{entries: map[string]*validator{}}
+}
+
+func (c *validatorCache) get(key string) *validator {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key]
+}
+
+func (c *validatorCache) put(key string, header http.Header, data *royalapi.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v := &validator{etag: header.Get("ETag"), lastModified: header.Get("Last-Modified"), data: data}
+	if v.etag == "" && v.lastModified == "" {
+		delete(c.entries, key)
+		return
+	}
+	c.entries[key] = v
+}
@@ -0,0 +1,27 @@
+package exporter
+
+import "github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+
+// coverage lists the results counted per kind of item of a scrape, every one
+// of which is exported so a result showing up is a change of its value
+// rather than a new series.
+var coverage = map[string][]string{
+	"cruise":    {"exported", "duplicate", "missing_fields"},
+	"sailing":   {"exported", "missing_fields"},
+	"stateroom": {"exported", "duplicate", "zero_price", "missing_fields"},
+}
+
+func (st *scrapeState) count(kind, result string, n int) {
+	st.coverage[[2]string{kind, result}] += n
+}
+
+// exportCoverage exports how many items of a complete scrape were exported
+// or skipped, a data-quality regression upstream shows up as a shift from
+// exported to one of the skipped results.
+func (hc *Exporter) exportCoverage(t config.Target, st *scrapeState) {
+	for kind, results := range coverage {
+		for _, result := range results {
+			hc.parseCoverage.WithLabelValues(t.Name, kind, result).Set(float64(st.coverage[[2]string{kind, result}]))
+		}
+	}
+}
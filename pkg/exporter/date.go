@@ -0,0 +1,40 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/calendar"
+)
+
+// dateLabel formats a sail date for the datelabel label, verbatim when no
+// layout is set or the date doesn't parse.
+func (hc *Exporter) dateLabel(sailDate string) string {
+	if hc.dateLayout == "" {
+		return sailDate
+	}
+	t, ok := calendar.ParseSailDate(sailDate, hc.dateLocation)
+	if !ok {
+		return sailDate
+	}
+	return t.Format(hc.dateLayout)
+}
+
+// departureDay returns the day of the week of a sail date such as
+// "Saturday" in the configured time zone, or "" when the date doesn't parse.
+func (hc *Exporter) departureDay(sailDate string) string {
+	t, ok := calendar.ParseSailDate(sailDate, hc.dateLocation)
+	if !ok {
+		return ""
+	}
+	return t.Weekday().String()
+}
+
+// parseDateLabel parses a datelabel label back into the sail date.
+func (hc *Exporter) parseDateLabel(label string) (time.Time, bool) {
+	if hc.dateLayout != "" {
+		if t, err := time.ParseInLocation(hc.dateLayout, label, hc.dateLocation); err == nil {
+			return t, true
+		}
+	}
+	return calendar.ParseSailDate(label, hc.dateLocation)
+}
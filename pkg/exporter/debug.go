@@ -0,0 +1,90 @@
+package exporter
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type rawResponse struct {
+	received  time.Time
+	requestID string
+	status    int
+	skip      int
+	body      []byte
+}
+
+// responseRing keeps the last size raw response bodies for every target.
+type responseRing struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string][]rawResponse
+}
+
+func newResponseRing(size int) *responseRing {
+	return &responseRing{size: size, entries: map[string][]rawResponse{}}
+}
+
+func (r *responseRing) add(target string, resp rawResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := append(r.entries[target], resp)
+	if len(entries) > r.size {
+		entries = entries[len(entries)-r.size:]
+	}
+	r.entries[target] = entries
+}
+
+// get returns the response index positions back from the newest one.
+func (r *responseRing) get(target string, index int) (rawResponse, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.entries[target]
+	if index < 0 || index >= len(entries) {
+		return rawResponse{}, false
+	}
+	return entries[len(entries)-1-index], true
+}
+
+func (hc *Exporter) serveLastResponse(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	token := hc.debugToken.Load().(string)
+	if token == "" || !strings.HasPrefix(auth, "Bearer ") || subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	target := r.URL.Query().Get("target")
+	if targets := hc.currentTargets(); target == "" && len(targets) == 1 {
+		target = targets[0].Name
+	}
+	index := 0
+	if i := r.URL.Query().Get("index"); i != "" {
+		var err error
+		if index, err = strconv.Atoi(i); err != nil {
+			http.Error(w, "invalid index", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, ok := hc.responses.get(target, index)
+	if !ok {
+		http.Error(w, "no response recorded for target", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Response-Time", resp.received.Format(time.RFC3339))
+	w.Header().Set("X-Response-Status", strconv.Itoa(resp.status))
+	w.Header().Set("X-Response-Skip", strconv.Itoa(resp.skip))
+	w.Header().Set(requestIDHeader, resp.requestID)
+	w.Write(resp.body)
+}
+
+// SetDebugToken replaces the token required by /debug/last-response, e.g.
+// after a mounted Secret changed.
+func (hc *Exporter) SetDebugToken(token string) {
+	hc.debugToken.Store(token)
+}
@@ -0,0 +1,38 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastResponseRequiresBearerToken(t *testing.T) {
+	mux := http.NewServeMux()
+	_, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(mux),
+		WithDebug("s3cret", 2),
+	)
+	require.NoError(t, err)
+
+	for auth, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Basic s3cret":  http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusNotFound,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/debug/last-response?target=carib", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		assert.Equal(t, want, rec.Code, "Authorization: %s", auth)
+	}
+}
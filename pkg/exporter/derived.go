@@ -0,0 +1,64 @@
+package exporter
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// derivedMetric is a gauge computed from the price series, see
+// config.DerivedMetricConfig.
+type derivedMetric struct {
+	config.DerivedMetricConfig
+	guard  *seriesGuard
+	failed sync.Once
+}
+
+// seriesVars returns the config.SeriesVariables of a price series, with its
+// labels. The sail date ones are left out when the datelabel doesn't parse.
+func (hc *Exporter) seriesVars(labels map[string]string, price float64, now time.Time) map[string]interface{} {
+	vars := make(map[string]interface{}, len(labels)+len(config.SeriesVariables)+len(config.WatchVariables))
+	for k, v := range labels {
+		vars[k] = v
+	}
+	vars["price"] = price
+	guests := 2
+	if hc.cabinGuests > 0 {
+		guests = hc.cabinGuests
+	}
+	vars["guests"] = float64(guests)
+	if nights, err := strconv.ParseFloat(labels["days"], 64); err == nil {
+		vars["nights"] = nights
+	}
+	if t, ok := hc.parseDateLabel(labels["datelabel"]); ok {
+		vars["sail_date"] = t.Format("2006-01-02")
+		vars["days_to_sail"] = math.Floor(t.Sub(now).Hours() / 24)
+	}
+	return vars
+}
+
+// exportDerived sets the derived metrics of a price series. A value failing
+// to evaluate leaves the series out and is logged once per metric.
+func (hc *Exporter) exportDerived(labels prometheus.Labels, price float64) error {
+	if len(hc.derived) == 0 {
+		return nil
+	}
+	vars := hc.seriesVars(labels, price, time.Now())
+	for _, d := range hc.derived {
+		v, err := d.Value.Number(vars)
+		if err != nil {
+			d.failed.Do(func() {
+				hc.logger.Printf("derived metric %s: error evaluating %q: %s", d.Name, d.Value, err)
+			})
+			continue
+		}
+		if err := d.guard.set(labels, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
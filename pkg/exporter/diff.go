@@ -0,0 +1,149 @@
+package exporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+)
+
+// PriceChange is a price that differs between two scrapes.
+type PriceChange struct {
+	Labels map[string]string `json:"labels"`
+	From   float64           `json:"from"`
+	To     float64           `json:"to"`
+}
+
+// SailingChange is a sailing that appeared or disappeared between two
+// scrapes, with its lowest price.
+type SailingChange struct {
+	Labels map[string]string `json:"labels"`
+	Price  float64           `json:"price"`
+}
+
+// TargetDiff lists the changes between the last two complete scrapes of a
+// target.
+type TargetDiff struct {
+	Target  string          `json:"target"`
+	From    time.Time       `json:"from"`
+	To      time.Time       `json:"to"`
+	Changed []PriceChange   `json:"changed"`
+	Added   []SailingChange `json:"added"`
+	Removed []SailingChange `json:"removed"`
+}
+
+type scrapeSnapshot struct {
+	at      time.Time
+	scraped map[string]*customMetric
+}
+
+// recordDiff compares a complete scrape with the previous one of the target.
+func (hc *Exporter) recordDiff(t config.Target, scraped map[string]*customMetric) {
+	now := time.Now()
+	hc.diffMu.Lock()
+	defer hc.diffMu.Unlock()
+	previous, ok := hc.snapshots[t.Name]
+	hc.snapshots[t.Name] = scrapeSnapshot{at: now, scraped: scraped}
+	if !ok {
+		if hc.logMode == LogModeDelta {
+			hc.logger.Printf("%s: first complete scrape, %d prices", t.Name, len(scraped))
+		}
+		return
+	}
+
+	d := TargetDiff{Target: t.Name, From: previous.at, To: now, Changed: []PriceChange{}}
+	for key, cm := range scraped {
+		if old, ok := previous.scraped[key]; ok && old.price != cm.price {
+			d.Changed = append(d.Changed, PriceChange{Labels: cm.priceLabels(), From: old.price, To: cm.price})
+		}
+	}
+	sort.Slice(d.Changed, func(i, j int) bool {
+		return history.Key(d.Changed[i].Labels) < history.Key(d.Changed[j].Labels)
+	})
+	d.Added = sailingsOnlyIn(scraped, previous.scraped)
+	d.Removed = sailingsOnlyIn(previous.scraped, scraped)
+	hc.diffs[t.Name] = d
+	hc.logDiff(d)
+	hc.observeChanges(d.Changed)
+}
+
+// observeChanges adds the changed prices to the price change histograms.
+func (hc *Exporter) observeChanges(changes []PriceChange) {
+	for _, c := range changes {
+		direction, delta := "up", c.To-c.From
+		if delta < 0 {
+			direction, delta = "down", -delta
+		}
+		hc.priceChange.WithLabelValues(direction).Observe(delta)
+		if c.From > 0 {
+			hc.priceChangeRatio.WithLabelValues(direction).Observe(delta / c.From)
+		}
+	}
+}
+
+// sailingsOnlyIn returns the sailings of a that aren't in b.
+func sailingsOnlyIn(a, b map[string]*customMetric) []SailingChange {
+	sailingKey := func(cm *customMetric) string {
+		return cm.cruiseID + "\x00" + cm.itinerary + "\x00" + cm.dateLabel
+	}
+	inB := map[string]bool{}
+	for _, cm := range b {
+		inB[sailingKey(cm)] = true
+	}
+	sailings := map[string]*SailingChange{}
+	for _, cm := range a {
+		key := sailingKey(cm)
+		if inB[key] {
+			continue
+		}
+		if s, ok := sailings[key]; ok {
+			if cm.price < s.Price {
+				s.Price = cm.price
+			}
+			continue
+		}
+		labels := map[string]string{
+			"url": cm.url, "cruiseid": cm.cruiseID, "itinerary": cm.itinerary, "datelabel": cm.dateLabel, "ship": cm.ship,
+		}
+		for k, v := range cm.labels {
+			labels[k] = v
+		}
+		sailings[key] = &SailingChange{Labels: labels, Price: cm.price}
+	}
+	keys := make([]string, 0, len(sailings))
+	for key := range sailings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	changes := make([]SailingChange, 0, len(keys))
+	for _, key := range keys {
+		changes = append(changes, *sailings[key])
+	}
+	return changes
+}
+
+func (hc *Exporter) forgetDiff(target string) {
+	hc.diffMu.Lock()
+	delete(hc.snapshots, target)
+	delete(hc.diffs, target)
+	hc.diffMu.Unlock()
+}
+
+// serveDiff serves the changes between the last two complete scrapes of
+// every target scraped at least twice.
+func (hc *Exporter) serveDiff(w http.ResponseWriter, r *http.Request) {
+	hc.diffMu.Lock()
+	diffs := make([]TargetDiff, 0, len(hc.diffs))
+	for _, d := range hc.diffs {
+		diffs = append(diffs, d)
+	}
+	hc.diffMu.Unlock()
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Target < diffs[j].Target })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Targets []TargetDiff `json:"targets"`
+	}{diffs})
+}
@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+)
+
+type digestEntry struct {
+	labels map[string]string
+	from   float64
+	to     float64
+}
+
+func (e digestEntry) describe() string {
+	return fmt.Sprintf("%s %s %s (%s nights) stateroom %s", e.labels["ship"], e.labels["datelabel"], e.labels["departureport"], e.labels["days"], e.labels["stateroomclass"])
+}
+
+// runDigest sends the digest on its schedule until the exporter stops.
+func (hc *Exporter) runDigest() {
+	for {
+		next := hc.digest.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			if !hc.isLeader() {
+				continue
+			}
+			hc.notifier.Send(hc.ctx, hc.digest.Notify, notify.Event{
+				Kind:  "digest",
+				Title: fmt.Sprintf("Royal Caribbean %s price digest", hc.digest.Schedule),
+				Text:  hc.buildDigest(time.Now()),
+			})
+		case <-hc.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// buildDigest summarises the history of the last digest period.
+func (hc *Exporter) buildDigest(now time.Time) string {
+	since := now.Add(-hc.digest.Period())
+	ships := map[string]bool{}
+	for _, s := range hc.digest.Ships {
+		ships[strings.ToLower(s)] = true
+	}
+
+	var drops, lowest, sailings []digestEntry
+	seenSailings := map[string]bool{}
+	hc.history.Range(func(s history.Series) {
+		var before []history.Sample
+		var during []history.Sample
+		for _, sample := range s.Samples {
+			if sample.Time.Before(since) {
+				before = append(before, sample)
+			} else {
+				during = append(during, sample)
+			}
+		}
+		if len(during) == 0 {
+			return
+		}
+		last := during[len(during)-1].Value
+		if len(before) == 0 {
+			key := s.Labels["ship"] + "\x00" + s.Labels["cruiseid"] + "\x00" + s.Labels["datelabel"]
+			if (ships[strings.ToLower(s.Labels["ship"])] || ships[strings.ToLower(s.Labels["shipcode"])]) && !seenSailings[key] {
+				seenSailings[key] = true
+				sailings = append(sailings, digestEntry{labels: s.Labels, to: last})
+			}
+			return
+		}
+		if from := before[len(before)-1].Value; last < from {
+			drops = append(drops, digestEntry{labels: s.Labels, from: from, to: last})
+		}
+		min := before[0].Value
+		for _, sample := range before {
+			if sample.Value < min {
+				min = sample.Value
+			}
+		}
+		if last < min {
+			lowest = append(lowest, digestEntry{labels: s.Labels, from: min, to: last})
+		}
+	})
+
+	sort.Slice(drops, func(i, j int) bool { return drops[i].to-drops[i].from < drops[j].to-drops[j].from })
+	sort.Slice(lowest, func(i, j int) bool { return lowest[i].to < lowest[j].to })
+	sort.Slice(sailings, func(i, j int) bool { return sailings[i].describe() < sailings[j].describe() })
+
+	var b strings.Builder
+	section := func(title string, entries []digestEntry, line func(digestEntry) string) {
+		fmt.Fprintf(&b, "%s:\n", title)
+		if len(entries) == 0 {
+			b.WriteString("  none\n")
+		}
+		for i, e := range entries {
+			if i == hc.digest.Top {
+				fmt.Fprintf(&b, "  and %d more\n", len(entries)-i)
+				break
+			}
+			fmt.Fprintf(&b, "  %s\n", line(e))
+		}
+	}
+	section("Biggest drops", drops, func(e digestEntry) string {
+		return fmt.Sprintf("%s: %.0f -> %.0f (%.0f)", e.describe(), e.from, e.to, e.to-e.from)
+	})
+	section("New lowest-ever prices", lowest, func(e digestEntry) string {
+		return fmt.Sprintf("%s: %.0f, previously %.0f", e.describe(), e.to, e.from)
+	})
+	if len(ships) > 0 {
+		section("New sailings", sailings, func(e digestEntry) string {
+			return fmt.Sprintf("%s %s %s (%s nights) from %.0f", e.labels["ship"], e.labels["datelabel"], e.labels["departureport"], e.labels["days"], e.to)
+		})
+	}
+	return b.String()
+}
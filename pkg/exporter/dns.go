@@ -0,0 +1,180 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+)
+
+// dnsCache holds resolved addresses until they expire. A nil cache resolves
+// every dial.
+type dnsCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cachedAddrs
+}
+
+type cachedAddrs struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{ttl: ttl, entries: map[string]cachedAddrs{}}
+}
+
+func (c *dnsCache) get(host string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[host]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.addrs, true
+}
+
+func (c *dnsCache) put(host string, addrs []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[host] = cachedAddrs{addrs: addrs, expires: time.Now().Add(c.ttl)}
+}
+
+// lookupHost resolves host through the static overrides of t, then the
+// cache, then the resolver. Failed lookups are not cached.
+func (hc *Exporter) lookupHost(ctx context.Context, t config.Target, host string) ([]string, error) {
+	if ip, ok := t.Hosts[host]; ok {
+		hc.dnsLookups.WithLabelValues("override").Inc()
+		return []string{ip}, nil
+	}
+	if addrs, ok := hc.dns.get(host); ok {
+		hc.dnsLookups.WithLabelValues("hit").Inc()
+		return addrs, nil
+	}
+	hc.dnsLookups.WithLabelValues("miss").Inc()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	hc.dns.put(host, addrs)
+	return addrs, nil
+}
+
+// dialer returns the DialContext of the connections to t, trying the
+// addresses of the host of the IP version of t in turn.
+func (hc *Exporter) dialer(t config.Target) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if t.IPVersion != 0 && network == "tcp" {
+			network = fmt.Sprintf("tcp%d", t.IPVersion)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return hc.dialFrom(ctx, t, network, addr)
+		}
+		addrs, err := hc.lookupHost(ctx, t, host)
+		if err != nil {
+			return nil, err
+		}
+		addrs = ofIPVersion(addrs, t.IPVersion)
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("no IPv%d address for %s", t.IPVersion, host)
+		}
+		for _, a := range addrs {
+			var conn net.Conn
+			if conn, err = hc.dialFrom(ctx, t, network, net.JoinHostPort(a, port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+// ofIPVersion returns the addresses of the IP version, 4 or 6, all of them
+// for 0.
+func ofIPVersion(addrs []string, version int) []string {
+	if version == 0 {
+		return addrs
+	}
+	var of []string
+	for _, a := range addrs {
+		if ip := net.ParseIP(a); ip != nil && (ip.To4() != nil) == (version == 4) {
+			of = append(of, a)
+		}
+	}
+	return of
+}
+
+// addressFamily is the family label of the connection to addr, ipv4 or
+// ipv6, empty when unknown.
+func addressFamily(addr net.Addr) string {
+	tcp, ok := addr.(*net.TCPAddr)
+	switch {
+	case !ok:
+		return ""
+	case tcp.IP.To4() != nil:
+		return "ipv4"
+	default:
+		return "ipv6"
+	}
+}
+
+// dialKey tells apart targets that must not share connections because they
+// dial differently, empty when t dials like the client of the exporter.
+func (hc *Exporter) dialKey(t config.Target) string {
+	address, iface := hc.source(t)
+	if hc.dns == nil && len(t.Hosts) == 0 && t.IPVersion == 0 && address == "" && iface == "" {
+		return ""
+	}
+	hosts := make([]string, 0, len(t.Hosts))
+	for host, ip := range t.Hosts {
+		hosts = append(hosts, host+"="+ip)
+	}
+	sort.Strings(hosts)
+	return fmt.Sprintf("ip:%d hosts:%s source:%s interface:%s", t.IPVersion, strings.Join(hosts, ","), address, iface)
+}
+
+// clientFor returns the client sending the requests to t. Targets dialing
+// differently get a copy of the client of the exporter each, so pooled
+// connections are never reused across them. A client set by WithHTTPClient
+// that is not an *http.Client with an *http.Transport is used as is.
+func (hc *Exporter) clientFor(t config.Target) Doer {
+	key := hc.dialKey(t)
+	if key == "" {
+		return hc.client
+	}
+	client, ok := hc.client.(*http.Client)
+	if !ok {
+		return hc.client
+	}
+	base, ok := client.Transport.(*http.Transport)
+	if client.Transport == nil {
+		base, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		return hc.client
+	}
+
+	hc.clientsMu.Lock()
+	defer hc.clientsMu.Unlock()
+	if c, ok := hc.clients[key]; ok {
+		return c
+	}
+	transport := base.Clone()
+	transport.DialContext = hc.dialer(t)
+	c := *client
+	c.Transport = transport
+	hc.clients[key] = &c
+	return &c
+}
@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"strings"
+	"time"
+)
+
+// scrapeOutcome is whether a scrape of a target returned data.
+type scrapeOutcome struct {
+	at time.Time
+	ok bool
+}
+
+// recordOutcome remembers the outcome of a scrape and exports the share of
+// successful scrapes of the target over every window. Skipped scrapes don't
+// count either way.
+func (hc *Exporter) recordOutcome(report TargetReport, now time.Time) {
+	if len(hc.successWindows) == 0 || report.Skipped {
+		return
+	}
+	longest := hc.successWindows[0]
+	for _, w := range hc.successWindows {
+		if w > longest {
+			longest = w
+		}
+	}
+
+	hc.outcomesMu.Lock()
+	defer hc.outcomesMu.Unlock()
+	outcomes := append(hc.outcomes[report.Name], scrapeOutcome{at: now, ok: report.Error == ""})
+	for len(outcomes) > 0 && outcomes[0].at.Before(now.Add(-longest)) {
+		outcomes = outcomes[1:]
+	}
+	hc.outcomes[report.Name] = outcomes
+
+	for _, w := range hc.successWindows {
+		var total, ok float64
+		for _, o := range outcomes {
+			if o.at.Before(now.Add(-w)) {
+				continue
+			}
+			total++
+			if o.ok {
+				ok++
+			}
+		}
+		hc.successRatio.WithLabelValues(report.Name, windowLabel(w)).Set(ok / total)
+	}
+}
+
+func (hc *Exporter) forgetOutcomes(target string) {
+	hc.outcomesMu.Lock()
+	delete(hc.outcomes, target)
+	hc.outcomesMu.Unlock()
+	for _, w := range hc.successWindows {
+		hc.successRatio.DeleteLabelValues(target, windowLabel(w))
+	}
+}
+
+// windowLabel formats a window like 1h or 30m rather than 1h0m0s.
+func windowLabel(w time.Duration) string {
+	s := w.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
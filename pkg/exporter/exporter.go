@@ -4,411 +4,1384 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptrace"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/calendar"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/leader"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/redis"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-type CruiseSearch struct {
-	Data struct {
-		CruiseSearch struct {
-			Results struct {
-				Cruises []struct {
-					ID                 string `json:"id"`
-					ProductViewLink    string `json:"productViewLink"`
-					LowestPriceSailing struct {
-						BookingLink               string `json:"bookingLink"`
-						ID                        string `json:"id"`
-						LowestStateroomClassPrice struct {
-							Price struct {
-								Value    int    `json:"value"`
-								Typename string `json:"__typename"`
-							} `json:"price"`
-							StateroomClass struct {
-								ID       string `json:"id"`
-								Typename string `json:"__typename"`
-							} `json:"stateroomClass"`
-							Typename string `json:"__typename"`
-						} `json:"lowestStateroomClassPrice"`
-						SailDate     string `json:"sailDate"`
-						StartDate    string `json:"startDate"`
-						EndDate      string `json:"endDate"`
-						TaxesAndFees struct {
-							Value    float64 `json:"value"`
-							Typename string  `json:"__typename"`
-						} `json:"taxesAndFees"`
-						TaxesAndFeesIncluded bool   `json:"taxesAndFeesIncluded"`
-						Typename             string `json:"__typename"`
-					} `json:"lowestPriceSailing"`
-					MasterSailing struct {
-						Itinerary struct {
-							Code  string `json:"code"`
-							Media struct {
-								Images []struct {
-									Path     string `json:"path"`
-									Typename string `json:"__typename"`
-								} `json:"images"`
-								Typename string `json:"__typename"`
-							} `json:"media"`
-							Days []struct {
-								Number int    `json:"number"`
-								Type   string `json:"type"`
-								Ports  []struct {
-									Activity      string `json:"activity"`
-									DepartureTime string `json:"departureTime"`
-									Port          struct {
-										Code   string `json:"code"`
-										Name   string `json:"name"`
-										Region string `json:"region"`
-										Media  struct {
-											Images []struct {
-												Path     string `json:"path"`
-												Typename string `json:"__typename"`
-											} `json:"images"`
-											Typename string `json:"__typename"`
-										} `json:"media"`
-										Typename string `json:"__typename"`
-									} `json:"port"`
-									Typename string `json:"__typename"`
-								} `json:"ports"`
-								Typename string `json:"__typename"`
-							} `json:"days"`
-							DeparturePort struct {
-								Code     string `json:"code"`
-								Name     string `json:"name"`
-								Region   string `json:"region"`
-								Typename string `json:"__typename"`
-							} `json:"departurePort"`
-							Destination struct {
-								Code     string `json:"code"`
-								Name     string `json:"name"`
-								Typename string `json:"__typename"`
-							} `json:"destination"`
-							Name          string `json:"name"`
-							SailingNights int    `json:"sailingNights"`
-							Ship          struct {
-								Code             string `json:"code"`
-								Name             string `json:"name"`
-								StateroomClasses []struct {
-									ID      string `json:"id"`
-									Name    string `json:"name"`
-									Content struct {
-										Amenities   []string `json:"amenities"`
-										Code        string   `json:"code"`
-										MaxCapacity string   `json:"maxCapacity"`
-										Media       struct {
-											Images []struct {
-												Path string `json:"path"`
-												Meta struct {
-													Description string `json:"description"`
-													Title       string `json:"title"`
-													Location    string `json:"location"`
-													Typename    string `json:"__typename"`
-												} `json:"meta"`
-												Typename string `json:"__typename"`
-											} `json:"images"`
-											Typename string `json:"__typename"`
-										} `json:"media"`
-										SuperCategory string `json:"superCategory"`
-										Typename      string `json:"__typename"`
-									} `json:"content"`
-									Typename string `json:"__typename"`
-								} `json:"stateroomClasses"`
-								Media struct {
-									Images []struct {
-										Path     string `json:"path"`
-										Typename string `json:"__typename"`
-									} `json:"images"`
-									Typename string `json:"__typename"`
-								} `json:"media"`
-								Typename string `json:"__typename"`
-							} `json:"ship"`
-							TotalNights int    `json:"totalNights"`
-							Type        string `json:"type"`
-							Typename    string `json:"__typename"`
-						} `json:"itinerary"`
-						Typename string `json:"__typename"`
-					} `json:"masterSailing"`
-					Sailings []struct {
-						BookingLink string `json:"bookingLink"`
-						ID          string `json:"id"`
-						Itinerary   struct {
-							Code     string `json:"code"`
-							Typename string `json:"__typename"`
-						} `json:"itinerary"`
-						SailDate              string `json:"sailDate"`
-						StartDate             string `json:"startDate"`
-						EndDate               string `json:"endDate"`
-						StateroomClassPricing []struct {
-							Price struct {
-								Value    int    `json:"value"`
-								Typename string `json:"__typename"`
-							} `json:"price"`
-							StateroomClass struct {
-								ID       string `json:"id"`
-								Typename string `json:"__typename"`
-							} `json:"stateroomClass"`
-							Typename string `json:"__typename"`
-						} `json:"stateroomClassPricing"`
-						Typename string `json:"__typename"`
-					} `json:"sailings"`
-					Typename string `json:"__typename"`
-				} `json:"cruises"`
-				Total    int    `json:"total"`
-				Typename string `json:"__typename"`
-			} `json:"results"`
-			Typename string `json:"__typename"`
-		} `json:"cruiseSearch"`
-	} `json:"data"`
-}
-
 type customMetric struct {
 	url             string
 	status          float64
-	totalMS         float64
-	dnsMS           float64
-	firstbyteMS     float64
-	connectMS       float64
+	total           time.Duration
+	dns             time.Duration
+	firstbyte       time.Duration
+	connect         time.Duration
 	price           float64
+	quotedPrice     float64
 	cruiseID        string
 	itinerary       string
 	stateroomClass  string
 	dateLabel       string
+	departureDay    string
 	ship            string
 	departurePort   string
 	days            string
 	shipCode        string
 	destinationCode string
+	region          string
+	bookingLink     string
+	labels          map[string]string
 }
 
 type Exporter struct {
 	ctx                   context.Context
-	urlStatus             *prometheus.GaugeVec
-	urlMs                 *prometheus.GaugeVec
-	urlDNS                *prometheus.GaugeVec
-	urlFirstByte          *prometheus.GaugeVec
-	urlConnectTime        *prometheus.GaugeVec
-	royalPrice            *prometheus.GaugeVec
-	urls                  []string
+	urlStatus             *seriesGuard
+	urlResponse           *seriesGuard
+	urlDNS                *seriesGuard
+	urlFirstByte          *seriesGuard
+	urlConnectTime        *seriesGuard
+	royalPrice            *seriesGuard
+	priceMismatch         *seriesGuard
+	priceAnomaly          *seriesGuard
+	priceTrend            *seriesGuard
+	holidayInfo           *seriesGuard
+	calendar              *calendar.Calendar
+	rollupConfigs         []config.RollupConfig
+	rollups               []rollup
+	operationConfigs      []config.OperationConfig
+	operations            []operation
+	derivedConfigs        []config.DerivedMetricConfig
+	derived               []*derivedMetric
+	documented            []prometheus.Collector
+	pricingCalendar       *config.PricingCalendarConfig
+	superCategoryClasses  map[string]string
+	taxes                 string
+	logMode               string
+	logThrottle           time.Duration
+	audit                 *auditLog
+	cabinGuests           int
+	cabinTotalPrice       *seriesGuard
+	regionSailings        *seriesGuard
+	regionLowestPrice     *seriesGuard
+	seriesTTL             int
+	scrapeStartsMu        sync.Mutex
+	scrapeStarts          map[string][]time.Time
+	openMetrics           bool
+	requestDuration       *prometheus.HistogramVec
+	priceChange           *prometheus.HistogramVec
+	priceChangeRatio      *prometheus.HistogramVec
+	pageLatency           *prometheus.GaugeVec
+	duplicateCruises      *prometheus.CounterVec
+	dns                   *dnsCache
+	dnsLookups            *prometheus.CounterVec
+	clientsMu             sync.Mutex
+	clients               map[string]Doer
+	sourceAddress         string
+	sourceInterface       string
+	sort                  string
+	checksumMu            sync.Mutex
+	checksums             map[string]string
+	checksumInfo          *prometheus.GaugeVec
+	scrapeContent         *prometheus.CounterVec
+	parseCoverage         *prometheus.GaugeVec
+	maxBackoff            time.Duration
+	backoffMu             sync.Mutex
+	failedCycles          int
+	nextCycle             time.Time
+	effectiveInterval     prometheus.Gauge
+	panics                *prometheus.CounterVec
+	successWindows        []time.Duration
+	successRatio          *prometheus.GaugeVec
+	outcomesMu            sync.Mutex
+	outcomes              map[string][]scrapeOutcome
+	seriesExpired         *prometheus.CounterVec
+	activeSeries          *activeSeries
+	diffMu                sync.Mutex
+	snapshots             map[string]scrapeSnapshot
+	diffs                 map[string]TargetDiff
+	dateLayout            string
+	dateLocation          *time.Location
+	quotedPrice           *seriesGuard
+	superCategoryPrice    *seriesGuard
+	calendarPrice         *seriesGuard
+	dropRawPrices         bool
+	seriesDropped         *prometheus.CounterVec
+	schemaWarnings        *prometheus.CounterVec
+	scrapesSkipped        *prometheus.CounterVec
+	httpRequests          *prometheus.CounterVec
+	inFlight              prometheus.Gauge
+	scrapePartial         *prometheus.GaugeVec
+	duplicates            *prometheus.CounterVec
+	itineraryChanges      *prometheus.CounterVec
+	stateroomsReleased    *prometheus.CounterVec
+	itineraryChangesCfg   *config.ItineraryChangesConfig
+	sailingsMu            sync.Mutex
+	sailings              map[string]sailingMeta
+	scrapingMu            sync.Mutex
+	scraping              map[string]time.Time
+	lastSchemaDiffMu      sync.Mutex
+	lastSchemaDiff        map[string]string
+	responses             *responseRing
+	debugToken            atomic.Value
+	lastScrape            atomic.Value
+	targetsMu             sync.RWMutex
+	targets               []config.Target
+	discovered            map[string][]config.Target
+	declaredLabelNames    []string
+	staticLabelNames      []string
+	filters               string
+	queryFeatures         royalapi.Features
+	query                 royalapi.Query
+	queriesMu             sync.Mutex
+	queries               map[string]royalapi.Query
+	persistedQueries      bool
 	healthcheck_invertval time.Duration
+	scrapeBudget          time.Duration
+	relabelConfigs        []config.RelabelConfig
+	seriesLimit           int
+	seriesLimitAction     string
+	registerer            prometheus.Registerer
+	gatherer              prometheus.Gatherer
+	snapshot              *snapshotGatherer
+	sampleTimestamps      bool
+	legacyMetricNames     bool
+	mux                   *http.ServeMux
+	client                Doer
+	history               *history.Store
+	historyFile           string
+	anomaly               *config.AnomalyConfig
+	trend                 *config.TrendConfig
+	digest                *config.DigestConfig
+	anomalousMu           sync.Mutex
+	anomalous             map[string]bool
+	notifier              *notify.Dispatcher
+	outbox                *notify.Outbox
+	outboxRetry           time.Duration
+	watchesMu             sync.RWMutex
+	watches               []config.WatchConfig
+	watchesFile           string
+	watchesSaveMu         sync.Mutex
+	managedWatches        map[string]bool
+	users                 map[string]*config.UserConfig
+	watchLimits           map[string]*watchLimit
+	watchFiring           *prometheus.GaugeVec
+	firingMu              sync.Mutex
+	firing                map[string]*firingWatch
+	watchedPrices         map[string]watchedPrice
+	conditionFailed       map[string]bool
+	feed                  feed
+	resolved              []*firingWatch
+	watchStateFile        string
+	alertmanager          *notify.Alertmanager
+	externalURL           string
+	elector               leader.Elector
+	tracer                Tracer
+	leaderGauge           prometheus.Gauge
+	catalogs              *redis.Client
+	catalogPrefix         string
+	catalogMu             sync.Mutex
+	catalogApplied        map[string]time.Time
+	pages                 *pageCache
+	pageConcurrency       int
+	maxPageSize           int
+	pageSizesMu           sync.Mutex
+	pageSizes             map[string]int
+	pageSize              *prometheus.GaugeVec
+	session               *session
+	requestBudget         int
+	budgetsMu             sync.Mutex
+	budgets               map[string]*budgetWindow
+	budgetRemaining       *prometheus.GaugeVec
+	scrapeWindow          *config.TimeWindowConfig
+	windowOpen            prometheus.Gauge
+	windowWasOpen         int32
+	cycling               int32
+	sessionBootstraps     *prometheus.CounterVec
+	validators            *validatorCache
+	cacheHits             *prometheus.CounterVec
+	logger                *log.Logger
 }
 
-func NewExporter(ctx context.Context, inverval time.Duration, urls []string) (hc *Exporter) {
-	hc = &Exporter{
-		ctx: ctx,
-		urlStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: "royal",
-			Subsystem: "external",
-			Name:      "proce",
-			Help:      "Status of the URL as a integer value",
-		}, []string{"url"}),
-		urlMs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: "royal",
-			Subsystem: "external",
-			Name:      "url_response_ms",
-			Help:      "Response time in milliseconds it took for the URL to respond.",
-		}, []string{"url"}),
-		urlDNS: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: "royal",
-			Subsystem: "external",
-			Name:      "url_dns_ms",
-			Help:      "Response time in milliseconds it took for the DNS request to take place.",
-		}, []string{"url"}),
-		urlFirstByte: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: "royal",
-			Subsystem: "external",
-			Name:      "url_first_byte_ms",
-			Help:      "Response time in milliseconds it took to retrive the first byte.",
-		}, []string{"url"}),
-		urlConnectTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+func NewExporter(ctx context.Context, opts ...Option) (*Exporter, error) {
+	hc := &Exporter{
+		ctx:                   ctx,
+		lastSchemaDiff:        map[string]string{},
+		discovered:            map[string][]config.Target{},
+		scraping:              map[string]time.Time{},
+		anomalous:             map[string]bool{},
+		validators:            newValidatorCache(),
+		catalogApplied:        map[string]time.Time{},
+		pageConcurrency:       1,
+		maxPageSize:           minPageSize,
+		pageSizes:             map[string]int{},
+		budgets:               map[string]*budgetWindow{},
+		windowWasOpen:         1,
+		sailings:              map[string]sailingMeta{},
+		firing:                map[string]*firingWatch{},
+		managedWatches:        map[string]bool{},
+		watchLimits:           map[string]*watchLimit{},
+		watchedPrices:         map[string]watchedPrice{},
+		conditionFailed:       map[string]bool{},
+		healthcheck_invertval: 60 * time.Second,
+		seriesLimitAction:     SeriesLimitDrop,
+		taxes:                 TaxesAsQuoted,
+		logMode:               LogModeFull,
+		openMetrics:           true,
+		tracer:                noopTracer{},
+		dateLocation:          time.UTC,
+		snapshots:             map[string]scrapeSnapshot{},
+		outcomes:              map[string][]scrapeOutcome{},
+		diffs:                 map[string]TargetDiff{},
+		clients:               map[string]Doer{},
+		checksums:             map[string]string{},
+		queryFeatures:         royalapi.AllFeatures(),
+		sort:                  royalapi.SortRecommended,
+		registerer:            prometheus.DefaultRegisterer,
+		gatherer:              prometheus.DefaultGatherer,
+		mux:                   http.DefaultServeMux,
+		client:                &http.Client{},
+		logger:                log.Default(),
+	}
+	hc.debugToken.Store("")
+	for _, opt := range opts {
+		if err := opt(hc); err != nil {
+			return nil, err
+		}
+	}
+	hc.query = royalapi.NewQuery(hc.queryFeatures)
+	var throttle *logThrottle
+	if hc.logThrottle > 0 {
+		throttle = newLogThrottle(hc.logger, hc.logThrottle)
+		hc.logger = log.New(throttle, "", 0)
+	}
+	if (hc.anomaly != nil || hc.trend != nil || hc.digest != nil) && hc.history == nil {
+		return nil, fmt.Errorf("anomaly detection, trends and digests require a history store")
+	}
+	if err := hc.wireOptions(); err != nil {
+		return nil, err
+	}
+	if err := config.ValidateTargets(hc.targets); err != nil {
+		return nil, err
+	}
+	if err := hc.collectStaticLabelNames(); err != nil {
+		return nil, err
+	}
+
+	seriesDropped := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "series_dropped_total",
+		Help:      "Number of series dropped or refused because the metric reached its series limit.",
+	}, []string{"metric"})
+
+	hc.seriesExpired = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "series_expired_total",
+		Help:      "Number of series removed because they weren't updated within the series TTL.",
+	}, []string{"metric"})
+	active := newActiveSeries()
+	hc.activeSeries = active
+	collectors := []prometheus.Collector{seriesDropped, hc.seriesExpired, active}
+	if throttle != nil {
+		collectors = append(collectors, prometheus.NewCounterFunc(prometheus.CounterOpts{
 			Namespace: "royal",
-			Subsystem: "external",
-			Name:      "url_connect_time_ms",
-			Help:      "Response time in milliseconds it took to establish the inital connection.",
-		}, []string{"url"}),
-		royalPrice: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: "exporter",
+			Name:      "log_messages_suppressed_total",
+			Help:      "Number of log messages held back because an identical one was logged within the log throttle interval.",
+		}, throttle.suppressedTotal))
+	}
+	gauge := func(name, help string, labels ...string) *seriesGuard {
+		labels = append(labels, hc.staticLabelNames...)
+		vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: "royal",
 			Subsystem: "external",
-			Name:      "price",
-			Help:      "cabin price with labels",
-		}, []string{"url", "cruiseid", "itinerary", "stateroomclass", "datelabel", "ship", "departureport", "days", "shipcode", "destinationcode"}),
-		healthcheck_invertval: inverval,
-		urls:                  urls,
-	}
-	prometheus.MustRegister(hc.urlStatus, hc.urlMs, hc.urlDNS, hc.urlConnectTime, hc.urlFirstByte, hc.royalPrice)
-	http.Handle("/metrics", promhttp.Handler())
-	return hc
+			Name:      name,
+			Help:      help,
+		}, keptLabels(hc.relabelConfigs, labels))
+		collectors = append(collectors, vec)
+		g := newSeriesGuard("royal_external_"+name, vec, hc.relabelConfigs, seriesDropped, hc.logger)
+		g.limit = hc.seriesLimit
+		g.action = hc.seriesLimitAction
+		active.guards = append(active.guards, g)
+		return g
+	}
+
+	hc.urlStatus = gauge("url_status", "HTTP status code the URL answered with.", "url")
+	hc.urlResponse = gauge("url_response_seconds", "Time it took for the URL to respond.", "url")
+	hc.urlDNS = gauge("url_dns_seconds", "Time it took for the DNS request to take place.", "url")
+	hc.urlFirstByte = gauge("url_first_byte_seconds", "Time it took to retrieve the first byte.", "url")
+	hc.urlConnectTime = gauge("url_connect_time_seconds", "Time it took to establish the initial connection.", "url")
+	if hc.legacyMetricNames {
+		for _, l := range []struct {
+			guard *seriesGuard
+			name  string
+			help  string
+			scale float64
+		}{
+			{hc.urlStatus, "proce", "Status of the URL as a integer value", 1},
+			{hc.urlResponse, "url_response_ms", "Response time in milliseconds it took for the URL to respond.", 1000},
+			{hc.urlDNS, "url_dns_ms", "Response time in milliseconds it took for the DNS request to take place.", 1000},
+			{hc.urlFirstByte, "url_first_byte_ms", "Response time in milliseconds it took to retrive the first byte.", 1000},
+			{hc.urlConnectTime, "url_connect_time_ms", "Response time in milliseconds it took to establish the inital connection.", 1000},
+		} {
+			help := l.help + " Deprecated, use " + l.guard.name + "."
+			l.guard.aliases = append(l.guard.aliases, metricAlias{guard: gauge(l.name, help, "url"), scale: l.scale})
+		}
+	}
+	priceLabelNames := []string{"url", "cruiseid", "itinerary", "stateroomclass", "datelabel", "departureday", "ship", "departureport", "days", "shipcode", "destinationcode"}
+	hc.royalPrice = gauge("price", "cabin price per person with labels, as the API quotes it for two guests sharing the cabin", priceLabelNames...)
+	hc.regionSailings = gauge("region_sailings", "Number of priced sailings per destination region.", "url", "destination_region")
+	hc.regionLowestPrice = gauge("region_lowest_price", "Cheapest cabin price per person of the sailings of a destination region.", "url", "destination_region")
+	if hc.cabinGuests > 0 {
+		hc.cabinTotalPrice = gauge("cabin_total_price", "cabin price for all the guests of the cabin, the price per person times the guest count", priceLabelNames...)
+	}
+	hc.priceMismatch = gauge("lowest_price_mismatch", "Advertised lowest price of the cruise minus the lowest price over its sailings, 0 when they agree.",
+		"url", "cruiseid")
+	if hc.anomaly != nil {
+		hc.priceAnomaly = gauge("price_anomaly", "1 if the price jumped away from its recent history, see the anomaly config.", priceLabelNames...)
+	}
+	if hc.calendar != nil {
+		hc.holidayInfo = gauge("sailing_holiday_info", "Always 1, one series per holiday the sailing overlaps.", "url", "cruiseid", "datelabel", "holiday")
+	}
+	for _, r := range hc.rollupConfigs {
+		for _, name := range hc.staticLabelNames {
+			if name == "month" || name == "aggregation" {
+				return nil, fmt.Errorf("static label %q collides with a rollup label", name)
+			}
+		}
+		labels := append([]string{"url"}, r.By...)
+		hc.rollups = append(hc.rollups, rollup{
+			RollupConfig: r,
+			guard:        gauge("price_rollup_"+r.Name, "Aggregated cabin prices of the "+r.Name+" rollup.", append(labels, "aggregation")...),
+		})
+	}
+	for _, cfg := range hc.operationConfigs {
+		op, err := newOperation(cfg, gauge)
+		if err != nil {
+			return nil, fmt.Errorf("operation %s: %w", cfg.Name, err)
+		}
+		hc.operations = append(hc.operations, op)
+	}
+	for _, cfg := range hc.derivedConfigs {
+		help := cfg.Help
+		if help == "" {
+			help = "Derived from the price series as " + cfg.Value.String() + "."
+		}
+		hc.derived = append(hc.derived, &derivedMetric{DerivedMetricConfig: cfg, guard: gauge(cfg.Name, help, priceLabelNames...)})
+	}
+	if hc.taxes != TaxesAsQuoted {
+		hc.quotedPrice = gauge("price_as_quoted", "cabin price as returned by the API, before royal_external_price was normalized to include or exclude taxes and fees", priceLabelNames...)
+	}
+	if hc.superCategoryClasses != nil {
+		hc.superCategoryPrice = gauge("super_category_price", "Cheapest price of the stateroom classes of a super category, interior, oceanview, balcony or suite, per sailing.", "url", "cruiseid", "itinerary", "datelabel", "ship", "super_category")
+	}
+	if hc.pricingCalendar != nil {
+		hc.calendarPrice = gauge("calendar_price", "Lowest price per sail date and stateroom super category from the pricing calendar.", "url", "itinerary", "sail_date", "super_category")
+	}
+	if hc.trend != nil {
+		hc.priceTrend = gauge("price_trend_per_day", "Linear trend of the price over the trend window, in price units per day. Negative means getting cheaper.", priceLabelNames...)
+	}
+	hc.seriesDropped = seriesDropped
+	hc.schemaWarnings = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "schema_warnings_total",
+		Help:      "Number of fields in the response that were missing, unexpected or empty compared to the CruiseSearch model.",
+	}, []string{"url", "kind"})
+
+	hc.scrapesSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "scrapes_skipped_total",
+		Help:      "Number of target scrapes skipped because the previous scrape of the target was still running.",
+	}, []string{"target"})
+	hc.cacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "cache_hits_total",
+		Help:      "Number of pages reused instead of parsed again, by cache: \"ttl\" for the response cache, \"conditional\" when the target answered 304 Not Modified.",
+	}, []string{"target", "cache"})
+
+	hc.httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "http_requests_total",
+		Help:      "Number of requests sent to the targets by status code, \"error\" when no response was received.",
+	}, []string{"target", "code"})
+	hc.inFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "in_flight_requests",
+		Help:      "Number of requests to the targets currently waiting for a response.",
+	})
+
+	hc.scrapePartial = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "scrape_partial",
+		Help:      "1 if the last scrape of the target ran out of its time budget before reaching the last page.",
+	}, []string{"target"})
+	hc.sessionBootstraps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "session_bootstraps_total",
+		Help:      "Number of session bootstrap page loads by result.",
+	}, []string{"result"})
+	sessionAge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "session_age_seconds",
+		Help:      "Age of the session the GraphQL calls are made with, 0 without a session.",
+	}, func() float64 {
+		if hc.session == nil {
+			return 0
+		}
+		return hc.session.age()
+	})
+	hc.budgetRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "request_budget_remaining",
+		Help:      "Number of requests the target may still receive today. Scraping the target pauses at 0 until midnight.",
+	}, []string{"target"})
+	hc.windowOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "scrape_window_open",
+		Help:      "1 while the configured scrape window allows scraping.",
+	})
+	hc.windowOpen.Set(1)
+	hc.pageSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "page_size",
+		Help:      "Number of cruises requested per page from the target, lowered from the maximum when the target rejects or truncates larger pages.",
+	}, []string{"target"})
+
+	hc.duplicates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "duplicate_prices_total",
+		Help:      "Number of prices seen more than once for the same sailing and stateroom class within a scrape. The lowest one is exported.",
+	}, []string{"target"})
+	hc.duplicateCruises = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "duplicate_cruises_total",
+		Help:      "Number of cruises skipped because an earlier page of the same scrape already had them, as when the upstream sort is unstable across pages.",
+	}, []string{"target"})
+
+	hc.itineraryChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "itinerary_changes_total",
+		Help:      "Number of sailings whose itinerary, departure port or ports of call changed between scrapes.",
+	}, []string{"target", "field"})
+
+	hc.stateroomsReleased = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "staterooms_released_total",
+		Help:      "Number of stateroom classes priced again after being sold out in the previous scrape.",
+	}, []string{"target"})
+	hc.leaderGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "leader",
+		Help:      "1 if this replica is the one scraping the targets.",
+	})
+	hc.requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "request_duration_seconds",
+		Help:      "Time it took the target to answer a request, by address family of the connection, with the scrape and request ID, or the trace ID, as exemplars.",
+		Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"target", "family"})
+	hc.priceChange = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "price_change",
+		Help:      "Absolute change of the prices that changed between two complete scrapes of a target, by direction.",
+		Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000},
+	}, []string{"direction"})
+	hc.priceChangeRatio = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "price_change_ratio",
+		Help:      "Change of the prices that changed between two complete scrapes of a target relative to the previous price, by direction.",
+		Buckets:   []float64{.01, .02, .05, .1, .2, .3, .5, 1},
+	}, []string{"direction"})
+	for _, direction := range []string{"up", "down"} {
+		hc.priceChange.WithLabelValues(direction)
+		hc.priceChangeRatio.WithLabelValues(direction)
+	}
+	hc.pageLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "page_latency_seconds",
+		Help:      "Minimum, maximum and mean time the pages of the last scrape of the target took.",
+	}, []string{"target", "aggregation"})
+	hc.dnsLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "dns_lookups_total",
+		Help:      "Host name lookups of target connections by result, hit or miss of the DNS cache or a static override of the target.",
+	}, []string{"result"})
+	hc.checksumInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "scrape_checksum_info",
+		Help:      "Checksum of the prices of the last complete scrape of the target, always 1.",
+	}, []string{"target", "checksum"})
+	hc.scrapeContent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "scrape_content_total",
+		Help:      "Complete scrapes of the target whose prices changed or not since the previous one.",
+	}, []string{"target", "result"})
+	hc.parseCoverage = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "parsed_items",
+		Help:      "Cruises, sailings and stateroom class prices of the last complete scrape of the target, exported or skipped as a duplicate, for a zero price or for missing fields.",
+	}, []string{"target", "kind", "result"})
+	hc.effectiveInterval = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "effective_interval_seconds",
+		Help:      "Interval between scrape cycles, longer than configured while backing off because every target fails.",
+	})
+	hc.effectiveInterval.Set(hc.healthcheck_invertval.Seconds())
+	hc.panics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "panics_total",
+		Help:      "Number of panics recovered from, by component: scrape of a target, scrape cycle or collector loop.",
+	}, []string{"component"})
+	hc.successRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "scrape_success_ratio",
+		Help:      "Share of the scrapes of the target over the window that returned data, skipped scrapes left out.",
+	}, []string{"target", "window"})
+	hc.watchFiring = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "exporter",
+		Name:      "watch_firing",
+		Help:      "Number of price series currently matching the watch.",
+	}, []string{"watch"})
+	collectors = append(collectors, hc.instrumentNotifier()...)
+
+	hc.documented = append(collectors, hc.schemaWarnings, hc.scrapesSkipped, hc.httpRequests, hc.inFlight, hc.scrapePartial, hc.duplicates, hc.duplicateCruises, hc.itineraryChanges, hc.stateroomsReleased, hc.watchFiring, hc.successRatio, hc.requestDuration, hc.pageLatency, hc.dnsLookups, hc.checksumInfo, hc.scrapeContent, hc.parseCoverage, hc.panics, hc.priceChange, hc.priceChangeRatio, hc.effectiveInterval, hc.leaderGauge, hc.cacheHits, hc.pageSize, hc.sessionBootstraps, sessionAge, hc.budgetRemaining, hc.windowOpen)
+	for _, c := range hc.documented {
+		if err := hc.registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	hc.snapshot = &snapshotGatherer{gatherer: hc.gatherer}
+	if hc.sampleTimestamps {
+		hc.snapshot.updated = hc.seriesUpdated
+	}
+	hc.mux.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		hc.registerer, promhttp.HandlerFor(hc.snapshot, promhttp.HandlerOpts{EnableOpenMetrics: hc.openMetrics}),
+	))
+	hc.mux.HandleFunc("/readyz", hc.serveReady)
+	hc.mux.HandleFunc("/api/v1/last-scrape", hc.serveLastScrape)
+	hc.mux.HandleFunc("/api/v1/diff", hc.serveDiff)
+	hc.mux.HandleFunc("/api/v1/metric-docs", hc.serveMetricDocs)
+	hc.mux.HandleFunc("/calendar.ics", hc.serveCalendar)
+	hc.mux.HandleFunc("/feed.atom", hc.serveFeed)
+	hc.mux.HandleFunc("/api/v1/watches", hc.signedIn(hc.serveWatches))
+	hc.mux.HandleFunc("/api/v1/watches/", hc.signedIn(hc.serveWatch))
+	hc.mux.HandleFunc("/api/v1/sd", hc.signedIn(hc.serveSD))
+	hc.mux.HandleFunc("/api/v1/watch-metrics", hc.signedIn(hc.serveWatchMetrics))
+	hc.mux.HandleFunc("/ui/", hc.signedIn(hc.serveUI))
+	hc.mux.HandleFunc("/ui/compare", hc.signedIn(hc.serveCompare))
+	hc.mux.HandleFunc("/ui/dates", hc.signedIn(hc.serveDates))
+	if hc.audit != nil {
+		hc.mux.HandleFunc("/api/v1/audit", hc.signedIn(hc.serveAudit))
+	}
+	if hc.responses != nil {
+		hc.mux.HandleFunc("/debug/last-response", hc.serveLastResponse)
+		hc.logger.Printf("debug endpoint enabled, keeping the last %d responses per target", hc.responses.size)
+	}
+	return hc, nil
 }
 
-func (hc *Exporter) updateCustomMetrics(cm *customMetric) {
-	// log.Printf("Updating custom metrics: url: %s, connectMS: %.0f, dnsMS: %.0f, firstbyteMS: %.0f, totalMS: %.0f, status: %.0f",
-	// 	cm.url,
-	// 	cm.connectMS,
-	// 	cm.dnsMS,
-	// 	cm.firstbyteMS,
-	// 	cm.totalMS,
-	// 	cm.status,
-	// )
-	hc.urlDNS.With(prometheus.Labels{
-		"url": cm.url,
-	}).Set(cm.dnsMS)
-	hc.urlConnectTime.With(prometheus.Labels{
-		"url": cm.url,
-	}).Set(cm.connectMS)
-	hc.urlMs.With(prometheus.Labels{
-		"url": cm.url,
-	}).Set(cm.totalMS)
-	hc.urlFirstByte.With(prometheus.Labels{
-		"url": cm.url,
-	}).Set(cm.firstbyteMS)
-	hc.urlStatus.With(prometheus.Labels{
-		"url": cm.url,
-	}).Set(cm.status)
-	hc.royalPrice.With(prometheus.Labels{
+func (cm *customMetric) priceLabels() prometheus.Labels {
+	labels := prometheus.Labels{
 		"url":             cm.url,
 		"cruiseid":        cm.cruiseID,
 		"itinerary":       cm.itinerary,
 		"stateroomclass":  cm.stateroomClass,
 		"datelabel":       cm.dateLabel,
+		"departureday":    cm.departureDay,
 		"ship":            cm.ship,
 		"departureport":   cm.departurePort,
 		"days":            cm.days,
 		"shipcode":        cm.shipCode,
 		"destinationcode": cm.destinationCode,
-	}).Set(cm.price)
+	}
+	for k, v := range cm.labels {
+		labels[k] = v
+	}
+	return labels
 }
 
-func (hc *Exporter) fetchStats(url string) {
+func (hc *Exporter) updateCustomMetrics(cm *customMetric) error {
+	// log.Printf("Updating custom metrics: url: %s, connect: %s, dns: %s, firstbyte: %s, total: %s, status: %.0f",
+	// 	cm.url,
+	// 	cm.connect,
+	// 	cm.dns,
+	// 	cm.firstbyte,
+	// 	cm.total,
+	// 	cm.status,
+	// )
+	urlLabels := prometheus.Labels{
+		"url": cm.url,
+	}
+	for k, v := range cm.labels {
+		urlLabels[k] = v
+	}
+	for _, m := range []struct {
+		guard *seriesGuard
+		value float64
+	}{
+		{hc.urlDNS, cm.dns.Seconds()},
+		{hc.urlConnectTime, cm.connect.Seconds()},
+		{hc.urlResponse, cm.total.Seconds()},
+		{hc.urlFirstByte, cm.firstbyte.Seconds()},
+		{hc.urlStatus, cm.status},
+	} {
+		if err := m.guard.set(urlLabels, m.value); err != nil {
+			return err
+		}
+	}
+	priceLabels := cm.priceLabels()
+	if !hc.dropRawPrices {
+		if err := hc.royalPrice.set(priceLabels, cm.price); err != nil {
+			return err
+		}
+		if hc.cabinTotalPrice != nil {
+			if err := hc.cabinTotalPrice.set(priceLabels, cm.price*float64(hc.cabinGuests)); err != nil {
+				return err
+			}
+		}
+		if hc.quotedPrice != nil {
+			if err := hc.quotedPrice.set(priceLabels, cm.quotedPrice); err != nil {
+				return err
+			}
+		}
+	}
+	if err := hc.observePrice(priceLabels, cm.price); err != nil {
+		return err
+	}
+	if err := hc.exportDerived(priceLabels, cm.price); err != nil {
+		return err
+	}
+	hc.evaluateWatches(priceLabels, cm.price, cm.bookingLink)
+	return nil
+}
 
-	var start, connect, dns time.Time
+func (hc *Exporter) fetchStats(ctx context.Context, t config.Target) (report TargetReport) {
+	report = TargetReport{Name: t.Name, URL: t.URL}
+	defer func(began time.Time) {
+		report.DurationSeconds = time.Since(began).Seconds()
+	}(time.Now())
+
+	if exhausted, reset := hc.budgetExhausted(t); exhausted {
+		hc.logger.Printf("skipping scrape of %s, its daily request budget is used up until %s", t.Name, reset.Format(time.RFC3339))
+		report.Skipped = true
+		return report
+	}
 
-	var connectMS, dnsMS, firstbyteMS, totalMS, status float64
+	if hc.scrapeBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hc.scrapeBudget)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	count := hc.currentPageSize(t)
+	st := newScrapeState()
+	defer hc.exportPageLatency(t, st)
+	// export handles a fetched page, returning false when the scrape has to stop
+	export := func(data *royalapi.Response, timing urlTiming, skip int, err error) bool {
+		if err != nil && report.Pages > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// keep what the previous pages exported
+			hc.logger.Printf("scrape budget of %s exceeded for %s after %d pages", hc.scrapeBudget, t.Name, report.Pages)
+			report.Partial = true
+			hc.scrapePartial.WithLabelValues(t.Name).Set(1)
+			return false
+		}
+		if err != nil && report.Pages > 0 && errors.Is(err, errBudgetExhausted) {
+			hc.logger.Printf("daily request budget of %s used up after %d pages", t.Name, report.Pages)
+			report.Partial = true
+			hc.scrapePartial.WithLabelValues(t.Name).Set(1)
+			return false
+		}
+		if err != nil {
+			hc.logger.Println(err)
+			report.Error = err.Error()
+			return false
+		}
+		report.Pages++
+
+		st.timing = timing
+		st.latencies = append(st.latencies, timing.total.Seconds())
+		if err := hc.exportCruises(t, data.Cruises(), st, &report); err != nil {
+			hc.logger.Printf("refusing scrape of %s: %s", t.Name, err)
+			report.Error = err.Error()
+			return false
+		}
+		hc.progressf("pulled down %d skipping the first %d of %d total scrape_id=%s", count, skip, data.Total(), traceOf(ctx).id())
+		return true
+	}
+
+	// The first page settles the page size and tells how many more pages
+	// there are, those are fetched up to pageConcurrency at a time and
+	// exported in order.
+	search := func(filters string) bool {
+		data, timing, err := hc.fetchTimedPage(ctx, t, filters, 0, count)
+		for count > minPageSize && pageRejected(data, count, err) && ctx.Err() == nil {
+			smaller := count / 2
+			if smaller < minPageSize {
+				smaller = minPageSize
+			}
+			hc.logger.Printf("%s rejected or truncated a page of %d, trying %d", t.Name, count, smaller)
+			count = smaller
+			data, timing, err = hc.fetchTimedPage(ctx, t, filters, 0, count)
+		}
+		if err == nil {
+			hc.setPageSize(t.Name, count)
+		}
+		if !export(data, timing, 0, err) {
+			return false
+		}
+		var pages []*pageResult
+		for skip := count; skip < data.Total(); skip += count {
+			pages = append(pages, &pageResult{skip: skip, done: make(chan struct{})})
+		}
+		go hc.fetchPages(ctx, t, filters, count, pages)
+		for _, p := range pages {
+			<-p.done
+			if !export(p.data, p.timing, p.skip, p.err) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, filters := range hc.searchFilters() {
+		if !search(filters) {
+			return report
+		}
+	}
+
+	hc.scrapePartial.WithLabelValues(t.Name).Set(0)
+	hc.finishScrape(t, st, &report)
+	hc.saveCatalog(t, st)
+	return report
+}
+
+// pageResult is a page fetched in the background, ready once done is closed.
+type pageResult struct {
+	skip   int
+	data   *royalapi.Response
+	timing urlTiming
+	err    error
+	done   chan struct{}
+}
+
+// fetchPages fetches pages in order, at most pageConcurrency at a time unless
+// the target sets its own. Pages not started when ctx is done fail with its
+// error.
+func (hc *Exporter) fetchPages(ctx context.Context, t config.Target, filters string, count int, pages []*pageResult) {
+	concurrency := hc.pageConcurrency
+	if t.PageConcurrency > 0 {
+		concurrency = t.PageConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+	for _, p := range pages {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			p.err = ctx.Err()
+			close(p.done)
+			continue
+		}
+		go func(p *pageResult) {
+			defer func() { <-sem }()
+			// the page runs on its own goroutine, out of reach of the
+			// recover of the target
+			if hc.recovered("scrape", func() { p.data, p.timing, p.err = hc.fetchTimedPage(ctx, t, filters, p.skip, count) }) {
+				p.err = fmt.Errorf("panic fetching page %d of %s", p.skip/count+1, t.Name)
+			}
+			close(p.done)
+		}(p)
+	}
+}
+
+// fetchTimedPage fetches a page and times the phases of the request.
+func (hc *Exporter) fetchTimedPage(ctx context.Context, t config.Target, filters string, skip, count int) (*royalapi.Response, urlTiming, error) {
+	var timing urlTiming
+	var start, connect, dns time.Time
 
 	trace := &httptrace.ClientTrace{
 		DNSStart: func(dsi httptrace.DNSStartInfo) { dns = time.Now() },
 		DNSDone: func(ddi httptrace.DNSDoneInfo) {
-			dnsMS = float64(time.Since(dns).Milliseconds())
+			timing.dns = time.Since(dns)
 		},
 
 		ConnectStart: func(network, addr string) { connect = time.Now() },
 		ConnectDone: func(network, addr string, err error) {
-			connectMS = float64(time.Since(connect).Milliseconds())
+			timing.connect = time.Since(connect)
 		},
 
 		GotFirstResponseByte: func() {
-			firstbyteMS = float64(time.Since(start).Milliseconds())
+			timing.firstbyte = time.Since(start)
 		},
 	}
 
-	count := 20 // Set the number of results per page
-	skip := 0   // Start with the first page
+	start = time.Now()
+	ctx, id := withRequestID(ctx)
+	ctx, span := hc.startSpan(ctx, "page", map[string]string{"target": t.Name, "request_id": id, "skip": strconv.Itoa(skip), "count": strconv.Itoa(count)})
+	data, err := hc.fetchPage(httptrace.WithClientTrace(ctx, trace), t, filters, skip, count)
+	timing.total = time.Since(start)
+	span.End(err)
+	if err != nil {
+		err = traceOf(ctx).traced(err, id)
+	}
+	return data, timing, err
+}
 
-	for {
-		jsonData := map[string]interface{}{
-			"operationName": "cruiseSearch_Cruises",
-			"variables": map[string]interface{}{
-				"sort": map[string]interface{}{
-					"by": "RECOMMENDED",
-				},
-				"pagination": map[string]interface{}{
-					"count": count,
-					"skip":  skip,
-				},
-			},
-			"query": "query cruiseSearch_Cruises($filters: String, $qualifiers: String, $sort: CruiseSearchSort, $pagination: CruiseSearchPagination) { cruiseSearch( filters: $filters qualifiers: $qualifiers sort: $sort pagination: $pagination ) { results { cruises { id productViewLink lowestPriceSailing { bookingLink id lowestStateroomClassPrice { price { value __typename } stateroomClass { id __typename } __typename } sailDate startDate endDate taxesAndFees { value __typename } taxesAndFeesIncluded __typename } masterSailing { itinerary { code media { images { path __typename } __typename } days { number type ports { activity arrivalTime departureTime port { code name region media { images { path __typename } __typename } __typename } __typename } __typename } departurePort { code name region __typename } destination { code name __typename } name postTour { days { number type ports { activity arrivalTime departureTime port { code name region __typename } __typename } __typename } duration __typename } preTour { days { number type ports { activity arrivalTime departureTime port { code name region __typename } __typename } __typename } duration __typename } sailingNights ship { code name stateroomClasses { id name content { amenities area code maxCapacity media { images { path meta { description title location __typename } __typename } __typename } superCategory __typename } __typename } media { images { path __typename } __typename } __typename } totalNights type __typename } __typename } sailings { bookingLink id itinerary { code __typename } sailDate startDate endDate stateroomClassPricing { price { value __typename } stateroomClass { id __typename } __typename } __typename } __typename } cruiseRecommendationId total __typename } __typename } }",
-		}
-
-		jsonValue, _ := json.Marshal(jsonData)
-
-		// Create an HTTP request with the JSON data and custom User-Agent header.
-		req, err := http.NewRequestWithContext(httptrace.WithClientTrace(hc.ctx, trace), "POST", url, bytes.NewBuffer(jsonValue))
-		if err != nil {
-			log.Println("Error creating request:", err)
-			return
-		}
+// urlTiming holds the request timings exported with every price.
+type urlTiming struct {
+	dns, connect, firstbyte, total time.Duration
+	status                         float64
+}
 
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15")
+// scrapeState is what one scrape of a target accumulates across pages.
+type scrapeState struct {
+	timing    urlTiming
+	latencies []float64
+	// seen are the IDs of the cruises of the pages exported so far
+	seen map[string]bool
+	// coverage counts what was parsed by kind and result, see coverage
+	coverage map[[2]string]int
+	lowest   map[string]int
+	scraped  map[string]*customMetric
+	cruises  []royalapi.Cruise
+}
 
-		start = time.Now()
-		// Send the HTTP request.
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Println("Error sending request:", err)
-			return
-		}
-		defer resp.Body.Close()
+func newScrapeState() *scrapeState {
+	return &scrapeState{seen: map[string]bool{}, coverage: map[[2]string]int{}, lowest: map[string]int{}, scraped: map[string]*customMetric{}}
+}
 
-		bodyText, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Println("Error reading response:", err)
-		}
-
-		var data CruiseSearch
-		json.Unmarshal(bodyText, &data)
-
-		for _, s := range data.Data.CruiseSearch.Results.Cruises {
-			for _, sc := range s.Sailings {
-				for _, stateroom := range sc.StateroomClassPricing {
-					if stateroom.Price.Value > 0 {
-						hc.updateCustomMetrics(
-							&customMetric{
-								url:             url,
-								dnsMS:           dnsMS,
-								connectMS:       connectMS,
-								firstbyteMS:     firstbyteMS,
-								totalMS:         totalMS,
-								status:          status,
-								price:           float64(stateroom.Price.Value),
-								cruiseID:        s.ID,
-								itinerary:       sc.Itinerary.Code,
-								stateroomClass:  stateroom.StateroomClass.ID,
-								dateLabel:       sc.SailDate,
-								ship:            s.MasterSailing.Itinerary.Ship.Name,
-								departurePort:   s.MasterSailing.Itinerary.DeparturePort.Name,
-								days:            strconv.Itoa(s.MasterSailing.Itinerary.TotalNights),
-								shipCode:        s.MasterSailing.Itinerary.Ship.Code,
-								destinationCode: s.MasterSailing.Itinerary.Destination.Code,
-							},
-						)
+// exportCruises exports the prices and derived metrics of one page.
+func (hc *Exporter) exportCruises(t config.Target, cruises []royalapi.Cruise, st *scrapeState, report *TargetReport) error {
+	parsed := len(cruises)
+	cruises = hc.dedupeCruises(t, cruises, st, report)
+	st.count("cruise", "duplicate", parsed-len(cruises))
+	if hc.catalogs != nil {
+		st.cruises = append(st.cruises, cruises...)
+	}
+	for _, s := range cruises {
+		if s.ID == "" {
+			st.count("cruise", "missing_fields", 1)
+			continue
+		}
+		st.count("cruise", "exported", 1)
+		report.Cruises++
+		if err := hc.checkLowestPrice(t, s); err != nil {
+			return err
+		}
+		for _, sc := range s.Sailings {
+			if sc.SailDate == "" {
+				st.count("sailing", "missing_fields", 1)
+				continue
+			}
+			st.count("sailing", "exported", 1)
+			report.Sailings++
+			hc.checkItinerary(t, s, sc)
+			if err := hc.tagHolidays(t, s, sc); err != nil {
+				return err
+			}
+			for _, stateroom := range sc.StateroomClassPricing {
+				if stateroom.StateroomClass.ID == "" {
+					st.count("stateroom", "missing_fields", 1)
+					continue
+				}
+				if stateroom.Price.Value <= 0 {
+					st.count("stateroom", "zero_price", 1)
+					continue
+				}
+				// the same sailing and stateroom class can show up more than
+				// once, keep the lowest price instead of the last one
+				key := strings.Join([]string{s.ID, sc.Itinerary.Code, stateroom.StateroomClass.ID, sc.SailDate}, "\x00")
+				if price, ok := st.lowest[key]; ok {
+					hc.duplicates.WithLabelValues(t.Name).Inc()
+					st.count("stateroom", "duplicate", 1)
+					if stateroom.Price.Value >= price {
+						continue
 					}
 				}
+				st.lowest[key] = stateroom.Price.Value
+				cm := hc.newPriceMetric(t, s, sc, stateroom)
+				cm.dns, cm.connect, cm.firstbyte, cm.total, cm.status = st.timing.dns, st.timing.connect, st.timing.firstbyte, st.timing.total, st.timing.status
+				if err := hc.updateCustomMetrics(cm); err != nil {
+					return err
+				}
+				report.Series++
+				if _, ok := st.scraped[key]; !ok {
+					st.count("stateroom", "exported", 1)
+				}
+				st.scraped[key] = cm
 			}
 		}
+	}
+	return nil
+}
 
-		log.Printf("pulled down %d skipping the first %d of %d total", count, skip, data.Data.CruiseSearch.Results.Total)
-		if skip < (data.Data.CruiseSearch.Results.Total - 20) {
-			skip = skip + 20
-		} else {
-			break
+// dedupeCruises drops the cruises an earlier page of the scrape already had,
+// which an unstable upstream sort shifts from one page onto the next.
+func (hc *Exporter) dedupeCruises(t config.Target, cruises []royalapi.Cruise, st *scrapeState, report *TargetReport) []royalapi.Cruise {
+	fresh := cruises[:0:0]
+	for _, c := range cruises {
+		if st.seen[c.ID] {
+			continue
+		}
+		st.seen[c.ID] = true
+		fresh = append(fresh, c)
+	}
+	if dropped := len(cruises) - len(fresh); dropped > 0 {
+		hc.progressf("page of %s repeats %d cruises of earlier pages, skipping them", t.Name, dropped)
+		hc.duplicateCruises.WithLabelValues(t.Name).Add(float64(dropped))
+		report.DuplicateCruises += dropped
+	}
+	return fresh
+}
+
+// finishScrape exports what needs every page of a complete scrape.
+func (hc *Exporter) finishScrape(t config.Target, st *scrapeState, report *TargetReport) {
+	hc.recordDiff(t, st.scraped)
+	hc.recordChecksum(t, st.scraped, report)
+	hc.exportCoverage(t, st)
+	if len(hc.rollups) > 0 {
+		prices := make([]prometheus.Labels, 0, len(st.scraped))
+		values := make([]float64, 0, len(st.scraped))
+		for _, cm := range st.scraped {
+			prices = append(prices, cm.priceLabels())
+			values = append(values, cm.price)
 		}
+		if err := hc.exportRollups(t, prices, values); err != nil {
+			hc.logger.Printf("refusing rollups of %s: %s", t.Name, err)
+			report.Error = err.Error()
+		}
+	}
+	if err := hc.exportRegions(t, st.scraped); err != nil {
+		hc.logger.Printf("refusing region overview of %s: %s", t.Name, err)
+		report.Error = err.Error()
+	}
+	if hc.superCategoryPrice != nil {
+		if err := hc.exportSuperCategories(t, st.scraped); err != nil {
+			hc.logger.Printf("refusing super category prices of %s: %s", t.Name, err)
+			report.Error = err.Error()
+		}
+	}
+}
+
+// newPriceMetric describes the price of one stateroom class of a sailing.
+func (hc *Exporter) newPriceMetric(t config.Target, c royalapi.Cruise, s royalapi.Sailing, p royalapi.StateroomClassPrice) *customMetric {
+	return &customMetric{
+		url:             t.URL,
+		labels:          hc.targetLabels(t),
+		price:           hc.normalizePrice(c, float64(p.Price.Value)),
+		quotedPrice:     float64(p.Price.Value),
+		cruiseID:        c.ID,
+		itinerary:       s.Itinerary.Code,
+		stateroomClass:  p.StateroomClass.ID,
+		dateLabel:       hc.dateLabel(s.SailDate),
+		departureDay:    hc.departureDay(s.SailDate),
+		ship:            c.MasterSailing.Itinerary.Ship.Name,
+		departurePort:   c.MasterSailing.Itinerary.DeparturePort.Name,
+		days:            strconv.Itoa(c.MasterSailing.Itinerary.TotalNights),
+		shipCode:        c.MasterSailing.Itinerary.Ship.Code,
+		destinationCode: c.MasterSailing.Itinerary.Destination.Code,
+		region:          regionOf(c),
+		bookingLink:     s.BookingLink,
+	}
+}
+
+// checkLowestPrice compares the advertised lowest price of a cruise with the
+// prices of its sailings. A difference usually means the model drifted or a
+// promotion is only applied on one side.
+func (hc *Exporter) checkLowestPrice(t config.Target, c royalapi.Cruise) error {
+	advertised := c.LowestPriceSailing.LowestStateroomClassPrice.Price.Value
+	min := c.MinSailingPrice()
+	if advertised <= 0 || min <= 0 {
+		return nil
+	}
+	labels := prometheus.Labels{"url": t.URL, "cruiseid": c.ID}
+	for k, v := range hc.targetLabels(t) {
+		labels[k] = v
+	}
+	return hc.priceMismatch.set(labels, float64(advertised-min))
+}
+
+// fetchPage requests one page of search results. With persisted queries
+// enabled only the query hash is sent, falling back to the full document when
+// the server doesn't know it yet.
+func (hc *Exporter) fetchPage(ctx context.Context, t config.Target, filters string, skip, count int) (*royalapi.Response, error) {
+	variables := royalapi.Variables{
+		Filters:    filters,
+		Sort:       royalapi.Sort{By: hc.sortOf(t)},
+		Pagination: royalapi.Pagination{Count: count, Skip: skip},
+	}
+
+	key := pageKey(t, variables)
+	if data, ok := hc.pages.get(key); ok {
+		hc.cacheHits.WithLabelValues(t.Name, "ttl").Inc()
+		return data, nil
+	}
+	cached := hc.validators.get(key)
+
+	query := hc.queryOf(t)
+	request := query.Request(variables)
+	if hc.persistedQueries {
+		request = query.PersistedRequest(variables, false)
+	}
+	body, header, err := hc.post(ctx, t, skip, request, cached)
+	defer putBuffer(body)
+	if errors.Is(err, errNotModified) {
+		hc.cacheHits.WithLabelValues(t.Name, "conditional").Inc()
+		hc.pages.put(key, cached.data)
+		return cached.data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := royalapi.Parse(body.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errParse, err)
+	}
+
+	if hc.persistedQueries && data.PersistedQueryNotFound() {
+		hc.logger.Printf("persisted query %s not found on %s, sending the full query", query.Hash(), t.Name)
+		if body, header, err = hc.post(ctx, t, skip, query.PersistedRequest(variables, true), nil); err != nil {
+			return nil, err
+		}
+		defer putBuffer(body)
+		if data, err = royalapi.Parse(body.Bytes()); err != nil {
+			return nil, fmt.Errorf("%w: %s", errParse, err)
+		}
+	}
+
+	// the drift check decodes the whole body again by reflection, so it only
+	// samples the first page of each scrape
+	if skip == 0 {
+		hc.checkSchema(t.URL, body.Bytes(), data, query)
+	}
+	hc.pages.put(key, data)
+	hc.validators.put(key, header, data)
+	return data, nil
+}
+
+// post sends request to the target under the request ID of ctx, or a new one
+// which errors then mention along with the scrape ID. The request is
+// conditional on cached when it is set, errNotModified meaning the cached
+// page is still current. The body comes from the buffer pool and goes back
+// with putBuffer.
+func (hc *Exporter) post(ctx context.Context, t config.Target, skip int, request interface{}, cached *validator) (*bytes.Buffer, http.Header, error) {
+	id, ok := requestIDOf(ctx)
+	if !ok {
+		id = traceOf(ctx).newRequest()
+	}
+	ctx, span := hc.startSpan(ctx, "request", map[string]string{"target": t.Name, "url": t.URL, "request_id": id})
+	body, header, err := hc.postRequest(ctx, t, id, skip, request, cached)
+	if errors.Is(err, errNotModified) {
+		span.End(nil)
+		return body, header, err
+	}
+	span.End(err)
+	if err != nil && !ok {
+		err = traceOf(ctx).traced(err, id)
+	}
+	return body, header, err
+}
+
+func (hc *Exporter) postRequest(ctx context.Context, t config.Target, id string, skip int, request interface{}, cached *validator) (*bytes.Buffer, http.Header, error) {
+	jsonValue, _ := json.Marshal(request)
+
+	// the address family the request went out over labels its latency
+	var family string
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { family = addressFamily(info.Conn.RemoteAddr()) },
+	})
+
+	// Create an HTTP request with the JSON data and custom User-Agent header.
+	req, err := http.NewRequestWithContext(ctx, "POST", t.URL, bytes.NewBuffer(jsonValue))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error creating request: %w", err)
 	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(requestIDHeader, id)
+	cached.setHeaders(req)
+	if err := hc.applySession(ctx, req); err != nil {
+		return nil, nil, err
+	}
+
+	if err := hc.takeRequest(t); err != nil {
+		return nil, nil, err
+	}
+
+	// Send the HTTP request.
+	hc.inFlight.Inc()
+	sent := time.Now()
+	resp, err := hc.clientFor(t).Do(req)
+	hc.inFlight.Dec()
+	hc.observeRequest(ctx, t, id, family, time.Since(sent))
+	if err != nil {
+		hc.httpRequests.WithLabelValues(t.Name, "error").Inc()
+		return nil, nil, fmt.Errorf("Error sending request: %w", err)
+	}
+	hc.httpRequests.WithLabelValues(t.Name, strconv.Itoa(resp.StatusCode)).Inc()
+	defer resp.Body.Close()
+	if hc.session != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+		// the next request starts a new session
+		hc.session.invalidate()
+		return nil, nil, fmt.Errorf("session rejected by %s: %s", t.Name, resp.Status)
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return nil, nil, errNotModified
+	}
+
+	body := getBuffer()
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		putBuffer(body)
+		return nil, nil, fmt.Errorf("Error reading response: %w", err)
+	}
+	if hc.responses != nil {
+		hc.responses.add(t.Name, rawResponse{received: time.Now(), requestID: id, status: resp.StatusCode, skip: skip, body: append([]byte(nil), body.Bytes()...)})
+	}
+	return body, resp.Header, nil
 }
 
 func (hc *Exporter) StartCollector() {
 	ticker := time.NewTicker(hc.healthcheck_invertval)
-	log.Println("starting exporter")
-	for _, u := range hc.urls {
-		hc.fetchStats(u)
+	hc.logger.Println("starting exporter")
+	hc.recovered("cycle", hc.scrapeIfLeader)
+	if hc.digest != nil {
+		go hc.runDigest()
+	}
+	if hc.outboxRetry > 0 {
+		go hc.notifier.Retry(hc.ctx, hc.outboxRetry)
 	}
-	go func() {
+	go hc.supervise("collector", func() {
 		for {
 			select {
 			case <-ticker.C:
-				for _, u := range hc.urls {
-					hc.fetchStats(u)
-				}
+				// A cycle slower than the interval must not delay the
+				// next one, targets still being scraped are skipped.
+				go hc.recovered("cycle", hc.scrapeIfLeader)
 			case <-hc.ctx.Done():
-				log.Println("Gracefully stopping exporter")
+				hc.logger.Println("Gracefully stopping exporter")
 				return
 			}
 		}
-	}()
+	})
+}
+
+// isLeader reports whether this replica should scrape, which it always
+// should without leader election.
+func (hc *Exporter) isLeader() bool {
+	leading := hc.elector == nil || hc.elector.IsLeader()
+	if leading {
+		hc.leaderGauge.Set(1)
+	} else {
+		hc.leaderGauge.Set(0)
+	}
+	return leading
+}
+
+// notify sends e through the notifiers named, leaving it to the leader when
+// replicas export the same shared catalog.
+func (hc *Exporter) notify(names []string, e notify.Event) {
+	if hc.isLeader() {
+		hc.notifier.Send(hc.ctx, names, e)
+	}
+}
+
+// scrapeIfLeader scrapes when leading, otherwise it exports the catalogs the
+// leader shared if there are any.
+func (hc *Exporter) scrapeIfLeader() {
+	if hc.isLeader() {
+		if !hc.inScrapeWindow() || hc.backingOff(time.Now()) {
+			return
+		}
+		hc.recordCycle(hc.ScrapeOnce(), time.Now())
+	} else if hc.catalogs != nil {
+		hc.cycle(func(ctx context.Context, t config.Target) TargetReport { return hc.syncCatalog(t) })
+	}
+}
+
+// ScrapeOnce scrapes every target once and returns the summary of the cycle,
+// which is also logged and served on /api/v1/last-scrape.
+func (hc *Exporter) ScrapeOnce() ScrapeReport {
+	return hc.cycle(func(ctx context.Context, t config.Target) TargetReport {
+		report := hc.fetchStats(ctx, t)
+		if !report.Skipped {
+			hc.runOperations(ctx, t)
+			hc.fetchPricingCalendars(ctx, t)
+		}
+		return report
+	})
+}
+
+// cycle runs fetch for every target that isn't already being fetched. The
+// context of every fetch carries the scrape ID of the cycle. Cycles don't
+// overlap, one starting while another runs skips every target, and only a
+// cycle that fetched a target replaces the last scrape and the snapshot.
+func (hc *Exporter) cycle(fetch func(context.Context, config.Target) TargetReport) ScrapeReport {
+	report := ScrapeReport{ID: newID(), Start: time.Now(), Targets: []TargetReport{}}
+	running := !atomic.CompareAndSwapInt32(&hc.cycling, 0, 1)
+	if !running {
+		defer atomic.StoreInt32(&hc.cycling, 0)
+	}
+	for _, t := range hc.currentTargets() {
+		if running || !hc.lockTarget(t.Name) {
+			hc.logger.Printf("skipping scrape of %s, the previous one is still running", t.Name)
+			hc.scrapesSkipped.WithLabelValues(t.Name).Inc()
+			report.add(TargetReport{Name: t.Name, URL: t.URL, Skipped: true})
+			continue
+		}
+		ctx, trace := withTrace(hc.ctx, report.ID)
+		ctx, span := hc.startSpan(ctx, "scrape", map[string]string{"target": t.Name})
+		tr := hc.fetchRecovered(fetch, ctx, t)
+		tr.RequestIDs = trace.requestIDs()
+		var err error
+		if tr.Error != "" {
+			err = errors.New(tr.Error)
+		}
+		span.End(err)
+		report.add(tr)
+		hc.recordOutcome(tr, time.Now())
+		hc.unlockTarget(t.Name)
+	}
+	report.DurationSeconds = time.Since(report.Start).Seconds()
+	if report.Errors > 0 || report.Partial > 0 {
+		hc.logger.Println(report.String())
+	} else {
+		hc.progressf("%s", report.String())
+	}
+	if report.Skipped == len(report.Targets) {
+		return report
+	}
+	hc.lastScrape.Store(report)
+	hc.expireSailings(time.Now())
+	hc.flushAlerts(time.Now())
+	hc.expireSeries(report)
+	hc.takeSnapshot()
+	if hc.history != nil {
+		hc.history.Expire(time.Now())
+		if hc.historyFile != "" {
+			if err := hc.history.Save(hc.historyFile); err != nil {
+				hc.logger.Printf("error saving history: %s", err)
+			}
+		}
+	}
+	return report
+}
+
+// takeSnapshot swaps in the current series as the ones /metrics serves.
+func (hc *Exporter) takeSnapshot() {
+	if err := hc.snapshot.take(); err != nil {
+		hc.logger.Printf("error taking metrics snapshot: %s", err)
+	}
+}
+
+// lockTarget marks the target as being scraped, returning false when it
+// already is.
+func (hc *Exporter) lockTarget(name string) bool {
+	hc.scrapingMu.Lock()
+	defer hc.scrapingMu.Unlock()
+	if _, ok := hc.scraping[name]; ok {
+		return false
+	}
+	hc.scraping[name] = time.Now()
+	return true
+}
+
+func (hc *Exporter) unlockTarget(name string) {
+	hc.scrapingMu.Lock()
+	defer hc.scrapingMu.Unlock()
+	delete(hc.scraping, name)
+}
+
+// Shutdown waits for running scrapes, which stop once the context passed to
+// NewExporter is cancelled, then saves the history and delivers the queued
+// notifications. It gives up when ctx is done.
+func (hc *Exporter) Shutdown(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		hc.scrapingMu.Lock()
+		running := len(hc.scraping)
+		hc.scrapingMu.Unlock()
+		if running == 0 {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%d scrapes still running: %w", running, ctx.Err())
+		}
+	}
+	if hc.history != nil && hc.historyFile != "" {
+		if err := hc.history.Save(hc.historyFile); err != nil {
+			return fmt.Errorf("error saving history: %w", err)
+		}
+	}
+	if err := hc.saveWatchState(); err != nil {
+		return fmt.Errorf("error saving watch state: %w", err)
+	}
+	return hc.notifier.Flush(ctx)
+}
+
+// Hung reports whether a scrape has been running for much longer than it
+// should, five intervals or twice the scrape budget.
+func (hc *Exporter) Hung() bool {
+	limit := 5 * hc.healthcheck_invertval
+	if 2*hc.scrapeBudget > limit {
+		limit = 2 * hc.scrapeBudget
+	}
+	hc.scrapingMu.Lock()
+	defer hc.scrapingMu.Unlock()
+	for name, started := range hc.scraping {
+		if time.Since(started) > limit {
+			hc.logger.Printf("scrape of %s has been running since %s", name, started.Format(time.RFC3339))
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,125 @@
+package exporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// feedSize is the number of entries /feed.atom keeps.
+const feedSize = 200
+
+// feedEntry is a price change or new sailing of a watched series.
+type feedEntry struct {
+	id      string
+	title   string
+	text    string
+	link    string
+	updated time.Time
+}
+
+// feed holds the latest entries, newest last.
+type feed struct {
+	mu      sync.Mutex
+	entries []feedEntry
+}
+
+func (f *feed) add(e feedEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e.id = fmt.Sprintf("tag:royalcaribbean-prometheus-exporter,%s:%d", e.updated.UTC().Format("2006-01-02"), e.updated.UnixNano())
+	f.entries = append(f.entries, e)
+	if len(f.entries) > feedSize {
+		f.entries = append(f.entries[:0:0], f.entries[len(f.entries)-feedSize:]...)
+	}
+}
+
+// recordPriceChange adds a feed entry for a watched series that is new, or
+// whose price changed. Series seen by the first scrape aren't new.
+func (hc *Exporter) recordPriceChange(labels map[string]string, seen bool, previous, price float64, link string, now time.Time) {
+	var title string
+	switch {
+	case !seen:
+		if _, primed := hc.lastScrape.Load().(ScrapeReport); !primed {
+			return
+		}
+		title = fmt.Sprintf("New sailing: %s %s %s at %.0f", labels["ship"], labels["datelabel"], labels["stateroomclass"], price)
+	case previous != price:
+		title = fmt.Sprintf("%s %s %s: %.0f to %.0f", labels["ship"], labels["datelabel"], labels["stateroomclass"], previous, price)
+	default:
+		return
+	}
+	hc.feed.add(feedEntry{
+		title: title,
+		text: fmt.Sprintf("Stateroom class %s of cruise %s, %s nights from %s on %s, is %.0f.",
+			labels["stateroomclass"], labels["cruiseid"], labels["days"], labels["departureport"], labels["datelabel"], price),
+		link:    hc.absoluteLink(link),
+		updated: now,
+	})
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string    `xml:"id"`
+	Title   string    `xml:"title"`
+	Updated string    `xml:"updated"`
+	Link    *atomLink `xml:"link,omitempty"`
+	Summary string    `xml:"summary"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+// serveFeed serves the recent price changes and new sailings of watched
+// series as an Atom feed, newest first.
+func (hc *Exporter) serveFeed(w http.ResponseWriter, r *http.Request) {
+	hc.feed.mu.Lock()
+	entries := append([]feedEntry(nil), hc.feed.entries...)
+	hc.feed.mu.Unlock()
+
+	updated := time.Now()
+	if len(entries) > 0 {
+		updated = entries[len(entries)-1].updated
+	}
+	out := atomFeed{
+		ID:      "tag:royalcaribbean-prometheus-exporter,2024:price-changes",
+		Title:   "Watched sailing price changes",
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "royalcaribbean-prometheus-exporter"},
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		entry := atomEntry{
+			ID:      e.id,
+			Title:   e.title,
+			Updated: e.updated.UTC().Format(time.RFC3339),
+			Summary: e.text,
+		}
+		if e.link != "" {
+			entry.Link = &atomLink{Href: e.link}
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		hc.logger.Printf("error encoding feed: %s", err)
+	}
+}
@@ -0,0 +1,244 @@
+package exporter
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	SeriesLimitDrop   = "drop"
+	SeriesLimitRefuse = "refuse"
+)
+
+var errSeriesLimit = errors.New("series limit reached")
+
+type guardedSeries struct {
+	key string
+	// target is the url label before relabeling, the target that set the
+	// series.
+	target  string
+	labels  prometheus.Labels
+	value   float64
+	updated time.Time
+	elem    *list.Element
+}
+
+// seriesGuard wraps a GaugeVec and caps the number of series it holds. When
+// the limit is reached the series set the longest ago, which the catalog
+// most likely dropped, are evicted first.
+type seriesGuard struct {
+	mu     sync.Mutex
+	name   string
+	vec    *prometheus.GaugeVec
+	rules  []config.RelabelConfig
+	limit  int
+	action string
+	series map[string]*guardedSeries
+	// order holds the series from the most to the least recently set.
+	order    *list.List
+	dropped  *prometheus.CounterVec
+	limiting bool
+	logger   *log.Logger
+	// aliases are set along with the guard, see WithLegacyMetricNames.
+	aliases []metricAlias
+}
+
+// metricAlias exports the series of a guard under another name, the values
+// multiplied by scale.
+type metricAlias struct {
+	guard *seriesGuard
+	scale float64
+}
+
+func newSeriesGuard(name string, vec *prometheus.GaugeVec, rules []config.RelabelConfig, dropped *prometheus.CounterVec, logger *log.Logger) *seriesGuard {
+	return &seriesGuard{
+		name:    name,
+		vec:     vec,
+		rules:   rules,
+		action:  SeriesLimitDrop,
+		series:  map[string]*guardedSeries{},
+		order:   list.New(),
+		dropped: dropped,
+		logger:  logger,
+	}
+}
+
+func seriesKey(labels prometheus.Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(labels[name])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+func (g *seriesGuard) set(labels prometheus.Labels, value float64) error {
+	for _, a := range g.aliases {
+		if err := a.guard.set(labels, value*a.scale); err != nil {
+			return err
+		}
+	}
+	target := labels["url"]
+	labels, keep := relabel(g.rules, g.name, labels)
+	if !keep {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := seriesKey(labels)
+	if s, ok := g.series[key]; ok || g.limit <= 0 || len(g.series) < g.limit {
+		if !ok {
+			s = &guardedSeries{key: key, target: target, labels: labels}
+			s.elem = g.order.PushFront(s)
+			g.series[key] = s
+		} else {
+			g.order.MoveToFront(s.elem)
+		}
+		s.value = value
+		s.updated = time.Now()
+		g.vec.With(labels).Set(value)
+		return nil
+	}
+
+	g.dropped.WithLabelValues(g.name).Inc()
+	if !g.limiting {
+		g.logger.Printf("series limit of %d reached for %s, applying %q", g.limit, g.name, g.action)
+		g.limiting = true
+	}
+	if g.action == SeriesLimitRefuse {
+		return fmt.Errorf("%s: %w", g.name, errSeriesLimit)
+	}
+
+	if oldest := g.order.Back(); oldest != nil {
+		g.remove(oldest.Value.(*guardedSeries))
+	}
+	s := &guardedSeries{key: key, target: target, labels: labels, value: value, updated: time.Now()}
+	s.elem = g.order.PushFront(s)
+	g.series[key] = s
+	g.vec.With(labels).Set(value)
+	return nil
+}
+
+// remove deletes s from the guard and the vector, with g.mu held.
+func (g *seriesGuard) remove(s *guardedSeries) {
+	g.vec.Delete(s.labels)
+	g.order.Remove(s.elem)
+	delete(g.series, s.key)
+}
+
+// expire removes the series last set before the cutoff of their target, or
+// the one of "" for other targets, and returns how many. A zero cutoff keeps
+// every series.
+func (g *seriesGuard) expire(cutoffs map[string]time.Time) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := 0
+	for _, s := range g.series {
+		cutoff, ok := cutoffs[s.target]
+		if !ok {
+			cutoff = cutoffs[""]
+		}
+		if s.updated.Before(cutoff) {
+			g.remove(s)
+			n++
+		}
+	}
+	return n
+}
+
+// updatedAt returns when every series was last set, by seriesKey.
+func (g *seriesGuard) updatedAt() map[string]time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	updated := make(map[string]time.Time, len(g.series))
+	for key, s := range g.series {
+		updated[key] = s.updated
+	}
+	return updated
+}
+
+// deleteMatching removes every series whose labels include all of match. A
+// series missing one of the labels, e.g. dropped by relabeling, doesn't match.
+func (g *seriesGuard) deleteMatching(match prometheus.Labels) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, s := range g.series {
+		matches := true
+		for k, v := range match {
+			if got, ok := s.labels[k]; !ok || got != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			g.remove(s)
+		}
+	}
+}
+
+func (hc *Exporter) guards() []*seriesGuard {
+	guards := []*seriesGuard{hc.urlStatus, hc.urlResponse, hc.urlDNS, hc.urlFirstByte, hc.urlConnectTime, hc.royalPrice, hc.priceMismatch, hc.regionSailings, hc.regionLowestPrice}
+	if hc.priceAnomaly != nil {
+		guards = append(guards, hc.priceAnomaly)
+	}
+	if hc.holidayInfo != nil {
+		guards = append(guards, hc.holidayInfo)
+	}
+	if hc.priceTrend != nil {
+		guards = append(guards, hc.priceTrend)
+	}
+	if hc.cabinTotalPrice != nil {
+		guards = append(guards, hc.cabinTotalPrice)
+	}
+	if hc.quotedPrice != nil {
+		guards = append(guards, hc.quotedPrice)
+	}
+	if hc.superCategoryPrice != nil {
+		guards = append(guards, hc.superCategoryPrice)
+	}
+	if hc.calendarPrice != nil {
+		guards = append(guards, hc.calendarPrice)
+	}
+	for _, op := range hc.operations {
+		for _, m := range op.metrics {
+			guards = append(guards, m.guard)
+		}
+	}
+	for _, d := range hc.derived {
+		guards = append(guards, d.guard)
+	}
+	for _, g := range guards {
+		for _, a := range g.aliases {
+			guards = append(guards, a.guard)
+		}
+	}
+	return guards
+}
+
+// seriesUpdated returns when the series of every guard were last set, by
+// metric name and seriesKey.
+func (hc *Exporter) seriesUpdated() map[string]map[string]time.Time {
+	updated := map[string]map[string]time.Time{}
+	for _, g := range hc.guards() {
+		updated[g.name] = g.updatedAt()
+	}
+	return updated
+}
@@ -0,0 +1,89 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exportertest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteMatchingRequiresEveryLabel(t *testing.T) {
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped"}, []string{"metric"})
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "g"}, []string{"site"})
+	g := newSeriesGuard("g", vec, nil, dropped, nil)
+	require.NoError(t, g.set(prometheus.Labels{"site": "a"}, 1))
+	require.NoError(t, g.set(prometheus.Labels{"site": "b"}, 2))
+
+	g.deleteMatching(prometheus.Labels{"url": "http://a", "site": "a"})
+	assert.Len(t, g.series, 2, "series without the url label must not match")
+
+	g.deleteMatching(prometheus.Labels{"site": "a"})
+	assert.Len(t, g.series, 1)
+}
+
+func TestLabelDropKeepsOtherTargetsSeries(t *testing.T) {
+	carib := exportertest.NewServer(exportertest.Cruise{
+		ID: "WN07RCI-1", Ship: "Wonder of the Seas", ShipCode: "WN", DeparturePort: "Port Canaveral", Destination: "CARIB", Nights: 7,
+		Sailings: []exportertest.Sailing{{ID: "A", Itinerary: "WN07W375", SailDate: "2036-01-12", Prices: map[string]int{"I": 899}}},
+	})
+	defer carib.Close()
+	baham := exportertest.NewServer(exportertest.Cruise{
+		ID: "IC03RCI-1", Ship: "Icon of the Seas", ShipCode: "IC", DeparturePort: "Miami", Destination: "BAHAM", Nights: 3,
+		Sailings: []exportertest.Sailing{{ID: "B", Itinerary: "IC03M001", SailDate: "2036-02-08", Prices: map[string]int{"I": 529}}},
+	})
+	defer baham.Close()
+
+	e, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithRelabelConfigs([]config.RelabelConfig{{Action: config.RelabelLabelDrop, Regex: config.MustNewRegexp("url")}}),
+		WithTargets(config.Target{Name: "carib", URL: carib.URL}, config.Target{Name: "baham", URL: baham.URL}),
+	)
+	require.NoError(t, err)
+
+	e.ScrapeOnce()
+	require.Len(t, e.royalPrice.series, 2)
+	require.Len(t, e.regionSailings.series, 1)
+
+	require.NoError(t, e.SetTargets([]config.Target{{Name: "carib", URL: carib.URL}}))
+	assert.Len(t, e.royalPrice.series, 2, "removing a target must not delete the series of the others")
+	assert.Len(t, e.regionSailings.series, 1)
+}
+
+func TestSeriesLimitDropsLeastRecentlySet(t *testing.T) {
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped"}, []string{"metric"})
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "g"}, []string{"site"})
+	g := newSeriesGuard("g", vec, nil, dropped, log.New(io.Discard, "", 0))
+	g.limit = 2
+
+	require.NoError(t, g.set(prometheus.Labels{"site": "a"}, 100))
+	require.NoError(t, g.set(prometheus.Labels{"site": "b"}, 1))
+	require.NoError(t, g.set(prometheus.Labels{"site": "a"}, 100))
+	require.NoError(t, g.set(prometheus.Labels{"site": "c"}, 1000))
+
+	assert.Contains(t, g.series, seriesKey(prometheus.Labels{"site": "a"}))
+	assert.Contains(t, g.series, seriesKey(prometheus.Labels{"site": "c"}))
+	assert.Equal(t, 2, g.order.Len())
+	assert.Equal(t, 2, testutil.CollectAndCount(vec))
+	assert.Equal(t, 1.0, testutil.ToFloat64(dropped.WithLabelValues("g")))
+}
+
+func TestSeriesLimitRefuse(t *testing.T) {
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped"}, []string{"metric"})
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "g"}, []string{"site"})
+	g := newSeriesGuard("g", vec, nil, dropped, log.New(io.Discard, "", 0))
+	g.limit, g.action = 1, SeriesLimitRefuse
+
+	require.NoError(t, g.set(prometheus.Labels{"site": "a"}, 1))
+	assert.ErrorIs(t, g.set(prometheus.Labels{"site": "b"}, 2), errSeriesLimit)
+	assert.NoError(t, g.set(prometheus.Labels{"site": "a"}, 3), "updating a series never hits the limit")
+	assert.Len(t, g.series, 1)
+}
@@ -0,0 +1,27 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// observePrice records an exported price in the history and judges it
+// against the samples recorded before it.
+func (hc *Exporter) observePrice(labels prometheus.Labels, price float64) error {
+	if hc.history == nil {
+		return nil
+	}
+	now := time.Now()
+	if hc.anomaly != nil {
+		past := hc.history.Samples(labels, now.Add(-hc.anomaly.Window))
+		if err := hc.checkAnomaly(labels, past, price); err != nil {
+			return err
+		}
+	}
+	hc.history.Add(labels, now, price)
+	if hc.trend != nil {
+		return hc.checkTrend(labels, now)
+	}
+	return nil
+}
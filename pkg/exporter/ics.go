@@ -0,0 +1,133 @@
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icsSailing is a watched sailing with the current price of every watched
+// stateroom class.
+type icsSailing struct {
+	labels  map[string]string
+	link    string
+	prices  map[string]float64
+	updated time.Time
+}
+
+// serveCalendar serves the watched sailings as an iCalendar feed, one all-day
+// event per sailing with the prices in its description.
+func (hc *Exporter) serveCalendar(w http.ResponseWriter, r *http.Request) {
+	sailings := map[string]*icsSailing{}
+	hc.firingMu.Lock()
+	for _, p := range hc.watchedPrices {
+		key := p.labels["url"] + "\x00" + p.labels["cruiseid"] + "\x00" + p.labels["datelabel"]
+		s, ok := sailings[key]
+		if !ok {
+			s = &icsSailing{labels: p.labels, prices: map[string]float64{}}
+			sailings[key] = s
+		}
+		s.prices[p.labels["stateroomclass"]] = p.price
+		if p.link != "" {
+			s.link = p.link
+		}
+		if p.evaluated.After(s.updated) {
+			s.updated = p.evaluated
+		}
+	}
+	hc.firingMu.Unlock()
+
+	keys := make([]string, 0, len(sailings))
+	for key := range sailings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	line := func(l string) {
+		b.WriteString(foldICS(l))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//royalcaribbean-prometheus-exporter//watched sailings//EN")
+	line("X-WR-CALNAME:Watched sailings")
+	for _, key := range keys {
+		s := sailings[key]
+		nights, _ := strconv.Atoi(s.labels["days"])
+		start, ok := hc.parseDateLabel(s.labels["datelabel"])
+		if !ok {
+			continue
+		}
+		end := start.AddDate(0, 0, nights)
+		classes := make([]string, 0, len(s.prices))
+		lowest := 0.0
+		for class, price := range s.prices {
+			classes = append(classes, class)
+			if lowest == 0 || price < lowest {
+				lowest = price
+			}
+		}
+		sort.Strings(classes)
+		var desc strings.Builder
+		for _, class := range classes {
+			fmt.Fprintf(&desc, "%s: %.0f\n", class, s.prices[class])
+		}
+		fmt.Fprintf(&desc, "Updated %s", s.updated.UTC().Format(time.RFC3339))
+
+		line("BEGIN:VEVENT")
+		host := s.labels["url"]
+		if u, err := url.Parse(host); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		line("UID:" + escapeICS(s.labels["cruiseid"]+"-"+s.labels["datelabel"]+"@"+host))
+		line("DTSTAMP:" + s.updated.UTC().Format("20060102T150405Z"))
+		line("LAST-MODIFIED:" + s.updated.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE:" + start.Format("20060102"))
+		// the end of an all-day event is exclusive
+		line("DTEND;VALUE=DATE:" + end.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICS(fmt.Sprintf("%s %s nights from %s, lowest %.0f", s.labels["ship"], s.labels["days"], s.labels["departureport"], lowest)))
+		line("DESCRIPTION:" + escapeICS(desc.String()))
+		if link := hc.absoluteLink(s.link); link != "" {
+			line("URL:" + link)
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escapeICS(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// foldICS splits a content line into lines of at most 75 bytes, without
+// breaking UTF-8 sequences, as RFC 5545 requires.
+func foldICS(l string) string {
+	if len(l) <= 75 {
+		return l
+	}
+	var b strings.Builder
+	limit := 75
+	for len(l) > limit {
+		i := limit
+		for i > 0 && l[i]&0xC0 == 0x80 {
+			i--
+		}
+		b.WriteString(l[:i])
+		b.WriteString("\r\n ")
+		l = l[i:]
+		// continuation lines start with the space
+		limit = 74
+	}
+	b.WriteString(l)
+	return b.String()
+}
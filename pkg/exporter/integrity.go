@@ -0,0 +1,60 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+)
+
+// checksum hashes the prices of a complete scrape, independent of the order
+// they came in.
+func checksum(scraped map[string]*customMetric) string {
+	keys := make([]string, 0, len(scraped))
+	for key := range scraped {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s\x00%g\n", key, scraped[key].quotedPrice)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// recordChecksum exports the checksum of a complete scrape and counts
+// whether it differs from that of the previous one. A target answering with
+// the same checksum for long may be served a cached or stale page.
+func (hc *Exporter) recordChecksum(t config.Target, scraped map[string]*customMetric, report *TargetReport) {
+	sum := checksum(scraped)
+	report.Checksum = sum
+
+	hc.checksumMu.Lock()
+	previous, ok := hc.checksums[t.Name]
+	hc.checksums[t.Name] = sum
+	hc.checksumMu.Unlock()
+
+	switch {
+	case !ok:
+	case previous == sum:
+		hc.scrapeContent.WithLabelValues(t.Name, "unchanged").Inc()
+	default:
+		hc.scrapeContent.WithLabelValues(t.Name, "changed").Inc()
+		hc.checksumInfo.DeleteLabelValues(t.Name, previous)
+	}
+	hc.checksumInfo.WithLabelValues(t.Name, sum).Set(1)
+}
+
+func (hc *Exporter) forgetChecksum(t config.Target) {
+	hc.checksumMu.Lock()
+	previous, ok := hc.checksums[t.Name]
+	delete(hc.checksums, t.Name)
+	hc.checksumMu.Unlock()
+	if ok {
+		hc.checksumInfo.DeleteLabelValues(t.Name, previous)
+	}
+	hc.scrapeContent.DeleteLabelValues(t.Name, "changed")
+	hc.scrapeContent.DeleteLabelValues(t.Name, "unchanged")
+}
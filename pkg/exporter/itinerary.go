@@ -0,0 +1,102 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+// sailingMeta is what a booked guest cares about besides the price.
+type sailingMeta struct {
+	itinerary     string
+	departurePort string
+	ports         string
+	seen          time.Time
+	// classes holds every stateroom class ever priced, priced those of the
+	// last scrape.
+	classes map[string]bool
+	priced  map[string]bool
+}
+
+// sailingRetention is how long a sailing that stopped showing up is
+// remembered, so it isn't treated as new when it comes back.
+const sailingRetention = 7 * 24 * time.Hour
+
+func (hc *Exporter) expireSailings(now time.Time) {
+	hc.sailingsMu.Lock()
+	defer hc.sailingsMu.Unlock()
+	for key, meta := range hc.sailings {
+		if now.Sub(meta.seen) > sailingRetention {
+			delete(hc.sailings, key)
+		}
+	}
+}
+
+// checkItinerary compares the sailing with the previous scrape and reports
+// itinerary swaps, departure port moves and changed ports of call.
+func (hc *Exporter) checkItinerary(t config.Target, c royalapi.Cruise, s royalapi.Sailing) {
+	it := c.MasterSailing.Itinerary
+	meta := sailingMeta{
+		itinerary:     s.Itinerary.Code,
+		departurePort: it.DeparturePort.Code,
+		ports:         strings.Join(it.PortCodes(), ","),
+		seen:          time.Now(),
+	}
+	key := strings.Join([]string{t.Name, c.ID, s.ID, s.SailDate}, "\x00")
+
+	meta.priced = map[string]bool{}
+	for _, p := range s.StateroomClassPricing {
+		if p.Price.Value > 0 {
+			meta.priced[p.StateroomClass.ID] = true
+		}
+	}
+
+	hc.sailingsMu.Lock()
+	old, ok := hc.sailings[key]
+	meta.classes = map[string]bool{}
+	for class := range old.classes {
+		meta.classes[class] = true
+	}
+	for class := range meta.priced {
+		meta.classes[class] = true
+	}
+	hc.sailings[key] = meta
+	hc.sailingsMu.Unlock()
+	if !ok {
+		return
+	}
+	hc.checkAvailability(t, c, s, old)
+
+	for _, change := range []struct {
+		field    string
+		from, to string
+	}{
+		{"itinerary", old.itinerary, meta.itinerary},
+		{"departure_port", old.departurePort, meta.departurePort},
+		{"ports", old.ports, meta.ports},
+	} {
+		// a field missing on one side means the query changed, not the sailing
+		if change.from == change.to || change.from == "" || change.to == "" {
+			continue
+		}
+		hc.itineraryChanges.WithLabelValues(t.Name, change.field).Inc()
+		text := fmt.Sprintf("%s sailing %s of cruise %s changed %s from %s to %s", it.Ship.Name, s.SailDate, c.ID, change.field, change.from, change.to)
+		hc.logger.Println(text)
+		if hc.itineraryChangesCfg != nil {
+			hc.notify(hc.itineraryChangesCfg.Notify, notify.Event{
+				Kind:     "itinerary_change",
+				Title:    fmt.Sprintf("Itinerary change on %s sailing %s", it.Ship.Name, s.SailDate),
+				Text:     text,
+				Priority: notify.PriorityHigh,
+				Labels: map[string]string{
+					"target": t.Name, "cruiseid": c.ID, "ship": it.Ship.Name, "datelabel": hc.dateLabel(s.SailDate), "field": change.field,
+				},
+				URL: s.BookingLink,
+			})
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+)
+
+var reservedLabelNames = map[string]bool{
+	"url": true, "cruiseid": true, "itinerary": true, "stateroomclass": true, "datelabel": true, "departureday": true,
+	"ship": true, "departureport": true, "days": true, "shipcode": true, "destinationcode": true, "super_category": true, "destination_region": true,
+}
+
+// collectStaticLabelNames gathers the static label names of all targets, as
+// every series must carry the same label set.
+func (hc *Exporter) collectStaticLabelNames() error {
+	names := map[string]bool{}
+	for _, name := range hc.declaredLabelNames {
+		if reservedLabelNames[name] {
+			return fmt.Errorf("static label %q collides with an exported label", name)
+		}
+		names[name] = true
+	}
+	for _, t := range hc.targets {
+		for name := range t.Labels {
+			if reservedLabelNames[name] {
+				return fmt.Errorf("target %s: static label %q collides with an exported label", t.Name, name)
+			}
+			names[name] = true
+		}
+	}
+	hc.staticLabelNames = make([]string, 0, len(names))
+	for name := range names {
+		hc.staticLabelNames = append(hc.staticLabelNames, name)
+	}
+	sort.Strings(hc.staticLabelNames)
+	return nil
+}
+
+// targetLabels returns the static labels of t, with the names only other
+// targets define set to "".
+func (hc *Exporter) targetLabels(t config.Target) map[string]string {
+	labels := make(map[string]string, len(hc.staticLabelNames))
+	for _, name := range hc.staticLabelNames {
+		labels[name] = t.Labels[name]
+	}
+	return labels
+}
@@ -0,0 +1,27 @@
+package exporter
+
+import "github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+
+var latencyAggregations = []string{"min", "max", "avg"}
+
+// exportPageLatency sums up the latencies of the pages of one scrape, so a
+// slow page is not hidden by the pages fetched after it. A scrape that got
+// no page through keeps the figures of the previous one.
+func (hc *Exporter) exportPageLatency(t config.Target, st *scrapeState) {
+	if len(st.latencies) == 0 {
+		return
+	}
+	min, max, sum := st.latencies[0], st.latencies[0], 0.0
+	for _, l := range st.latencies {
+		if l < min {
+			min = l
+		}
+		if l > max {
+			max = l
+		}
+		sum += l
+	}
+	hc.pageLatency.WithLabelValues(t.Name, "min").Set(min)
+	hc.pageLatency.WithLabelValues(t.Name, "max").Set(max)
+	hc.pageLatency.WithLabelValues(t.Name, "avg").Set(sum / float64(len(st.latencies)))
+}
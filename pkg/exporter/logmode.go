@@ -0,0 +1,52 @@
+package exporter
+
+import (
+	"strconv"
+)
+
+// Log modes, how much the exporter logs about scrapes that went well.
+const (
+	// LogModeFull logs the progress of every page and scrape.
+	LogModeFull = "full"
+	// LogModeDelta only logs changed prices and new or removed sailings,
+	// readable in the journal of a long running deployment.
+	LogModeDelta = "delta"
+)
+
+// progressf logs the progress of a scrape, unless only changes are logged.
+func (hc *Exporter) progressf(format string, v ...interface{}) {
+	if hc.logMode == LogModeDelta {
+		return
+	}
+	hc.logger.Printf(format, v...)
+}
+
+// logDiff logs the changes of a target in the delta log mode.
+func (hc *Exporter) logDiff(d TargetDiff) {
+	if hc.logMode != LogModeDelta {
+		return
+	}
+	for _, c := range d.Changed {
+		hc.logger.Printf("%s: price of %s changed from %s to %s", d.Target, describeSeries(c.Labels), formatPrice(c.From), formatPrice(c.To))
+	}
+	for _, s := range d.Added {
+		hc.logger.Printf("%s: new sailing %s from %s", d.Target, describeSeries(s.Labels), formatPrice(s.Price))
+	}
+	for _, s := range d.Removed {
+		hc.logger.Printf("%s: sailing %s removed, last at %s", d.Target, describeSeries(s.Labels), formatPrice(s.Price))
+	}
+}
+
+// describeSeries names a sailing, or one of its stateroom classes, for the
+// log.
+func describeSeries(labels map[string]string) string {
+	s := labels["ship"] + " " + labels["datelabel"]
+	if class := labels["stateroomclass"]; class != "" {
+		s += " stateroom class " + class
+	}
+	return s
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
@@ -0,0 +1,68 @@
+package exporter
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logThrottle is the output of the exporter's logger that passes identical
+// messages on at most once per interval, so a flapping upstream doesn't flood
+// the journal. How often a message was held back is logged once the interval
+// is over.
+type logThrottle struct {
+	out      *log.Logger
+	interval time.Duration
+	now      func() time.Time
+
+	mu         sync.Mutex
+	seen       map[string]*throttledMessage
+	suppressed uint64
+}
+
+// volatileRE matches the parts of a message that differ between otherwise
+// identical ones, the scrape and request IDs and durations.
+var volatileRE = regexp.MustCompile(`\b[0-9a-f]{16}\b|\b[0-9.]+(?:µs|ms|s)\b`)
+
+type throttledMessage struct {
+	msg        string
+	until      time.Time
+	suppressed int
+}
+
+func newLogThrottle(out *log.Logger, interval time.Duration) *logThrottle {
+	return &logThrottle{out: out, interval: interval, now: time.Now, seen: map[string]*throttledMessage{}}
+}
+
+func (t *logThrottle) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	key := volatileRE.ReplaceAllString(msg, "_")
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, s := range t.seen {
+		if now.Before(s.until) {
+			continue
+		}
+		if s.suppressed > 0 {
+			t.out.Printf("%s (repeated %d more times in %s)", s.msg, s.suppressed, t.interval)
+		}
+		delete(t.seen, k)
+	}
+	if s, ok := t.seen[key]; ok {
+		s.suppressed++
+		t.suppressed++
+		return len(p), nil
+	}
+	t.seen[key] = &throttledMessage{msg: msg, until: now.Add(t.interval)}
+	t.out.Print(msg)
+	return len(p), nil
+}
+
+func (t *logThrottle) suppressedTotal() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return float64(t.suppressed)
+}
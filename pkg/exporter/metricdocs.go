@@ -0,0 +1,122 @@
+package exporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricDoc describes an exported metric.
+type MetricDoc struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Help     string   `json:"help"`
+	Labels   []string `json:"labels"`
+	Examples []string `json:"examples"`
+}
+
+// metricExamples are queries worth showing on top of the generated ones.
+var metricExamples = map[string][]string{
+	"royal_external_price": {
+		`min by (ship, datelabel) (royal_external_price{stateroomclass="B"})`,
+		`royal_external_price < 0.9 * min_over_time(royal_external_price[7d])`,
+	},
+	"royal_exporter_watch_firing": {`royal_exporter_watch_firing > 0`},
+	"royal_exporter_price_change_ratio": {
+		`histogram_quantile(0.9, sum by (le) (increase(royal_exporter_price_change_ratio_bucket[1d])))`,
+	},
+	"royal_notifier_failed_total": {`increase(royal_notifier_failed_total[1h]) > 0`},
+}
+
+// descRE matches prometheus.Desc.String, the only way to read a Desc.
+var descRE = regexp.MustCompile(`^Desc\{fqName: ("(?:[^"\\]|\\.)*"), help: ("(?:[^"\\]|\\.)*"), constLabels: \{.*\}, variableLabels: \[(.*)\]\}$`)
+
+// metricDocs describes the metrics of the documented collectors. The type
+// comes from the collector or, for the likes of GaugeFuncs, from a gathered
+// series.
+func (hc *Exporter) metricDocs() []MetricDoc {
+	gathered := map[string]string{}
+	if mfs, err := hc.gatherer.Gather(); err == nil {
+		for _, mf := range mfs {
+			gathered[mf.GetName()] = strings.ToLower(mf.GetType().String())
+		}
+	}
+	seen := map[string]bool{}
+	var docs []MetricDoc
+	for _, c := range hc.documented {
+		typ := ""
+		switch c.(type) {
+		case *prometheus.GaugeVec, prometheus.Gauge:
+			typ = "gauge"
+		case *prometheus.CounterVec, prometheus.Counter:
+			typ = "counter"
+		case *prometheus.HistogramVec:
+			typ = "histogram"
+		case *prometheus.SummaryVec:
+			typ = "summary"
+		}
+		ch := make(chan *prometheus.Desc, 16)
+		go func() {
+			c.Describe(ch)
+			close(ch)
+		}()
+		for desc := range ch {
+			m := descRE.FindStringSubmatch(desc.String())
+			if m == nil {
+				continue
+			}
+			doc := MetricDoc{Type: typ, Labels: strings.Fields(m[3])}
+			doc.Name, _ = strconv.Unquote(m[1])
+			doc.Help, _ = strconv.Unquote(m[2])
+			if seen[doc.Name] {
+				continue
+			}
+			seen[doc.Name] = true
+			if doc.Type == "" {
+				doc.Type = gathered[doc.Name]
+			}
+			if doc.Type == "" {
+				doc.Type = "unknown"
+			}
+			doc.Examples = append(exampleQueries(doc), metricExamples[doc.Name]...)
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Name < docs[j].Name })
+	return docs
+}
+
+// exampleQueries returns the typical queries over a metric of its type.
+func exampleQueries(doc MetricDoc) []string {
+	by := ""
+	if len(doc.Labels) > 0 {
+		by = " by (" + doc.Labels[0] + ")"
+	}
+	switch doc.Type {
+	case "counter":
+		return []string{"sum" + by + " (rate(" + doc.Name + "[5m]))"}
+	case "histogram":
+		return []string{"histogram_quantile(0.95, sum by (le) (rate(" + doc.Name + "_bucket[5m])))"}
+	case "summary":
+		return []string{"rate(" + doc.Name + "_sum[5m]) / rate(" + doc.Name + "_count[5m])"}
+	default:
+		if by == "" {
+			return []string{doc.Name}
+		}
+		return []string{doc.Name, "min" + by + " (" + doc.Name + ")"}
+	}
+}
+
+// serveMetricDocs lists the exported metrics with their labels and example
+// queries.
+func (hc *Exporter) serveMetricDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Metrics []MetricDoc `json:"metrics"`
+	}{hc.metricDocs()})
+}
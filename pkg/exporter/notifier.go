@@ -0,0 +1,64 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// instrumentNotifier returns the delivery metrics of the notifier, every
+// channel starting at zero so alerts on failures see it from the start.
+func (hc *Exporter) instrumentNotifier() []prometheus.Collector {
+	if hc.notifier == nil {
+		return nil
+	}
+	sent := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "notifier",
+		Name:      "sent_total",
+		Help:      "Number of notifications delivered by the channel.",
+	}, []string{"channel"})
+	failed := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "royal",
+		Subsystem: "notifier",
+		Name:      "failed_total",
+		Help:      "Number of notifications the channel failed to deliver.",
+	}, []string{"channel"})
+	lastSent := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "notifier",
+		Name:      "last_sent_timestamp_seconds",
+		Help:      "When the channel last delivered a notification, 0 if it never did.",
+	}, []string{"channel"})
+	lastFailed := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "notifier",
+		Name:      "last_failed_timestamp_seconds",
+		Help:      "When the channel last failed to deliver a notification, 0 if it never did.",
+	}, []string{"channel"})
+	queued := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "notifier",
+		Name:      "queued_notifications",
+		Help:      "Number of failed notifications waiting in the outbox to be retried.",
+	}, func() float64 { return float64(hc.notifier.Queued()) })
+	for _, name := range hc.notifier.Names() {
+		sent.WithLabelValues(name)
+		failed.WithLabelValues(name)
+		lastSent.WithLabelValues(name)
+		lastFailed.WithLabelValues(name)
+	}
+	hc.notifier.OnDelivery(func(channel string, e notify.Event, err error) {
+		hc.auditDelivery(channel, e, err)
+		now := float64(time.Now().UnixNano()) / 1e9
+		if err != nil {
+			failed.WithLabelValues(channel).Inc()
+			lastFailed.WithLabelValues(channel).Set(now)
+			return
+		}
+		sent.WithLabelValues(channel).Inc()
+		lastSent.WithLabelValues(channel).Set(now)
+	})
+	return []prometheus.Collector{sent, failed, lastSent, lastFailed, queued}
+}
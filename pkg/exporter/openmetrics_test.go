@@ -0,0 +1,133 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exportertest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil/promlint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrapedMetrics returns /metrics of an exporter that scraped one target, as
+// the format accept asks for.
+func scrapedMetrics(t *testing.T, accept string) (string, string) {
+	t.Helper()
+	srv := exportertest.NewServer(exportertest.Cruise{
+		ID: "WN07RCI-1", Ship: "Wonder of the Seas", ShipCode: "WN", DeparturePort: "Port Canaveral", Destination: "CARIB", Nights: 7,
+		Sailings: []exportertest.Sailing{{ID: "A", Itinerary: "WN07W375", SailDate: "2036-01-12", Prices: map[string]int{"I": 899, "O": 1049}}},
+	})
+	defer srv.Close()
+	mux := http.NewServeMux()
+	e, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(mux),
+		WithTargets(config.Target{Name: "carib", URL: srv.URL}),
+	)
+	require.NoError(t, err)
+	e.ScrapeOnce()
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("Accept", accept)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Header().Get("Content-Type"), rec.Body.String()
+}
+
+var (
+	openMetricsSample   = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{.*?\})? (\S+)( # (\{.*?\}) (\S+)( \S+)?)?$`)
+	openMetricsLabel    = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)="((?:[^"\\]|\\.)*)"`)
+	openMetricsSuffixes = map[string][]string{
+		"counter":   {"_total", "_created"},
+		"gauge":     {""},
+		"histogram": {"_bucket", "_count", "_sum", "_created"},
+		"summary":   {"", "_count", "_sum", "_created"},
+		"unknown":   {""},
+	}
+)
+
+// checkOpenMetrics checks the exposition against the rules of the OpenMetrics
+// text format a scraper parsing it strictly enforces, failing t with every
+// line breaking one.
+func checkOpenMetrics(t *testing.T, body string) {
+	t.Helper()
+	require.True(t, strings.HasSuffix(body, "# EOF\n"), "the exposition ends with # EOF")
+	families := map[string]bool{}
+	family, typ := "", ""
+	scanner := bufio.NewScanner(strings.NewReader(strings.TrimSuffix(body, "# EOF\n")))
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# ") {
+			fields := strings.SplitN(line, " ", 4)
+			if !assert.GreaterOrEqual(t, len(fields), 4, "line %d: %s", n, line) {
+				continue
+			}
+			if fields[2] != family {
+				family, typ = fields[2], "unknown"
+				assert.False(t, families[family], "line %d: family %s is exposed twice", n, family)
+				families[family] = true
+			}
+			switch fields[1] {
+			case "TYPE":
+				typ = fields[3]
+				assert.Contains(t, openMetricsSuffixes, typ, "line %d: unknown type", n)
+				if typ == "counter" {
+					assert.False(t, strings.HasSuffix(family, "_total"), "line %d: counter family %s keeps _total", n, family)
+				}
+			case "HELP", "UNIT":
+			default:
+				t.Errorf("line %d: unknown descriptor %s", n, fields[1])
+			}
+			continue
+		}
+		m := openMetricsSample.FindStringSubmatch(line)
+		if !assert.NotNil(t, m, "line %d: malformed sample %s", n, line) {
+			continue
+		}
+		suffix := strings.TrimPrefix(m[1], family)
+		assert.True(t, strings.HasPrefix(m[1], family), "line %d: %s outside of family %s", n, m[1], family)
+		assert.Contains(t, openMetricsSuffixes[typ], suffix, "line %d: %s in a %s", n, m[1], typ)
+		_, err := strconv.ParseFloat(m[3], 64)
+		assert.NoError(t, err, "line %d: value", n)
+		if m[4] == "" {
+			continue
+		}
+		assert.True(t, typ == "histogram" && suffix == "_bucket" || typ == "counter" && suffix == "_total", "line %d: exemplar on %s", n, m[1])
+		length := 0
+		for _, l := range openMetricsLabel.FindAllStringSubmatch(m[5], -1) {
+			length += utf8.RuneCountInString(l[1]) + utf8.RuneCountInString(l[2])
+		}
+		assert.LessOrEqual(t, length, 128, "line %d: exemplar labels are limited to 128 characters", n)
+		_, err = strconv.ParseFloat(m[6], 64)
+		assert.NoError(t, err, "line %d: exemplar value", n)
+	}
+	require.NoError(t, scanner.Err())
+}
+
+func TestMetricsAreValidOpenMetrics(t *testing.T) {
+	contentType, body := scrapedMetrics(t, "application/openmetrics-text; version=0.0.1")
+	require.True(t, strings.HasPrefix(contentType, "application/openmetrics-text"), contentType)
+	checkOpenMetrics(t, body)
+	assert.Regexp(t, `(?m)^royal_exporter_request_duration_seconds_bucket\{.*\} \S+ # \{request_id="[^"]+"`, body, "request latencies carry exemplars")
+}
+
+// TestMetricsPassLint lints the text format the way promtool check metrics
+// does.
+func TestMetricsPassLint(t *testing.T) {
+	_, body := scrapedMetrics(t, "text/plain")
+	problems, err := promlint.New(strings.NewReader(body)).Lint()
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
@@ -0,0 +1,161 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/jsonpath"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// operation is a configured GraphQL operation and the metrics read from its
+// responses.
+type operation struct {
+	config.OperationConfig
+	targets map[string]bool
+	metrics []operationMetric
+}
+
+type operationMetric struct {
+	items  jsonpath.Path
+	value  jsonpath.Path
+	labels map[string]jsonpath.Path
+	guard  *seriesGuard
+}
+
+// operationRequest is the body of a configured operation.
+type operationRequest struct {
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Query         string                 `json:"query"`
+}
+
+// newOperation parses the paths of cfg, creating the gauges with gauge.
+func newOperation(cfg config.OperationConfig, gauge func(name, help string, labels ...string) *seriesGuard) (operation, error) {
+	op := operation{OperationConfig: cfg}
+	if len(cfg.Targets) > 0 {
+		op.targets = map[string]bool{}
+		for _, name := range cfg.Targets {
+			op.targets[name] = true
+		}
+	}
+	for _, m := range cfg.Metrics {
+		om := operationMetric{labels: map[string]jsonpath.Path{}}
+		var err error
+		if om.items, err = jsonpath.Parse(m.Items); err != nil {
+			return op, err
+		}
+		if om.value, err = jsonpath.Parse(m.Value); err != nil {
+			return op, err
+		}
+		names := []string{"url"}
+		for name, path := range m.Labels {
+			if om.labels[name], err = jsonpath.Parse(path); err != nil {
+				return op, err
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names[1:])
+		help := m.Help
+		if help == "" {
+			help = fmt.Sprintf("%s of the %s operation.", m.Value, cfg.Name)
+		}
+		om.guard = gauge(m.Name, help, names...)
+		op.metrics = append(op.metrics, om)
+	}
+	return op, nil
+}
+
+// runOperations sends the configured operations to the target and exports
+// their metrics. Failures are logged, they don't fail the scrape.
+func (hc *Exporter) runOperations(ctx context.Context, t config.Target) {
+	for i := range hc.operations {
+		op := &hc.operations[i]
+		if op.targets != nil && !op.targets[t.Name] {
+			continue
+		}
+		if err := hc.runOperation(ctx, t, op); err != nil {
+			hc.logger.Printf("error running operation %s on %s: %s", op.Name, t.Name, err)
+		}
+	}
+}
+
+func (hc *Exporter) runOperation(ctx context.Context, t config.Target, op *operation) error {
+	body, _, err := hc.post(ctx, t, 0, operationRequest{
+		OperationName: op.OperationName,
+		Variables:     op.JSONVariables(),
+		Query:         op.Query,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(body)
+	var data interface{}
+	if err := json.Unmarshal(body.Bytes(), &data); err != nil {
+		return fmt.Errorf("%w: %s", errParse, err)
+	}
+	if m, ok := data.(map[string]interface{}); ok && m["errors"] != nil {
+		return fmt.Errorf("operation returned errors: %s", labelValue(m["errors"]))
+	}
+
+	targetLabels := hc.targetLabels(t)
+	for _, m := range op.metrics {
+		m.guard.deleteMatching(prometheus.Labels{"url": t.URL})
+		for _, item := range m.items.Select(data) {
+			v, ok := m.value.First(item)
+			if !ok {
+				continue
+			}
+			value, ok := number(v)
+			if !ok {
+				continue
+			}
+			labels := prometheus.Labels{"url": t.URL}
+			for name, path := range m.labels {
+				labels[name] = ""
+				if v, ok := path.First(item); ok && v != nil {
+					labels[name] = labelValue(v)
+				}
+			}
+			for k, v := range targetLabels {
+				labels[k] = v
+			}
+			if err := m.guard.set(labels, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// number converts a JSON number, numeric string or boolean to a sample value.
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func labelValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,683 @@
+package exporter
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/calendar"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/leader"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/redis"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Option configures an Exporter created by NewExporter.
+type Option func(*Exporter) error
+
+// WithInterval sets how often every target is scraped.
+func WithInterval(interval time.Duration) Option {
+	return func(hc *Exporter) error {
+		if interval <= 0 {
+			return fmt.Errorf("interval must be positive, got %s", interval)
+		}
+		hc.healthcheck_invertval = interval
+		return nil
+	}
+}
+
+// WithMaxBackoff doubles the interval between scrape cycles, up to max,
+// while every target fails. Zero keeps the interval.
+func WithMaxBackoff(max time.Duration) Option {
+	return func(hc *Exporter) error {
+		if max < 0 {
+			return fmt.Errorf("max backoff must not be negative, got %s", max)
+		}
+		hc.maxBackoff = max
+		return nil
+	}
+}
+
+// WithScrapeBudget limits how long a scrape of one target may take. When the
+// budget runs out pagination stops, the pages collected so far are kept and
+// the scrape is flagged as partial. Zero means no limit.
+func WithScrapeBudget(budget time.Duration) Option {
+	return func(hc *Exporter) error {
+		if budget < 0 {
+			return fmt.Errorf("scrape budget must not be negative, got %s", budget)
+		}
+		hc.scrapeBudget = budget
+		return nil
+	}
+}
+
+// WithRequestBudget caps the requests sent to every target per day, unless
+// the target sets its own budget. Once a target used it up it isn't scraped
+// until midnight. Zero means no limit.
+func WithRequestBudget(n int) Option {
+	return func(hc *Exporter) error {
+		if n < 0 {
+			return fmt.Errorf("request budget must not be negative, got %d", n)
+		}
+		hc.requestBudget = n
+		return nil
+	}
+}
+
+// WithMaxPageSize requests up to n cruises per page. Targets that reject or
+// truncate pages that large get smaller ones, down to 20, and the size that
+// worked is used from then on.
+func WithMaxPageSize(n int) Option {
+	return func(hc *Exporter) error {
+		if n < minPageSize {
+			return fmt.Errorf("page size must be at least %d, got %d", minPageSize, n)
+		}
+		hc.maxPageSize = n
+		return nil
+	}
+}
+
+// WithPageConcurrency fetches up to n pages of a target at the same time once
+// the first page told how many there are. Prices are still exported in page
+// order.
+func WithPageConcurrency(n int) Option {
+	return func(hc *Exporter) error {
+		if n < 1 {
+			return fmt.Errorf("page concurrency must be at least 1, got %d", n)
+		}
+		hc.pageConcurrency = n
+		return nil
+	}
+}
+
+// WithResponseCache reuses parsed pages for ttl, keyed by target and query
+// variables, so scrapes close together don't repeat upstream requests. Zero
+// disables the cache.
+func WithResponseCache(ttl time.Duration) Option {
+	return func(hc *Exporter) error {
+		if ttl < 0 {
+			return fmt.Errorf("cache ttl must not be negative, got %s", ttl)
+		}
+		if ttl > 0 {
+			hc.pages = newPageCache(ttl)
+		}
+		return nil
+	}
+}
+
+// WithURLs adds GraphQL endpoints to scrape, named after their URL.
+func WithURLs(urls ...string) Option {
+	return func(hc *Exporter) error {
+		for _, u := range urls {
+			hc.targets = append(hc.targets, config.Target{Name: u, URL: u})
+		}
+		return nil
+	}
+}
+
+// WithTargets adds named GraphQL endpoints to scrape.
+func WithTargets(targets ...config.Target) Option {
+	return func(hc *Exporter) error {
+		hc.targets = append(hc.targets, targets...)
+		return nil
+	}
+}
+
+// WithStaticLabelNames declares static label names that targets discovered
+// at runtime may use in addition to those of the configured targets.
+func WithStaticLabelNames(names ...string) Option {
+	return func(hc *Exporter) error {
+		hc.declaredLabelNames = append(hc.declaredLabelNames, names...)
+		return nil
+	}
+}
+
+// WithTaxes normalizes the exported prices to include or exclude taxes and
+// fees, one of TaxesAsQuoted, TaxesExcluded or TaxesIncluded. The prices as
+// quoted are exported as royal_external_price_as_quoted unless they are kept.
+func WithTaxes(mode string) Option {
+	return func(hc *Exporter) error {
+		if mode != TaxesAsQuoted && mode != TaxesExcluded && mode != TaxesIncluded {
+			return fmt.Errorf("unknown taxes mode %q", mode)
+		}
+		hc.taxes = mode
+		return nil
+	}
+}
+
+// WithLogMode sets how much is logged about scrapes, LogModeFull or
+// LogModeDelta. Errors are logged either way.
+func WithLogMode(mode string) Option {
+	return func(hc *Exporter) error {
+		if mode != LogModeFull && mode != LogModeDelta {
+			return fmt.Errorf("unknown log mode %q", mode)
+		}
+		hc.logMode = mode
+		return nil
+	}
+}
+
+// WithLogThrottle logs identical messages at most once per interval, with
+// the number held back once it is over. Zero logs every message.
+func WithLogThrottle(interval time.Duration) Option {
+	return func(hc *Exporter) error {
+		if interval < 0 {
+			return fmt.Errorf("log throttle interval must not be negative")
+		}
+		hc.logThrottle = interval
+		return nil
+	}
+}
+
+// WithCabinGuests exports royal_external_cabin_total_price, the price per
+// person times guests. Zero disables the metric.
+func WithCabinGuests(guests int) Option {
+	return func(hc *Exporter) error {
+		if guests < 0 {
+			return fmt.Errorf("cabin guests must not be negative, got %d", guests)
+		}
+		hc.cabinGuests = guests
+		return nil
+	}
+}
+
+// WithDateLabels formats the datelabel label with layout, a time.Format
+// layout, in the IANA time zone, UTC when empty. Sail dates without a time
+// zone are taken to be in it. An empty layout exports sail dates verbatim.
+func WithDateLabels(layout, timezone string) Option {
+	return func(hc *Exporter) error {
+		loc := time.UTC
+		if timezone != "" {
+			var err error
+			if loc, err = time.LoadLocation(timezone); err != nil {
+				return fmt.Errorf("invalid date label time zone %q: %w", timezone, err)
+			}
+		}
+		hc.dateLayout = layout
+		hc.dateLocation = loc
+		return nil
+	}
+}
+
+// WithFilters sets the cruiseSearch filters variable, e.g. "ship:WN".
+func WithFilters(filters string) Option {
+	return func(hc *Exporter) error {
+		if err := royalapi.ValidateFilters(filters); err != nil {
+			return err
+		}
+		hc.filters = filters
+		return nil
+	}
+}
+
+// WithQueryFeatures limits the GraphQL query to the optional fields enabled in
+// features. Everything is requested by default.
+func WithQueryFeatures(features royalapi.Features) Option {
+	return func(hc *Exporter) error {
+		hc.queryFeatures = features
+		return nil
+	}
+}
+
+// WithSort sorts the search results by one of royalapi.Sorts. Targets may
+// set their own.
+func WithSort(by string) Option {
+	return func(hc *Exporter) error {
+		if err := royalapi.ValidateSort(by); err != nil {
+			return err
+		}
+		hc.sort = by
+		return nil
+	}
+}
+
+// WithPersistedQueries sends the query as an automatic persisted query hash,
+// like the website does, falling back to the full query when the server
+// doesn't know the hash.
+func WithPersistedQueries(enabled bool) Option {
+	return func(hc *Exporter) error {
+		hc.persistedQueries = enabled
+		return nil
+	}
+}
+
+// WithOpenMetrics serves /metrics in the OpenMetrics format to scrapers
+// asking for it, the default, the only one carrying the exemplars of
+// royal_exporter_request_duration_seconds.
+func WithOpenMetrics(enabled bool) Option {
+	return func(hc *Exporter) error {
+		hc.openMetrics = enabled
+		return nil
+	}
+}
+
+// WithSampleTimestamps exports the royal_external_ samples with the time
+// they were fetched instead of leaving it to the scraper, so the TSDB records
+// when a price was observed even with long scrape intervals. Prometheus
+// doesn't mark timestamped series stale, they vanish 5m after the last
+// sample.
+func WithSampleTimestamps(enabled bool) Option {
+	return func(hc *Exporter) error {
+		hc.sampleTimestamps = enabled
+		return nil
+	}
+}
+
+// WithLegacyMetricNames exports the metrics renamed to follow the Prometheus
+// naming conventions under their old names and units too, like
+// royal_external_url_response_ms next to royal_external_url_response_seconds.
+func WithLegacyMetricNames(enabled bool) Option {
+	return func(hc *Exporter) error {
+		hc.legacyMetricNames = enabled
+		return nil
+	}
+}
+
+// WithRegistry registers the metrics with reg and serves them from it on
+// /metrics instead of the global registry.
+func WithRegistry(reg *prometheus.Registry) Option {
+	return func(hc *Exporter) error {
+		hc.registerer = reg
+		hc.gatherer = reg
+		return nil
+	}
+}
+
+// WithServeMux registers the exporter's HTTP handlers on mux instead of
+// http.DefaultServeMux.
+func WithServeMux(mux *http.ServeMux) Option {
+	return func(hc *Exporter) error {
+		hc.mux = mux
+		return nil
+	}
+}
+
+// Doer sends an HTTP request and returns its response. *http.Client
+// satisfies it, as do instrumented or fake clients.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// WithHTTPClient sets the client used for upstream requests.
+func WithHTTPClient(client Doer) Option {
+	return func(hc *Exporter) error {
+		if client == nil {
+			return fmt.Errorf("http client must not be nil")
+		}
+		hc.client = client
+		return nil
+	}
+}
+
+// WithTracer records a span for every scrape of a target, every page and
+// every request sent to the target.
+func WithTracer(tracer Tracer) Option {
+	return func(hc *Exporter) error {
+		if tracer == nil {
+			return fmt.Errorf("tracer must not be nil")
+		}
+		hc.tracer = tracer
+		return nil
+	}
+}
+
+// WithDNSCache keeps the addresses target host names resolve to for ttl
+// instead of resolving them for every new connection.
+func WithDNSCache(ttl time.Duration) Option {
+	return func(hc *Exporter) error {
+		if ttl <= 0 {
+			return fmt.Errorf("dns cache ttl must be positive")
+		}
+		hc.dns = newDNSCache(ttl)
+		return nil
+	}
+}
+
+// WithSource binds the connections to the targets to a local IP address or
+// the address of a network interface, at most one of which may be set.
+// Targets setting their own source override it.
+func WithSource(address, iface string) Option {
+	return func(hc *Exporter) error {
+		if err := config.ValidateSource(address, iface); err != nil {
+			return err
+		}
+		hc.sourceAddress, hc.sourceInterface = address, iface
+		return nil
+	}
+}
+
+// WithSession loads the bootstrap page of cfg before the GraphQL calls and
+// sends them with the cookies and headers it handed out, loading it again
+// once the session is too old or rejected.
+func WithSession(cfg config.SessionConfig) Option {
+	return func(hc *Exporter) error {
+		hc.session = &session{cfg: cfg}
+		return nil
+	}
+}
+
+// WithLogger sets the logger used by the exporter.
+func WithLogger(logger *log.Logger) Option {
+	return func(hc *Exporter) error {
+		hc.logger = logger
+		return nil
+	}
+}
+
+// WithRelabelConfigs applies the rules to every series before exposition.
+func WithRelabelConfigs(rules []config.RelabelConfig) Option {
+	return func(hc *Exporter) error {
+		hc.relabelConfigs = rules
+		return nil
+	}
+}
+
+// WithSeriesLimit caps every metric at limit series, zero meaning unlimited.
+// action is either SeriesLimitDrop or SeriesLimitRefuse.
+func WithSeriesLimit(limit int, action string) Option {
+	return func(hc *Exporter) error {
+		if action != SeriesLimitDrop && action != SeriesLimitRefuse {
+			return fmt.Errorf("unknown series limit action %q", action)
+		}
+		hc.seriesLimit = limit
+		hc.seriesLimitAction = action
+		return nil
+	}
+}
+
+// WithSeriesTTL removes series that weren't updated by the last intervals
+// scrapes, even when their target isn't scraped anymore. Skipped scrapes
+// don't count. Zero keeps them until their target is removed.
+func WithSeriesTTL(intervals int) Option {
+	return func(hc *Exporter) error {
+		if intervals < 0 {
+			return fmt.Errorf("series ttl must not be negative, got %d", intervals)
+		}
+		hc.seriesTTL = intervals
+		return nil
+	}
+}
+
+// WithSuccessWindows exports royal_exporter_scrape_success_ratio of every
+// target over each window, e.g. 1h and 24h.
+func WithSuccessWindows(windows ...time.Duration) Option {
+	return func(hc *Exporter) error {
+		for _, w := range windows {
+			if w <= 0 {
+				return fmt.Errorf("success ratio window must be positive, got %s", w)
+			}
+		}
+		hc.successWindows = windows
+		return nil
+	}
+}
+
+// WithDebug keeps the last size raw responses per target and serves them on
+// /debug/last-response for requests bearing the given token. The endpoint is
+// disabled when token is empty.
+func WithDebug(token string, size int) Option {
+	return func(hc *Exporter) error {
+		if token == "" || size <= 0 {
+			return nil
+		}
+		hc.debugToken.Store(token)
+		hc.responses = newResponseRing(size)
+		return nil
+	}
+}
+
+// WithHistory records every exported price in store, saving it to file after
+// every scrape cycle when file is not empty.
+func WithHistory(store *history.Store, file string) Option {
+	return func(hc *Exporter) error {
+		hc.history = store
+		hc.historyFile = file
+		return nil
+	}
+}
+
+// WithAnomalyDetection exports royal_external_price_anomaly and notifies about
+// prices that jump away from their history. It requires WithHistory.
+func WithAnomalyDetection(cfg config.AnomalyConfig) Option {
+	return func(hc *Exporter) error {
+		hc.anomaly = &cfg
+		return nil
+	}
+}
+
+// WithTrend exports royal_external_price_trend_per_day for every price. It
+// requires WithHistory.
+func WithTrend(cfg config.TrendConfig) Option {
+	return func(hc *Exporter) error {
+		hc.trend = &cfg
+		return nil
+	}
+}
+
+// WithDigest sends a summary of the price history through the notifier on
+// the configured schedule. It requires WithHistory.
+func WithDigest(cfg config.DigestConfig) Option {
+	return func(hc *Exporter) error {
+		hc.digest = &cfg
+		return nil
+	}
+}
+
+// WithItineraryChangeNotifications sends an event whenever the itinerary of
+// a sailing changes. Changes are always logged and counted.
+func WithItineraryChangeNotifications(cfg config.ItineraryChangesConfig) Option {
+	return func(hc *Exporter) error {
+		hc.itineraryChangesCfg = &cfg
+		return nil
+	}
+}
+
+// WithUsers makes the watches API and the web UI ask for the name and
+// password of one of the users, who only see and manage their own watches.
+func WithUsers(users ...config.UserConfig) Option {
+	return func(hc *Exporter) error {
+		if hc.users == nil {
+			hc.users = map[string]*config.UserConfig{}
+		}
+		for i := range users {
+			u := users[i]
+			if err := u.Validate(); err != nil {
+				return fmt.Errorf("user %q: %w", u.Name, err)
+			}
+			hc.users[u.Name] = &u
+		}
+		return nil
+	}
+}
+
+// WithWatches evaluates the watches against every exported price, notifying
+// when one starts firing. Scrapes only search for the itineraries and
+// products of scoped watches.
+func WithWatches(watches ...config.WatchConfig) Option {
+	return func(hc *Exporter) error {
+		hc.watches = append(hc.watches, watches...)
+		return nil
+	}
+}
+
+// WithWatchesFile manages watches through /api/v1/watches, kept in file on top
+// of the watches of WithWatches. Without it, or without WithUsers, the API
+// only lists the watches.
+func WithWatchesFile(file string) Option {
+	return func(hc *Exporter) error {
+		hc.watchesFile = file
+		return nil
+	}
+}
+
+// WithWatchState keeps the firing watches in file so a restart doesn't
+// notify them again.
+func WithWatchState(file string) Option {
+	return func(hc *Exporter) error {
+		hc.watchStateFile = file
+		return nil
+	}
+}
+
+// WithAuditLog appends the notifications sent, config reloads and watch
+// changes through the API to file as JSON lines, served on /api/v1/audit.
+func WithAuditLog(file string) Option {
+	return func(hc *Exporter) error {
+		hc.audit = &auditLog{file: file}
+		return nil
+	}
+}
+
+// WithAlertmanager sends firing watches to an Alertmanager after every scrape
+// cycle. Relative booking links are resolved against externalURL.
+func WithAlertmanager(am *notify.Alertmanager, externalURL string) Option {
+	return func(hc *Exporter) error {
+		hc.alertmanager = am
+		hc.externalURL = externalURL
+		return nil
+	}
+}
+
+// WithHolidays exports royal_external_sailing_holiday_info for the sailings
+// overlapping the holidays.
+func WithHolidays(holidays ...config.HolidayConfig) Option {
+	return func(hc *Exporter) error {
+		if len(holidays) > 0 {
+			hc.calendar = calendar.New(holidays)
+		}
+		return nil
+	}
+}
+
+// WithRollups exports aggregations of the prices of every scrape, and only
+// those when cfg.DropRaw is set.
+func WithRollups(cfg config.RollupsConfig) Option {
+	return func(hc *Exporter) error {
+		hc.rollupConfigs = cfg.Rules
+		hc.dropRawPrices = cfg.DropRaw
+		return nil
+	}
+}
+
+// WithOperations sends the GraphQL operations to the targets after every
+// cruise search and exports the metrics they map.
+func WithOperations(ops ...config.OperationConfig) Option {
+	return func(hc *Exporter) error {
+		hc.operationConfigs = append(hc.operationConfigs, ops...)
+		return nil
+	}
+}
+
+// WithDerivedMetrics exports a gauge per derived metric, computed from every
+// price series.
+func WithDerivedMetrics(metrics ...config.DerivedMetricConfig) Option {
+	return func(hc *Exporter) error {
+		hc.derivedConfigs = append(hc.derivedConfigs, metrics...)
+		return nil
+	}
+}
+
+// WithSuperCategories exports royal_external_super_category_price, the
+// cheapest price per sailing and stateroom super category.
+func WithSuperCategories(cfg config.SuperCategoriesConfig) Option {
+	return func(hc *Exporter) error {
+		hc.superCategoryClasses = map[string]string{}
+		for class, category := range cfg.Classes {
+			hc.superCategoryClasses[class] = category
+		}
+		return nil
+	}
+}
+
+// WithPricingCalendar exports royal_external_calendar_price from the pricing
+// calendar of the configured itineraries after every cruise search.
+func WithPricingCalendar(cfg config.PricingCalendarConfig) Option {
+	return func(hc *Exporter) error {
+		hc.pricingCalendar = &cfg
+		return nil
+	}
+}
+
+// WithScrapeWindow only scrapes during the hours of cfg. /metrics keeps
+// serving the last values outside of them.
+func WithScrapeWindow(cfg config.TimeWindowConfig) Option {
+	return func(hc *Exporter) error {
+		hc.scrapeWindow = &cfg
+		return nil
+	}
+}
+
+// WithLeaderElection only scrapes and sends digests while e reports this
+// replica as the leader. /metrics is served either way.
+func WithLeaderElection(e leader.Elector) Option {
+	return func(hc *Exporter) error {
+		hc.elector = e
+		return nil
+	}
+}
+
+// WithCatalogStore shares the catalog of every complete scrape through
+// client, under keys starting with prefix. Replicas that aren't the leader
+// export the shared catalogs instead of scraping.
+func WithCatalogStore(client *redis.Client, prefix string) Option {
+	return func(hc *Exporter) error {
+		hc.catalogs = client
+		hc.catalogPrefix = prefix
+		return nil
+	}
+}
+
+// WithNotifier sends events such as price anomalies through d.
+func WithNotifier(d *notify.Dispatcher) Option {
+	return func(hc *Exporter) error {
+		hc.notifier = d
+		return nil
+	}
+}
+
+// WithNotificationOutbox queues the notifications that failed in o and
+// retries the due ones every interval. It requires WithNotifier.
+func WithNotificationOutbox(o *notify.Outbox, interval time.Duration) Option {
+	return func(hc *Exporter) error {
+		hc.outbox = o
+		hc.outboxRetry = interval
+		return nil
+	}
+}
+
+// wireOptions connects what the options recorded once all of them are
+// applied, so they can be given in any order: the watches file is checked
+// against the users and notifiers, and the watch state restored for all the
+// watches.
+func (hc *Exporter) wireOptions() error {
+	if hc.outbox != nil {
+		if hc.notifier == nil {
+			return fmt.Errorf("a notification outbox requires a notifier")
+		}
+		hc.notifier.SetOutbox(hc.outbox)
+	}
+	for _, w := range hc.watches {
+		hc.watchLimits[w.Name] = newWatchLimit(w)
+	}
+	if hc.watchesFile != "" {
+		watches, err := config.LoadWatches(hc.watchesFile)
+		if err != nil {
+			return fmt.Errorf("error loading watches: %w", err)
+		}
+		for _, w := range watches {
+			if err := hc.addWatch(w); err != nil {
+				return fmt.Errorf("error loading watches: %s: %w", w.Name, err)
+			}
+		}
+	}
+	if hc.watchStateFile != "" {
+		if err := hc.loadWatchState(hc.watchStateFile); err != nil {
+			return fmt.Errorf("error loading watch state: %w", err)
+		}
+	}
+	return nil
+}
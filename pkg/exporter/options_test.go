@@ -0,0 +1,62 @@
+package exporter
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOptionsInAnyOrder gives the options that depend on each other in the
+// reverse of the order they used to require.
+func TestOptionsInAnyOrder(t *testing.T) {
+	dir := t.TempDir()
+	watchesFile := filepath.Join(dir, "watches.yml")
+	require.NoError(t, config.SaveWatches(watchesFile, []config.WatchConfig{
+		{Name: "alice-carib", User: "alice", Match: map[string]string{"destinationcode": "CARIB"}},
+	}))
+	outbox, err := notify.LoadOutbox(filepath.Join(dir, "outbox.json"), time.Hour)
+	require.NoError(t, err)
+	dispatcher, err := notify.NewDispatcher(log.Default(), notify.NewWebhook("alice-hook", "http://hook.invalid"))
+	require.NoError(t, err)
+
+	e, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithWatchState(filepath.Join(dir, "state.json")),
+		WithWatchesFile(watchesFile),
+		WithWatches(config.WatchConfig{Name: "cheap", Below: 500, Match: map[string]string{"shipcode": "WN"}}),
+		WithUsers(config.UserConfig{Name: "alice", Password: "secret", Notify: []string{"alice-hook"}}),
+		WithNotificationOutbox(outbox, time.Minute),
+		WithNotifier(dispatcher),
+	)
+	require.NoError(t, err)
+
+	var names []string
+	for _, w := range e.currentWatches() {
+		names = append(names, w.Name)
+	}
+	assert.Equal(t, []string{"cheap", "alice-carib"}, names)
+	assert.Equal(t, []string{"alice-hook"}, e.currentWatches()[1].Notify, "the watch takes the notifiers of its user")
+	assert.Contains(t, e.watchLimits, "cheap")
+	assert.Contains(t, e.watchLimits, "alice-carib")
+}
+
+func TestNotificationOutboxRequiresNotifier(t *testing.T) {
+	outbox, err := notify.LoadOutbox(filepath.Join(t.TempDir(), "outbox.json"), time.Hour)
+	require.NoError(t, err)
+	_, err = NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithNotificationOutbox(outbox, time.Minute),
+	)
+	assert.EqualError(t, err, "a notification outbox requires a notifier")
+}
@@ -0,0 +1,53 @@
+package exporter
+
+import (
+	"errors"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+// minPageSize is the page size every target is known to accept.
+const minPageSize = 20
+
+var errParse = errors.New("Error parsing response")
+
+// pageRejected reports whether the target refused a page of count cruises or
+// sent back fewer than it has.
+func pageRejected(data *royalapi.Response, count int, err error) bool {
+	if err != nil {
+		return errors.Is(err, errParse)
+	}
+	n := len(data.Cruises())
+	if n == 0 && len(data.Errors) > 0 {
+		return true
+	}
+	return n < count && n < data.Total()
+}
+
+// maxPageSizeOf is the largest page size requested from the target.
+func (hc *Exporter) maxPageSizeOf(t config.Target) int {
+	if t.PageSize > 0 {
+		return t.PageSize
+	}
+	return hc.maxPageSize
+}
+
+// currentPageSize is the page size that last worked for the target, the
+// maximum until a scrape settled it.
+func (hc *Exporter) currentPageSize(t config.Target) int {
+	max := hc.maxPageSizeOf(t)
+	hc.pageSizesMu.Lock()
+	defer hc.pageSizesMu.Unlock()
+	if n, ok := hc.pageSizes[t.Name]; ok && n <= max {
+		return n
+	}
+	return max
+}
+
+func (hc *Exporter) setPageSize(target string, n int) {
+	hc.pageSizesMu.Lock()
+	hc.pageSizes[target] = n
+	hc.pageSizesMu.Unlock()
+	hc.pageSize.WithLabelValues(target).Set(float64(n))
+}
@@ -0,0 +1,28 @@
+package exporter
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer keeps an unusually large response from being held on to.
+const maxPooledBuffer = 4 << 20
+
+// buffers reuses response body buffers across pages, a full catalog scrape
+// reads hundreds of them.
+var buffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns b to the pool. b must not be used afterwards.
+func putBuffer(b *bytes.Buffer) {
+	if b == nil || b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	buffers.Put(b)
+}
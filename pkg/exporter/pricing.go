@@ -0,0 +1,73 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fetchPricingCalendars exports the pricing calendar of every configured
+// itinerary. An itinerary keeps its previous prices when a month fails.
+func (hc *Exporter) fetchPricingCalendars(ctx context.Context, t config.Target) {
+	cfg := hc.pricingCalendar
+	if cfg == nil {
+		return
+	}
+	if len(cfg.Targets) > 0 {
+		found := false
+		for _, name := range cfg.Targets {
+			found = found || name == t.Name
+		}
+		if !found {
+			return
+		}
+	}
+	for _, itinerary := range cfg.Itineraries {
+		if err := hc.fetchPricingCalendar(ctx, t, itinerary, cfg.Months); err != nil {
+			hc.logger.Printf("error fetching pricing calendar of %s from %s: %s", itinerary, t.Name, err)
+		}
+	}
+}
+
+func (hc *Exporter) fetchPricingCalendar(ctx context.Context, t config.Target, itinerary string, months int) error {
+	now := time.Now()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	var dates []royalapi.CalendarSailDate
+	for i := 0; i < months; i++ {
+		body, _, err := hc.post(ctx, t, 0, royalapi.NewCalendarRequest(itinerary, first.AddDate(0, i, 0)), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := royalapi.ParseCalendar(body.Bytes())
+		putBuffer(body)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errParse, err)
+		}
+		if len(resp.Errors) > 0 {
+			return fmt.Errorf("pricing calendar returned errors: %s", resp.Errors[0].Message)
+		}
+		dates = append(dates, resp.Data.PricingCalendar.SailDates...)
+	}
+
+	match := prometheus.Labels{"url": t.URL, "itinerary": itinerary}
+	hc.calendarPrice.deleteMatching(match)
+	for _, d := range dates {
+		for _, p := range d.Prices {
+			if p.LowestPrice.Value <= 0 {
+				continue
+			}
+			labels := prometheus.Labels{"url": t.URL, "itinerary": itinerary, "sail_date": d.SailDate, "super_category": p.SuperCategory}
+			for k, v := range hc.targetLabels(t) {
+				labels[k] = v
+			}
+			if err := hc.calendarPrice.set(labels, p.LowestPrice.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
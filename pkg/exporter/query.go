@@ -0,0 +1,35 @@
+package exporter
+
+import (
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+// queryOf returns the query sent to the target, the one built from its own
+// query features if it sets them.
+func (hc *Exporter) queryOf(t config.Target) royalapi.Query {
+	if t.QueryFeatures == "" {
+		return hc.query
+	}
+	hc.queriesMu.Lock()
+	defer hc.queriesMu.Unlock()
+	if q, ok := hc.queries[t.QueryFeatures]; ok {
+		return q
+	}
+	// validated with the target
+	features, _ := royalapi.ParseFeatures(t.QueryFeatures)
+	if hc.queries == nil {
+		hc.queries = map[string]royalapi.Query{}
+	}
+	q := royalapi.NewQuery(features)
+	hc.queries[t.QueryFeatures] = q
+	return q
+}
+
+// sortOf returns the order the search results of t are sorted by.
+func (hc *Exporter) sortOf(t config.Target) string {
+	if t.Sort != "" {
+		return t.Sort
+	}
+	return hc.sort
+}
@@ -0,0 +1,32 @@
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// NotReady returns why the exporter has nothing to serve yet, "" once a
+// scrape cycle completed or another replica leads, as long as no scrape
+// hangs.
+func (hc *Exporter) NotReady() string {
+	if hc.Hung() {
+		return "a scrape is hung"
+	}
+	if _, ok := hc.lastScrape.Load().(ScrapeReport); ok {
+		return ""
+	}
+	if hc.elector != nil && !hc.elector.IsLeader() {
+		return ""
+	}
+	return "no scrape has completed yet"
+}
+
+// serveReady answers 200 when the exporter is ready and 503 with the reason
+// when it isn't.
+func (hc *Exporter) serveReady(w http.ResponseWriter, r *http.Request) {
+	if reason := hc.NotReady(); reason != "" {
+		http.Error(w, reason, http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
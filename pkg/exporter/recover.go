@@ -0,0 +1,58 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+)
+
+// recovered runs f, turning a panic into a log line with the stack and a
+// count on royal_exporter_panics_total. It reports whether f panicked.
+func (hc *Exporter) recovered(component string, f func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			hc.panics.WithLabelValues(component).Inc()
+			hc.logger.Printf("panic in %s: %v\n%s", component, r, debug.Stack())
+			panicked = true
+		}
+	}()
+	f()
+	return false
+}
+
+// fetchRecovered runs fetch for the target, a panic failing the scrape of the
+// target rather than the cycle.
+func (hc *Exporter) fetchRecovered(fetch func(context.Context, config.Target) TargetReport, ctx context.Context, t config.Target) (report TargetReport) {
+	if hc.recovered("scrape", func() { report = fetch(ctx, t) }) {
+		report = TargetReport{Name: t.Name, URL: t.URL, Error: fmt.Sprintf("panic scraping %s", t.Name)}
+	}
+	return report
+}
+
+// supervise runs loop until ctx is done, restarting it after a panic. The
+// delay before a restart doubles with every panic in a row, up to the scrape
+// interval, so a loop crashing right away doesn't spin.
+func (hc *Exporter) supervise(component string, loop func()) {
+	delay := time.Second
+	for {
+		started := time.Now()
+		if !hc.recovered(component, loop) {
+			return
+		}
+		if time.Since(started) > hc.healthcheck_invertval {
+			delay = time.Second
+		}
+		hc.logger.Printf("restarting %s in %s", component, delay)
+		select {
+		case <-time.After(delay):
+		case <-hc.ctx.Done():
+			return
+		}
+		if delay *= 2; delay > hc.healthcheck_invertval {
+			delay = hc.healthcheck_invertval
+		}
+	}
+}
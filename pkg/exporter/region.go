@@ -0,0 +1,59 @@
+package exporter
+
+import (
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// regionOf is the region a cruise is sold under, its destination like
+// Caribbean or Alaska, else the region of its departure port.
+func regionOf(c royalapi.Cruise) string {
+	it := c.MasterSailing.Itinerary
+	if it.Destination.Name != "" {
+		return it.Destination.Name
+	}
+	return it.DeparturePort.Region
+}
+
+type regionStats struct {
+	sailings map[string]bool
+	lowest   float64
+}
+
+// exportRegions replaces the region overview of the target with the sailing
+// counts and cheapest prices of its last complete scrape.
+func (hc *Exporter) exportRegions(t config.Target, scraped map[string]*customMetric) error {
+	match := prometheus.Labels{"url": t.URL}
+	for k, v := range hc.targetLabels(t) {
+		match[k] = v
+	}
+	regions := map[string]*regionStats{}
+	for _, cm := range scraped {
+		r, ok := regions[cm.region]
+		if !ok {
+			r = &regionStats{sailings: map[string]bool{}, lowest: cm.price}
+			regions[cm.region] = r
+		}
+		r.sailings[cm.cruiseID+"\x00"+cm.itinerary+"\x00"+cm.dateLabel] = true
+		if cm.price < r.lowest {
+			r.lowest = cm.price
+		}
+	}
+
+	hc.regionSailings.deleteMatching(match)
+	hc.regionLowestPrice.deleteMatching(match)
+	for region, r := range regions {
+		labels := prometheus.Labels{"destination_region": region}
+		for k, v := range match {
+			labels[k] = v
+		}
+		if err := hc.regionSailings.set(labels, float64(len(r.sailings))); err != nil {
+			return err
+		}
+		if err := hc.regionLowestPrice.set(labels, r.lowest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
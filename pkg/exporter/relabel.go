@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"strings"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// relabel applies the rules to a series of the metric name and reports
+// whether the series should be kept. Replacements may only rewrite labels the
+// metric already has, so the label set always matches the GaugeVec.
+func relabel(rules []config.RelabelConfig, name string, labels prometheus.Labels) (prometheus.Labels, bool) {
+	if len(rules) == 0 {
+		return labels, true
+	}
+	out := make(prometheus.Labels, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+
+	for _, r := range rules {
+		if r.Action == config.RelabelLabelDrop {
+			for k := range out {
+				if r.Regex.MatchString(k) {
+					delete(out, k)
+				}
+			}
+			continue
+		}
+
+		values := make([]string, len(r.SourceLabels))
+		for i, l := range r.SourceLabels {
+			if l == "__name__" {
+				values[i] = name
+			} else {
+				values[i] = out[l]
+			}
+		}
+		value := strings.Join(values, r.Separator)
+
+		switch r.Action {
+		case config.RelabelKeep:
+			if !r.Regex.MatchString(value) {
+				return nil, false
+			}
+		case config.RelabelDrop:
+			if r.Regex.MatchString(value) {
+				return nil, false
+			}
+		case config.RelabelReplace:
+			if _, ok := out[r.TargetLabel]; !ok {
+				continue
+			}
+			idx := r.Regex.FindStringSubmatchIndex(value)
+			if idx == nil {
+				continue
+			}
+			out[r.TargetLabel] = string(r.Regex.ExpandString(nil, r.Replacement, value, idx))
+		}
+	}
+	return out, true
+}
+
+// keptLabels returns the label names that survive the labeldrop rules.
+func keptLabels(rules []config.RelabelConfig, names []string) []string {
+	kept := make([]string, 0, len(names))
+	for _, name := range names {
+		dropped := false
+		for _, r := range rules {
+			if r.Action == config.RelabelLabelDrop && r.Regex.MatchString(name) {
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
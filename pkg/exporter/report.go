@@ -0,0 +1,77 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// TargetReport summarises the scrape of one target.
+type TargetReport struct {
+	Name            string  `json:"name"`
+	URL             string  `json:"url"`
+	Pages           int     `json:"pages"`
+	Cruises         int     `json:"cruises"`
+	Sailings        int     `json:"sailings"`
+	Series          int     `json:"series_updated"`
+	Error           string  `json:"error,omitempty"`
+	Skipped         bool    `json:"skipped,omitempty"`
+	Partial         bool    `json:"partial,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// DuplicateCruises are cruises skipped because an earlier page of the
+	// scrape already had them.
+	DuplicateCruises int `json:"duplicate_cruises,omitempty"`
+	// Checksum hashes the prices of a complete scrape.
+	Checksum string `json:"checksum,omitempty"`
+	// RequestIDs are the IDs of the requests sent, in the order they were.
+	RequestIDs []string `json:"request_ids,omitempty"`
+}
+
+// ScrapeReport summarises one scrape cycle over every target.
+type ScrapeReport struct {
+	ID              string         `json:"scrape_id"`
+	Start           time.Time      `json:"start"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	Pages           int            `json:"pages"`
+	Cruises         int            `json:"cruises"`
+	Sailings        int            `json:"sailings"`
+	Series          int            `json:"series_updated"`
+	Errors          int            `json:"errors"`
+	Skipped         int            `json:"skipped"`
+	Partial         int            `json:"partial"`
+	Targets         []TargetReport `json:"targets"`
+}
+
+func (r *ScrapeReport) add(t TargetReport) {
+	r.Targets = append(r.Targets, t)
+	r.Pages += t.Pages
+	r.Cruises += t.Cruises
+	r.Sailings += t.Sailings
+	r.Series += t.Series
+	if t.Error != "" {
+		r.Errors++
+	}
+	if t.Skipped {
+		r.Skipped++
+	}
+	if t.Partial {
+		r.Partial++
+	}
+}
+
+func (r ScrapeReport) String() string {
+	return fmt.Sprintf("scrape finished scrape_id=%s targets=%d pages=%d cruises=%d sailings=%d series_updated=%d errors=%d skipped=%d partial=%d duration=%s",
+		r.ID, len(r.Targets), r.Pages, r.Cruises, r.Sailings, r.Series, r.Errors, r.Skipped, r.Partial,
+		time.Duration(r.DurationSeconds*float64(time.Second)).Round(time.Millisecond))
+}
+
+func (hc *Exporter) serveLastScrape(w http.ResponseWriter, r *http.Request) {
+	report, ok := hc.lastScrape.Load().(ScrapeReport)
+	if !ok {
+		http.Error(w, "no scrape has completed yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"math"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type rollup struct {
+	config.RollupConfig
+	guard *seriesGuard
+}
+
+type rollupGroup struct {
+	labels     prometheus.Labels
+	min, max   float64
+	sum, count float64
+}
+
+// exportRollups replaces the rollups of the target with the aggregations of
+// the prices of its last complete scrape.
+func (hc *Exporter) exportRollups(t config.Target, prices []prometheus.Labels, values []float64) error {
+	match := prometheus.Labels{"url": t.URL}
+	for k, v := range hc.targetLabels(t) {
+		match[k] = v
+	}
+	for _, r := range hc.rollups {
+		groups := map[string]*rollupGroup{}
+		for i, price := range prices {
+			labels := prometheus.Labels{}
+			for k, v := range match {
+				labels[k] = v
+			}
+			for _, by := range r.By {
+				labels[by] = hc.rollupDimension(price, by)
+			}
+			key := history.Key(labels)
+			g, ok := groups[key]
+			if !ok {
+				g = &rollupGroup{labels: labels, min: math.Inf(1), max: math.Inf(-1)}
+				groups[key] = g
+			}
+			g.min = math.Min(g.min, values[i])
+			g.max = math.Max(g.max, values[i])
+			g.sum += values[i]
+			g.count++
+		}
+
+		r.guard.deleteMatching(match)
+		for _, g := range groups {
+			for _, a := range r.Aggregations {
+				labels := prometheus.Labels{"aggregation": a}
+				for k, v := range g.labels {
+					labels[k] = v
+				}
+				if err := r.guard.set(labels, g.aggregate(a)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (hc *Exporter) rollupDimension(price prometheus.Labels, name string) string {
+	if name == "super_category" {
+		return hc.superCategoryOf(price["stateroomclass"])
+	}
+	if name == "month" {
+		// parsed back as the datelabel may have any -date-label-format
+		if t, ok := hc.parseDateLabel(price["datelabel"]); ok {
+			return t.Format("2006-01")
+		}
+		return ""
+	}
+	return price[name]
+}
+
+func (g *rollupGroup) aggregate(a string) float64 {
+	switch a {
+	case "min":
+		return g.min
+	case "max":
+		return g.max
+	case "avg":
+		return g.sum / g.count
+	}
+	return g.count
+}
@@ -0,0 +1,54 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exportertest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollupMonthWithCustomDateLabelFormat(t *testing.T) {
+	srv := exportertest.NewServer(exportertest.Cruise{
+		ID: "WN07RCI-1", Ship: "Wonder of the Seas", ShipCode: "WN", DeparturePort: "Port Canaveral", Destination: "CARIB", Nights: 7,
+		Sailings: []exportertest.Sailing{
+			{ID: "A", Itinerary: "WN07W375", SailDate: "2036-01-12", Prices: map[string]int{"I": 899}},
+			{ID: "B", Itinerary: "WN07W375", SailDate: "2036-01-19", Prices: map[string]int{"I": 949}},
+			{ID: "C", Itinerary: "WN07W375", SailDate: "2036-02-02", Prices: map[string]int{"I": 799}},
+		},
+	})
+	defer srv.Close()
+
+	e, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithDateLabels("Mon, 02 Jan 2006", ""),
+		WithRollups(config.RollupsConfig{Rules: []config.RollupConfig{{Name: "monthly", By: []string{"month"}, Aggregations: []string{"count", "min"}}}}),
+		WithTargets(config.Target{Name: "carib", URL: srv.URL}),
+	)
+	require.NoError(t, err)
+	e.ScrapeOnce()
+
+	got := map[string]float64{}
+	for _, s := range e.rollups[0].guard.series {
+		got[s.labels["month"]+" "+s.labels["aggregation"]] = s.value
+	}
+	assert.Equal(t, map[string]float64{
+		"2036-01 count": 2, "2036-01 min": 899,
+		"2036-02 count": 1, "2036-02 min": 799,
+	}, got)
+}
+
+func TestRollupDimensionMonth(t *testing.T) {
+	hc := &Exporter{}
+	require.NoError(t, WithDateLabels("02/01/2006", "Europe/Paris")(hc))
+	assert.Equal(t, "2036-03", hc.rollupDimension(prometheus.Labels{"datelabel": "14/03/2036"}, "month"))
+	assert.Equal(t, "", hc.rollupDimension(prometheus.Labels{"datelabel": "TBA"}, "month"))
+
+	require.NoError(t, WithDateLabels("", "")(hc))
+	assert.Equal(t, "2036-03", hc.rollupDimension(prometheus.Labels{"datelabel": "2036-03-14"}, "month"))
+}
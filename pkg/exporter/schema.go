@@ -0,0 +1,28 @@
+package exporter
+
+import (
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// checkSchema counts and logs the drift between the response body and the
+// royalapi model.
+func (hc *Exporter) checkSchema(url string, body []byte, data *royalapi.Response, query royalapi.Query) {
+	diff, err := royalapi.CheckSchema(body, data, query)
+	if err != nil {
+		return
+	}
+
+	hc.schemaWarnings.With(prometheus.Labels{"url": url, "kind": "missing"}).Add(float64(len(diff.Missing)))
+	hc.schemaWarnings.With(prometheus.Labels{"url": url, "kind": "unexpected"}).Add(float64(len(diff.Unexpected)))
+	hc.schemaWarnings.With(prometheus.Labels{"url": url, "kind": "empty"}).Add(float64(len(diff.Empty)))
+
+	// only log when the drift changes so a persistent mismatch doesn't flood the log
+	text := diff.String()
+	hc.lastSchemaDiffMu.Lock()
+	defer hc.lastSchemaDiffMu.Unlock()
+	if diff.Len() > 0 && hc.lastSchemaDiff[url] != text {
+		hc.logger.Printf("schema drift detected for %s: %s", url, text)
+	}
+	hc.lastSchemaDiff[url] = text
+}
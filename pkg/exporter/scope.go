@@ -0,0 +1,34 @@
+package exporter
+
+import (
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+// searchFilters are the filters of the searches of a scrape: the configured
+// filters, narrowed to the itineraries and products of the watches when they
+// are scoped, one search per kind of scope.
+func (hc *Exporter) searchFilters() []string {
+	var itineraries, products []string
+	seen := map[string]bool{}
+	for _, w := range hc.currentWatches() {
+		if w.Itinerary != "" && !seen["i:"+w.Itinerary] {
+			seen["i:"+w.Itinerary] = true
+			itineraries = append(itineraries, w.Itinerary)
+		}
+		if w.Product != "" && !seen["p:"+w.Product] {
+			seen["p:"+w.Product] = true
+			products = append(products, w.Product)
+		}
+	}
+	if len(itineraries) == 0 && len(products) == 0 {
+		return []string{hc.filters}
+	}
+	var filters []string
+	if len(itineraries) > 0 {
+		filters = append(filters, royalapi.AddFilter(hc.filters, royalapi.ItineraryFilter, itineraries...))
+	}
+	if len(products) > 0 {
+		filters = append(filters, royalapi.AddFilter(hc.filters, royalapi.ProductFilter, products...))
+	}
+	return filters
+}
@@ -0,0 +1,117 @@
+package exporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// sdTargetGroup is a target group of the Prometheus HTTP service discovery.
+type sdTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// serveSD lists a pseudo target per watch for http_sd_configs. The target is
+// this exporter, scraped on /api/v1/watch-metrics for the prices the watch
+// sees, and the labels are those the watch matches on.
+func (hc *Exporter) serveSD(w http.ResponseWriter, r *http.Request) {
+	u := userOf(r)
+	groups := []sdTargetGroup{}
+	for _, watch := range hc.currentWatches() {
+		if !visibleTo(watch, u) {
+			continue
+		}
+		labels := map[string]string{}
+		for k, v := range watch.Match {
+			labels[k] = v
+		}
+		if watch.Itinerary != "" {
+			labels["itinerary"] = watch.Itinerary
+		}
+		if watch.Product != "" {
+			labels["cruiseid"] = watch.Product
+		}
+		if watch.User != "" {
+			labels["user"] = watch.User
+		}
+		labels["watch"] = watch.Name
+		labels["__metrics_path__"] = "/api/v1/watch-metrics"
+		labels["__param_watch"] = watch.Name
+		groups = append(groups, sdTargetGroup{Targets: []string{r.Host}, Labels: labels})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+// serveWatchMetrics exports the prices the watch named by the watch
+// parameter last saw and whether it fires for them, the scrape of the
+// pseudo targets of serveSD.
+func (hc *Exporter) serveWatchMetrics(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("watch")
+	known := false
+	for _, watch := range hc.currentWatches() {
+		known = known || watch.Name == name && visibleTo(watch, userOf(r))
+	}
+	if !known {
+		http.Error(w, "unknown watch", http.StatusNotFound)
+		return
+	}
+
+	type series struct {
+		labels map[string]string
+		price  float64
+		firing bool
+	}
+	var watched []series
+	names := map[string]bool{}
+	hc.firingMu.Lock()
+	for key, p := range hc.watchedPrices {
+		if !strings.HasPrefix(key, name+"\x00") {
+			continue
+		}
+		_, firing := hc.firing[key]
+		watched = append(watched, series{labels: p.labels, price: p.price, firing: firing})
+		for k := range p.labels {
+			names[k] = true
+		}
+	}
+	hc.firingMu.Unlock()
+
+	labelNames := make([]string, 0, len(names))
+	for k := range names {
+		labelNames = append(labelNames, k)
+	}
+	sort.Strings(labelNames)
+	price := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "watch",
+		Name:      "price",
+		Help:      "Last price of a series the watch matches.",
+	}, labelNames)
+	firing := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "royal",
+		Subsystem: "watch",
+		Name:      "firing",
+		Help:      "1 if the watch fires for the series, else 0.",
+	}, labelNames)
+	for _, s := range watched {
+		values := make([]string, len(labelNames))
+		for i, k := range labelNames {
+			values[i] = s.labels[k]
+		}
+		price.WithLabelValues(values...).Set(s.price)
+		f := 0.0
+		if s.firing {
+			f = 1
+		}
+		firing.WithLabelValues(values...).Set(f)
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(price, firing)
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+)
+
+// userAgent is sent with every upstream request.
+const userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15"
+
+// session holds what the bootstrap page load handed out.
+type session struct {
+	cfg     config.SessionConfig
+	mu      sync.Mutex
+	cookies []*http.Cookie
+	headers http.Header
+	minted  time.Time
+}
+
+// age is 0 when there is no session.
+func (s *session) age() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.minted.IsZero() {
+		return 0
+	}
+	return time.Since(s.minted).Seconds()
+}
+
+// invalidate makes the next request load the bootstrap page again.
+func (s *session) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.minted = time.Time{}
+}
+
+// applySession adds the session cookies and headers to req, loading the
+// bootstrap page first when the session is missing or too old.
+func (hc *Exporter) applySession(ctx context.Context, req *http.Request) error {
+	s := hc.session
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.minted.IsZero() || time.Since(s.minted) > s.cfg.MaxAge {
+		if err := hc.bootstrapSession(ctx, s); err != nil {
+			hc.sessionBootstraps.WithLabelValues("error").Inc()
+			return fmt.Errorf("Error bootstrapping session: %w", err)
+		}
+		hc.sessionBootstraps.WithLabelValues("success").Inc()
+	}
+	for _, c := range s.cookies {
+		req.AddCookie(c)
+	}
+	for name, values := range s.headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	return nil
+}
+
+// bootstrapSession loads the bootstrap page and keeps its cookies and
+// captured headers. s.mu must be held.
+func (hc *Exporter) bootstrapSession(ctx context.Context, s *session) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.cfg.BootstrapURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html")
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", s.cfg.BootstrapURL, resp.Status)
+	}
+
+	s.cookies = resp.Cookies()
+	s.headers = http.Header{}
+	for _, name := range s.cfg.CaptureHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			s.headers.Set(name, v)
+		}
+	}
+	s.minted = time.Now()
+	hc.logger.Printf("bootstrapped session from %s with %d cookies and %d headers", s.cfg.BootstrapURL, len(s.cookies), len(s.headers))
+	return nil
+}
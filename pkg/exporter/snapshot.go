@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// snapshotPrefix names the families scrapes update series by series, those
+// served from the snapshot.
+const snapshotPrefix = "royal_external_"
+
+// snapshotGatherer serves the royal_external_ families as they were when the
+// last scrape cycle finished, so a pull while a scrape updates them never sees
+// half a scrape. The other families, the exporter's own instrumentation, are
+// gathered live. Until the first cycle finishes everything is live.
+type snapshotGatherer struct {
+	gatherer prometheus.Gatherer
+	// updated, if set, returns when the series were last set by metric name
+	// and seriesKey, stamped on the samples of the snapshot.
+	updated func() map[string]map[string]time.Time
+
+	mu       sync.RWMutex
+	families []*dto.MetricFamily
+	taken    bool
+}
+
+// take swaps in the current royal_external_ families as the snapshot.
+func (s *snapshotGatherer) take() error {
+	mfs, err := s.gatherer.Gather()
+	var updated map[string]map[string]time.Time
+	if s.updated != nil {
+		updated = s.updated()
+	}
+	families := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		if !strings.HasPrefix(mf.GetName(), snapshotPrefix) {
+			continue
+		}
+		if series, ok := updated[mf.GetName()]; ok {
+			stamp(mf, series)
+		}
+		families = append(families, mf)
+	}
+	s.mu.Lock()
+	s.families, s.taken = families, true
+	s.mu.Unlock()
+	return err
+}
+
+func (s *snapshotGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := s.gatherer.Gather()
+	s.mu.RLock()
+	families, taken := s.families, s.taken
+	s.mu.RUnlock()
+	if !taken {
+		return mfs, err
+	}
+	merged := make([]*dto.MetricFamily, 0, len(mfs)+len(families))
+	for _, mf := range mfs {
+		if !strings.HasPrefix(mf.GetName(), snapshotPrefix) {
+			merged = append(merged, mf)
+		}
+	}
+	merged = append(merged, families...)
+	return prometheus.Gatherers{staticGatherer(merged)}.Gather()
+}
+
+// stamp sets the timestamp of every sample of mf to when its series was
+// last set.
+func stamp(mf *dto.MetricFamily, updated map[string]time.Time) {
+	for _, m := range mf.Metric {
+		labels := make(prometheus.Labels, len(m.Label))
+		for _, l := range m.Label {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if t, ok := updated[seriesKey(labels)]; ok {
+			ms := t.UnixNano() / int64(time.Millisecond)
+			m.TimestampMs = &ms
+		}
+	}
+}
+
+type staticGatherer []*dto.MetricFamily
+
+func (g staticGatherer) Gather() ([]*dto.MetricFamily, error) {
+	return g, nil
+}
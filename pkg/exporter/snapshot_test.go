@@ -0,0 +1,89 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exportertest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// servedPrices returns the royal_external_price series /metrics serves by
+// url.
+func servedPrices(t *testing.T, e *Exporter) map[string]int {
+	t.Helper()
+	mfs, err := e.snapshot.Gather()
+	require.NoError(t, err)
+	urls := map[string]int{}
+	for _, mf := range mfs {
+		if mf.GetName() != "royal_external_price" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "url" {
+					urls[l.GetValue()]++
+				}
+			}
+		}
+	}
+	return urls
+}
+
+func TestOverlappingCycleKeepsLastScrapeAndSnapshot(t *testing.T) {
+	srv := exportertest.NewServer(exportertest.Cruise{
+		ID: "WN07RCI-1", Ship: "Wonder of the Seas", ShipCode: "WN", DeparturePort: "Port Canaveral", Destination: "CARIB", Nights: 7,
+		Sailings: []exportertest.Sailing{{ID: "A", Itinerary: "WN07W375", SailDate: "2036-01-12", Prices: map[string]int{"I": 899}}},
+	})
+	defer srv.Close()
+	e, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithTargets(config.Target{Name: "carib", URL: srv.URL}),
+	)
+	require.NoError(t, err)
+
+	first := e.ScrapeOnce()
+	require.Equal(t, 0, first.Skipped)
+
+	// a tick while the cycle still runs
+	requests := srv.Requests()
+	atomic.StoreInt32(&e.cycling, 1)
+	srv.SetCruises()
+	overlapping := e.ScrapeOnce()
+	atomic.StoreInt32(&e.cycling, 0)
+
+	assert.Equal(t, 1, overlapping.Skipped)
+	assert.Equal(t, requests, srv.Requests(), "the overlapping cycle must not scrape")
+	assert.Equal(t, first.ID, e.lastScrape.Load().(ScrapeReport).ID)
+	assert.Equal(t, map[string]int{srv.URL: 1}, servedPrices(t, e))
+}
+
+func TestRemovedTargetLeavesSnapshot(t *testing.T) {
+	cruise := func(id string) exportertest.Cruise {
+		return exportertest.Cruise{
+			ID: id, Ship: "Wonder of the Seas", ShipCode: "WN", DeparturePort: "Port Canaveral", Destination: "CARIB", Nights: 7,
+			Sailings: []exportertest.Sailing{{ID: id, Itinerary: "WN07W375", SailDate: "2036-01-12", Prices: map[string]int{"I": 899}}},
+		}
+	}
+	carib := exportertest.NewServer(cruise("WN07RCI-1"))
+	defer carib.Close()
+	baham := exportertest.NewServer(cruise("WN07RCI-2"))
+	defer baham.Close()
+	e, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithTargets(config.Target{Name: "carib", URL: carib.URL}, config.Target{Name: "baham", URL: baham.URL}),
+	)
+	require.NoError(t, err)
+	e.ScrapeOnce()
+	require.Equal(t, map[string]int{carib.URL: 1, baham.URL: 1}, servedPrices(t, e))
+
+	require.NoError(t, e.SetTargets([]config.Target{{Name: "carib", URL: carib.URL}}))
+	assert.Equal(t, map[string]int{carib.URL: 1}, servedPrices(t, e))
+}
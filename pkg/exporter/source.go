@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+)
+
+// source returns the source address or network interface the connections
+// to t go out from, those of the target if it sets either.
+func (hc *Exporter) source(t config.Target) (address, iface string) {
+	if t.SourceAddress != "" || t.Interface != "" {
+		return t.SourceAddress, t.Interface
+	}
+	return hc.sourceAddress, hc.sourceInterface
+}
+
+// dialFrom dials addr, an IP address and port, from the source of t.
+func (hc *Exporter) dialFrom(ctx context.Context, t config.Target, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	address, iface := hc.source(t)
+	if address == "" && iface == "" {
+		return d.DialContext(ctx, network, addr)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	remote := net.ParseIP(host)
+	if remote == nil {
+		return nil, fmt.Errorf("cannot bind a connection to %s to a source address", addr)
+	}
+	local := net.ParseIP(address)
+	if iface != "" {
+		// looked up on every dial, the addresses of a VPN interface change
+		// when it reconnects
+		if local, err = interfaceAddr(iface, remote.To4() != nil); err != nil {
+			return nil, err
+		}
+	}
+	if (local.To4() != nil) != (remote.To4() != nil) {
+		return nil, fmt.Errorf("source address %s cannot reach %s", local, remote)
+	}
+	d.LocalAddr = &net.TCPAddr{IP: local}
+	return d.DialContext(ctx, network, addr)
+}
+
+// interfaceAddr returns the first IPv4 or IPv6 address of the interface,
+// skipping link-local ones.
+func interfaceAddr(name string, ipv4 bool) (net.IP, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", name, err)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("addresses of interface %s: %w", name, err)
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() || (ipnet.IP.To4() != nil) != ipv4 {
+			continue
+		}
+		return ipnet.IP, nil
+	}
+	family := "IPv6"
+	if ipv4 {
+		family = "IPv4"
+	}
+	return nil, fmt.Errorf("interface %s has no %s address", name, family)
+}
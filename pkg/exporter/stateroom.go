@@ -0,0 +1,58 @@
+package exporter
+
+import (
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// superCategoryOf returns the super category of a stateroom class, the
+// configured one first.
+func (hc *Exporter) superCategoryOf(class string) string {
+	if c, ok := hc.superCategoryClasses[class]; ok {
+		return c
+	}
+	return royalapi.SuperCategory(class)
+}
+
+// exportSuperCategories replaces the super category prices of the target with
+// the cheapest class of every category and sailing of its last complete
+// scrape. Classes of unknown category are left out.
+func (hc *Exporter) exportSuperCategories(t config.Target, scraped map[string]*customMetric) error {
+	match := prometheus.Labels{"url": t.URL}
+	for k, v := range hc.targetLabels(t) {
+		match[k] = v
+	}
+	lowest := map[string]prometheus.Labels{}
+	prices := map[string]float64{}
+	for _, cm := range scraped {
+		category := hc.superCategoryOf(cm.stateroomClass)
+		if category == "" {
+			continue
+		}
+		labels := prometheus.Labels{
+			"cruiseid":       cm.cruiseID,
+			"itinerary":      cm.itinerary,
+			"datelabel":      cm.dateLabel,
+			"ship":           cm.ship,
+			"super_category": category,
+		}
+		for k, v := range match {
+			labels[k] = v
+		}
+		key := history.Key(labels)
+		if p, ok := prices[key]; !ok || cm.price < p {
+			lowest[key] = labels
+			prices[key] = cm.price
+		}
+	}
+
+	hc.superCategoryPrice.deleteMatching(match)
+	for key, labels := range lowest {
+		if err := hc.superCategoryPrice.set(labels, prices[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
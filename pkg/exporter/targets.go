@@ -0,0 +1,158 @@
+package exporter
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// currentTargets returns the configured targets followed by the discovered
+// ones.
+func (hc *Exporter) currentTargets() []config.Target {
+	hc.targetsMu.RLock()
+	defer hc.targetsMu.RUnlock()
+	sources := make([]string, 0, len(hc.discovered))
+	for source := range hc.discovered {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	targets := append([]config.Target(nil), hc.targets...)
+	seen := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		seen[t.Name] = true
+	}
+	for _, source := range sources {
+		for _, t := range hc.discovered[source] {
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				targets = append(targets, t)
+			}
+		}
+	}
+	return targets
+}
+
+// SetTargets replaces the configured targets, e.g. after the config file was
+// reloaded. Series of targets that disappeared are deleted.
+func (hc *Exporter) SetTargets(targets []config.Target) error {
+	if err := config.ValidateTargets(targets); err != nil {
+		return err
+	}
+	targets = hc.declaredLabelsOnly(targets)
+
+	hc.targetsMu.Lock()
+	removed := removedTargets(hc.targets, targets)
+	hc.targets = targets
+	hc.targetsMu.Unlock()
+
+	hc.deleteTargetSeries(removed)
+	return nil
+}
+
+// SetDiscoveredTargets replaces the targets found by the discovery source.
+// Labels that weren't declared with WithStaticLabelNames are dropped, and
+// series of targets that disappeared are deleted.
+func (hc *Exporter) SetDiscoveredTargets(source string, targets []config.Target) {
+	targets = hc.declaredLabelsOnly(targets)
+
+	hc.targetsMu.Lock()
+	removed := removedTargets(hc.discovered[source], targets)
+	hc.discovered[source] = targets
+	hc.targetsMu.Unlock()
+
+	hc.deleteTargetSeries(removed)
+}
+
+// declaredLabelsOnly drops the labels that aren't part of the label set the
+// metrics were created with.
+func (hc *Exporter) declaredLabelsOnly(targets []config.Target) []config.Target {
+	known := make(map[string]bool, len(hc.staticLabelNames))
+	for _, name := range hc.staticLabelNames {
+		known[name] = true
+	}
+	out := make([]config.Target, 0, len(targets))
+	for _, t := range targets {
+		labels := make(map[string]string, len(t.Labels))
+		for k, v := range t.Labels {
+			if !known[k] {
+				hc.logger.Printf("dropping label %q of target %s, label names can only change on restart", k, t.Name)
+				continue
+			}
+			labels[k] = v
+		}
+		t.Labels = labels
+		out = append(out, t)
+	}
+	return out
+}
+
+// removedTargets returns the targets of before that aren't in after with the
+// same URL and labels.
+func removedTargets(before, after []config.Target) []config.Target {
+	var removed []config.Target
+	for _, b := range before {
+		found := false
+		for _, a := range after {
+			if sameTarget(a, b) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			removed = append(removed, b)
+		}
+	}
+	return removed
+}
+
+func sameTarget(a, b config.Target) bool {
+	if a.Name != b.Name || a.URL != b.URL || len(a.Labels) != len(b.Labels) {
+		return false
+	}
+	for k, v := range a.Labels {
+		if b.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (hc *Exporter) deleteTargetSeries(targets []config.Target) {
+	for _, t := range targets {
+		hc.logger.Printf("target %s is gone, deleting its series", t.Name)
+		match := prometheus.Labels{"url": t.URL}
+		for k, v := range hc.targetLabels(t) {
+			match[k] = v
+		}
+		for _, g := range hc.guards() {
+			g.deleteMatching(match)
+		}
+		hc.scrapePartial.DeleteLabelValues(t.Name)
+		hc.pageSize.DeleteLabelValues(t.Name)
+		hc.duplicateCruises.DeleteLabelValues(t.Name)
+		hc.budgetRemaining.DeleteLabelValues(t.Name)
+		for _, family := range []string{"ipv4", "ipv6", ""} {
+			hc.requestDuration.DeleteLabelValues(t.Name, family)
+		}
+		for _, a := range latencyAggregations {
+			hc.pageLatency.DeleteLabelValues(t.Name, a)
+		}
+		hc.forgetDiff(t.Name)
+		hc.forgetChecksum(t)
+		for kind, results := range coverage {
+			for _, result := range results {
+				hc.parseCoverage.DeleteLabelValues(t.Name, kind, result)
+			}
+		}
+		hc.forgetOutcomes(t.Name)
+	}
+	// the deletions show on /metrics right away unless a cycle is running,
+	// which takes the snapshot when it finishes
+	if len(targets) > 0 && atomic.CompareAndSwapInt32(&hc.cycling, 0, 1) {
+		hc.takeSnapshot()
+		atomic.StoreInt32(&hc.cycling, 0)
+	}
+}
@@ -0,0 +1,27 @@
+package exporter
+
+import (
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+// How prices are exported with regard to taxes and fees, which some markets
+// include in the prices and others don't.
+const (
+	TaxesAsQuoted = "as-quoted"
+	TaxesExcluded = "base"
+	TaxesIncluded = "total"
+)
+
+// normalizePrice adds or removes the taxes and fees of the cruise so the
+// price matches hc.taxes. The API only reports them for the lowest priced
+// sailing, they are assumed to be the same for every sailing of the cruise.
+func (hc *Exporter) normalizePrice(c royalapi.Cruise, price float64) float64 {
+	lowest := c.LowestPriceSailing
+	switch {
+	case hc.taxes == TaxesIncluded && !lowest.TaxesAndFeesIncluded:
+		return price + lowest.TaxesAndFees.Value
+	case hc.taxes == TaxesExcluded && lowest.TaxesAndFeesIncluded:
+		return price - lowest.TaxesAndFees.Value
+	}
+	return price
+}
@@ -0,0 +1,36 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLTimingKeepsSubMillisecondPrecision(t *testing.T) {
+	e, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithLegacyMetricNames(true),
+	)
+	require.NoError(t, err)
+	require.NoError(t, e.updateCustomMetrics(&customMetric{url: "http://carib.invalid", dns: 1500 * time.Microsecond, total: 250 * time.Microsecond, price: 899}))
+
+	value := func(g *seriesGuard) float64 {
+		for _, s := range g.series {
+			return s.value
+		}
+		return -1
+	}
+	assert.Equal(t, 0.0015, value(e.urlDNS))
+	assert.Equal(t, 0.00025, value(e.urlResponse))
+	assert.Equal(t, 1.5, value(e.urlDNS.aliases[0].guard), "the legacy name is in milliseconds")
+
+	c := catalogTiming{DNSMS: milliseconds(1500 * time.Microsecond)}
+	assert.Equal(t, 1.5, c.DNSMS)
+	assert.Equal(t, 1500*time.Microsecond, fromMilliseconds(c.DNSMS))
+}
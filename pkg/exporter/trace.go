@@ -0,0 +1,144 @@
+package exporter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// requestIDHeader carries the request ID upstream so it shows up in the
+// logs of proxies and the target alike.
+const requestIDHeader = "X-Request-Id"
+
+type traceKey struct{}
+
+type spanKey struct{}
+
+type requestIDKey struct{}
+
+// scrapeTrace identifies a scrape of one target and the requests it sent.
+type scrapeTrace struct {
+	scrapeID string
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func withTrace(ctx context.Context, scrapeID string) (context.Context, *scrapeTrace) {
+	tr := &scrapeTrace{scrapeID: scrapeID}
+	return context.WithValue(ctx, traceKey{}, tr), tr
+}
+
+// traceOf returns the trace of the scrape ctx belongs to, nil outside of
+// scrapes.
+func traceOf(ctx context.Context) *scrapeTrace {
+	tr, _ := ctx.Value(traceKey{}).(*scrapeTrace)
+	return tr
+}
+
+// newRequest returns the ID of a new request of the scrape.
+func (tr *scrapeTrace) newRequest() string {
+	id := newID()
+	if tr != nil {
+		tr.mu.Lock()
+		tr.requests = append(tr.requests, id)
+		tr.mu.Unlock()
+	}
+	return id
+}
+
+func (tr *scrapeTrace) id() string {
+	if tr == nil {
+		return ""
+	}
+	return tr.scrapeID
+}
+
+func (tr *scrapeTrace) requestIDs() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return append([]string(nil), tr.requests...)
+}
+
+// withRequestID starts a request of the scrape, sent under the returned ID
+// by every post with the returned context.
+func withRequestID(ctx context.Context) (context.Context, string) {
+	id := traceOf(ctx).newRequest()
+	return context.WithValue(ctx, requestIDKey{}, id), id
+}
+
+func requestIDOf(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// traced adds the scrape and request ID to err.
+func (tr *scrapeTrace) traced(err error, requestID string) error {
+	if tr == nil {
+		return fmt.Errorf("%w (request %s)", err, requestID)
+	}
+	return fmt.Errorf("%w (scrape %s, request %s)", err, tr.scrapeID, requestID)
+}
+
+// Tracer starts the spans of scrapes, pages and requests, e.g. by handing
+// them to an OpenTelemetry tracer. Without one no spans are recorded.
+type Tracer interface {
+	// Start starts a span as a child of the span of ctx, if any, and returns
+	// the context carrying it.
+	Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. A span with a TraceID() string method
+// links the latency of its request to the trace by the trace_id exemplar.
+type Span interface {
+	// End ends the span, as failed when err isn't nil.
+	End(err error)
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(err error) {}
+
+// startSpan starts a span named name of the tracer, "scrape", "page" or
+// "request", with the scrape ID of ctx among its attributes.
+func (hc *Exporter) startSpan(ctx context.Context, name string, attributes map[string]string) (context.Context, Span) {
+	if id := traceOf(ctx).id(); id != "" {
+		attributes["scrape_id"] = id
+	}
+	ctx, span := hc.tracer.Start(ctx, name, attributes)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// observeRequest records how long a request took, linked to its scrape and
+// request ID by an exemplar so a slow request can be found in the logs, or to
+// its trace when the span has a trace ID.
+func (hc *Exporter) observeRequest(ctx context.Context, t config.Target, requestID, family string, took time.Duration) {
+	exemplar := prometheus.Labels{"request_id": requestID}
+	if tr := traceOf(ctx); tr != nil {
+		exemplar["scrape_id"] = tr.scrapeID
+	}
+	if span, ok := ctx.Value(spanKey{}).(interface{ TraceID() string }); ok && span.TraceID() != "" {
+		// exemplars are limited to 64 runes, the span carries the IDs as
+		// attributes instead
+		exemplar = prometheus.Labels{"trace_id": span.TraceID()}
+	}
+	hc.requestDuration.WithLabelValues(t.Name, family).(prometheus.ExemplarObserver).ObserveWithExemplar(took.Seconds(), exemplar)
+}
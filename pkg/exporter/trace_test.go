@@ -0,0 +1,151 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exportertest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedSpan struct {
+	name       string
+	parent     *recordedSpan
+	attributes map[string]string
+	ended      bool
+	err        error
+}
+
+func (s *recordedSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+func (s *recordedSpan) TraceID() string {
+	return "4bf92f3577b34da6a3ce929d0e0e4736"
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpanKey struct{}
+
+func (tr *recordingTracer) Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span) {
+	parent, _ := ctx.Value(recordedSpanKey{}).(*recordedSpan)
+	s := &recordedSpan{name: name, parent: parent, attributes: attributes}
+	tr.mu.Lock()
+	tr.spans = append(tr.spans, s)
+	tr.mu.Unlock()
+	return context.WithValue(ctx, recordedSpanKey{}, s), s
+}
+
+func TestScrapeSpans(t *testing.T) {
+	srv := exportertest.NewServer(exportertest.Cruise{
+		ID: "WN07RCI-1", Ship: "Wonder of the Seas", ShipCode: "WN", DeparturePort: "Port Canaveral", Destination: "CARIB", Nights: 7,
+		Sailings: []exportertest.Sailing{{ID: "A", Itinerary: "WN07W375", SailDate: "2036-01-12", Prices: map[string]int{"I": 899}}},
+	})
+	defer srv.Close()
+	tracer := &recordingTracer{}
+	e, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithTracer(tracer),
+		WithTargets(config.Target{Name: "carib", URL: srv.URL}),
+	)
+	require.NoError(t, err)
+
+	report := e.ScrapeOnce()
+	require.Len(t, report.Targets, 1)
+
+	var scrape, page, request *recordedSpan
+	for _, s := range tracer.spans {
+		assert.True(t, s.ended, "span %s not ended", s.name)
+		assert.NoError(t, s.err)
+		assert.Equal(t, report.ID, s.attributes["scrape_id"])
+		assert.Equal(t, "carib", s.attributes["target"])
+		switch s.name {
+		case "scrape":
+			scrape = s
+		case "page":
+			page = s
+		case "request":
+			request = s
+		}
+	}
+	require.NotNil(t, scrape)
+	require.NotNil(t, page)
+	require.NotNil(t, request)
+	assert.Nil(t, scrape.parent)
+	assert.Same(t, scrape, page.parent)
+	assert.Same(t, page, request.parent)
+	assert.Equal(t, "0", page.attributes["skip"])
+	assert.Equal(t, page.attributes["request_id"], request.attributes["request_id"])
+	assert.Equal(t, report.Targets[0].RequestIDs, []string{request.attributes["request_id"]})
+}
+
+func TestRequestExemplarLinksTrace(t *testing.T) {
+	srv := exportertest.NewServer()
+	defer srv.Close()
+	reg := prometheus.NewRegistry()
+	e, err := NewExporter(context.Background(),
+		WithRegistry(reg),
+		WithServeMux(http.NewServeMux()),
+		WithTracer(&recordingTracer{}),
+		WithTargets(config.Target{Name: "carib", URL: srv.URL}),
+	)
+	require.NoError(t, err)
+	e.ScrapeOnce()
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var exemplars []map[string]string
+	for _, f := range families {
+		if f.GetName() != "royal_exporter_request_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, b := range m.GetHistogram().GetBucket() {
+				if b.GetExemplar() == nil {
+					continue
+				}
+				labels := map[string]string{}
+				for _, l := range b.GetExemplar().GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+				exemplars = append(exemplars, labels)
+			}
+		}
+	}
+	require.NotEmpty(t, exemplars)
+	assert.Equal(t, map[string]string{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"}, exemplars[0])
+}
+
+func TestFailedRequestSpan(t *testing.T) {
+	srv := exportertest.NewServer()
+	srv.Close()
+	tracer := &recordingTracer{}
+	e, err := NewExporter(context.Background(),
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(http.NewServeMux()),
+		WithTracer(tracer),
+		WithTargets(config.Target{Name: "down", URL: srv.URL}),
+	)
+	require.NoError(t, err)
+
+	e.ScrapeOnce()
+	require.NotEmpty(t, tracer.spans)
+	for _, s := range tracer.spans {
+		assert.Error(t, s.err, "span %s", s.name)
+	}
+}
+
+func TestWithTracerRejectsNil(t *testing.T) {
+	assert.Error(t, WithTracer(nil)(&Exporter{}))
+}
@@ -0,0 +1,40 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// checkTrend exports the least squares slope of the price over the trend
+// window, in currency units per day.
+func (hc *Exporter) checkTrend(labels prometheus.Labels, now time.Time) error {
+	samples := hc.history.Samples(labels, now.Add(-hc.trend.Window))
+	if len(samples) < hc.trend.MinSamples {
+		return nil
+	}
+	slope, ok := slopePerDay(samples)
+	if !ok {
+		return nil
+	}
+	return hc.priceTrend.set(labels, slope)
+}
+
+func slopePerDay(samples []history.Sample) (float64, bool) {
+	t0 := samples[0].Time
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.Time.Sub(t0).Hours() / 24
+		n++
+		sumX += x
+		sumY += s.Value
+		sumXY += x * s.Value
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denom, true
+}
@@ -0,0 +1,283 @@
+package exporter
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+//go:embed ui/*.html
+var uiFiles embed.FS
+
+var uiTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"price":     func(v float64) string { return fmt.Sprintf("%.0f", v) },
+	"sparkline": sparkline,
+}).ParseFS(uiFiles, "ui/*.html"))
+
+// uiSailing is a sailing of the last complete scrape of a target.
+type uiSailing struct {
+	Target    string
+	CruiseID  string
+	Itinerary string
+	DateLabel string
+	Ship      string
+	Lowest    float64
+	Classes   int
+}
+
+func (s uiSailing) DatesURL() string {
+	return "/ui/dates?" + url.Values{"target": {s.Target}, "itinerary": {s.Itinerary}}.Encode()
+}
+
+func (s uiSailing) CompareURL() string {
+	return "/ui/compare?" + url.Values{
+		"target":    {s.Target},
+		"cruiseid":  {s.CruiseID},
+		"itinerary": {s.Itinerary},
+		"datelabel": {s.DateLabel},
+	}.Encode()
+}
+
+// uiClass is the price of a stateroom class of a sailing.
+type uiClass struct {
+	Class         string
+	SuperCategory string
+	Price         float64
+	// Delta is how much more the class costs than the cheapest one.
+	Delta   float64
+	History []history.Sample
+}
+
+// uiDate is a sail date of an itinerary with the price of the cheapest class
+// of every super category, in the order of SuperCategories.
+type uiDate struct {
+	uiSailing
+	Categories []*uiCategoryPrice
+	date       time.Time
+}
+
+type uiCategoryPrice struct {
+	Price float64
+	// Trend is the change since the sample recorded before the current one,
+	// 0 without history.
+	Trend  float64
+	labels map[string]string
+}
+
+// Arrow points the way the price last moved.
+func (p *uiCategoryPrice) Arrow() string {
+	switch {
+	case p == nil || p.Trend == 0:
+		return ""
+	case p.Trend > 0:
+		return "\u2191"
+	default:
+		return "\u2193"
+	}
+}
+
+// snapshotPrices returns the prices of the last complete scrape of every
+// target by target name.
+func (hc *Exporter) snapshotPrices() map[string][]*customMetric {
+	hc.diffMu.Lock()
+	defer hc.diffMu.Unlock()
+	prices := make(map[string][]*customMetric, len(hc.snapshots))
+	for target, s := range hc.snapshots {
+		for _, cm := range s.scraped {
+			prices[target] = append(prices[target], cm)
+		}
+	}
+	return prices
+}
+
+// serveUI lists the sailings of the last complete scrapes, each linking to
+// the comparison of its stateroom classes.
+func (hc *Exporter) serveUI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/ui/" {
+		http.NotFound(w, r)
+		return
+	}
+	sailings := map[string]*uiSailing{}
+	for target, prices := range hc.snapshotPrices() {
+		for _, cm := range prices {
+			key := strings.Join([]string{target, cm.cruiseID, cm.itinerary, cm.dateLabel}, "\x00")
+			s, ok := sailings[key]
+			if !ok {
+				s = &uiSailing{Target: target, CruiseID: cm.cruiseID, Itinerary: cm.itinerary, DateLabel: cm.dateLabel, Ship: cm.ship, Lowest: cm.price}
+				sailings[key] = s
+			}
+			s.Classes++
+			if cm.price < s.Lowest {
+				s.Lowest = cm.price
+			}
+		}
+	}
+	keys := make([]string, 0, len(sailings))
+	for key := range sailings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := make([]uiSailing, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, *sailings[key])
+	}
+	hc.renderUI(w, "index.html", list)
+}
+
+// serveCompare shows every stateroom class of a sailing side by side, with
+// its price history and how much more it costs than the cheapest class.
+func (hc *Exporter) serveCompare(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s := uiSailing{Target: q.Get("target"), CruiseID: q.Get("cruiseid"), Itinerary: q.Get("itinerary"), DateLabel: q.Get("datelabel")}
+	var classes []uiClass
+	for _, cm := range hc.snapshotPrices()[s.Target] {
+		if cm.cruiseID != s.CruiseID || cm.itinerary != s.Itinerary || cm.dateLabel != s.DateLabel {
+			continue
+		}
+		s.Ship = cm.ship
+		c := uiClass{Class: cm.stateroomClass, SuperCategory: hc.superCategoryOf(cm.stateroomClass), Price: cm.price}
+		if hc.history != nil {
+			c.History = hc.history.Samples(cm.priceLabels(), time.Time{})
+		}
+		classes = append(classes, c)
+	}
+	if len(classes) == 0 {
+		http.Error(w, "sailing not found in the last complete scrape", http.StatusNotFound)
+		return
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if classes[i].Price != classes[j].Price {
+			return classes[i].Price < classes[j].Price
+		}
+		return classes[i].Class < classes[j].Class
+	})
+	for i := range classes {
+		classes[i].Delta = classes[i].Price - classes[0].Price
+	}
+	s.Lowest, s.Classes = classes[0].Price, len(classes)
+	hc.renderUI(w, "compare.html", struct {
+		Sailing uiSailing
+		Classes []uiClass
+		History bool
+		// Watches tells whether watches can be added through the API
+		Watches bool
+	}{s, classes, hc.history != nil, hc.watchesFile != "" && len(hc.users) > 0})
+}
+
+// serveDates lists every sail date of an itinerary like the date picker of
+// the website, with the price of each super category and where it is headed.
+func (hc *Exporter) serveDates(w http.ResponseWriter, r *http.Request) {
+	target, itinerary := r.URL.Query().Get("target"), r.URL.Query().Get("itinerary")
+	dates := map[string]*uiDate{}
+	for _, cm := range hc.snapshotPrices()[target] {
+		if cm.itinerary != itinerary {
+			continue
+		}
+		i := categoryIndex(hc.superCategoryOf(cm.stateroomClass))
+		if i < 0 {
+			continue
+		}
+		key := cm.cruiseID + "\x00" + cm.dateLabel
+		d, ok := dates[key]
+		if !ok {
+			d = &uiDate{
+				uiSailing:  uiSailing{Target: target, CruiseID: cm.cruiseID, Itinerary: itinerary, DateLabel: cm.dateLabel, Ship: cm.ship, Lowest: cm.price},
+				Categories: make([]*uiCategoryPrice, len(royalapi.SuperCategories)),
+			}
+			d.date, _ = hc.parseDateLabel(cm.dateLabel)
+			dates[key] = d
+		}
+		if cm.price < d.Lowest {
+			d.Lowest = cm.price
+		}
+		if p := d.Categories[i]; p == nil || cm.price < p.Price {
+			d.Categories[i] = &uiCategoryPrice{Price: cm.price, labels: cm.priceLabels()}
+		}
+	}
+	if len(dates) == 0 {
+		http.Error(w, "itinerary not found in the last complete scrape", http.StatusNotFound)
+		return
+	}
+	list := make([]*uiDate, 0, len(dates))
+	for _, d := range dates {
+		if hc.history != nil {
+			for _, p := range d.Categories {
+				if p == nil {
+					continue
+				}
+				if samples := hc.history.Samples(p.labels, time.Time{}); len(samples) > 1 {
+					p.Trend = p.Price - samples[len(samples)-2].Value
+				}
+			}
+		}
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].date.Equal(list[j].date) {
+			return list[i].date.Before(list[j].date)
+		}
+		return list[i].CruiseID < list[j].CruiseID
+	})
+	hc.renderUI(w, "dates.html", struct {
+		Target     string
+		Itinerary  string
+		Categories []string
+		Dates      []*uiDate
+	}{target, itinerary, royalapi.SuperCategories, list})
+}
+
+func categoryIndex(category string) int {
+	for i, c := range royalapi.SuperCategories {
+		if c == category {
+			return i
+		}
+	}
+	return -1
+}
+
+func (hc *Exporter) renderUI(w http.ResponseWriter, name string, data interface{}) {
+	var b strings.Builder
+	if err := uiTemplates.ExecuteTemplate(&b, name, data); err != nil {
+		hc.logger.Printf("error rendering %s: %s", name, err)
+		http.Error(w, "error rendering page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// sparkline draws the samples as an inline SVG line, nothing for fewer than
+// two samples.
+func sparkline(samples []history.Sample) template.HTML {
+	const width, height = 120.0, 24.0
+	if len(samples) < 2 {
+		return ""
+	}
+	min, max := samples[0].Value, samples[0].Value
+	for _, s := range samples {
+		if s.Value < min {
+			min = s.Value
+		}
+		if s.Value > max {
+			max = s.Value
+		}
+	}
+	points := make([]string, len(samples))
+	for i, s := range samples {
+		y := height / 2
+		if max > min {
+			y = height - (s.Value-min)/(max-min)*height
+		}
+		points[i] = fmt.Sprintf("%.1f,%.1f", float64(i)/float64(len(samples)-1)*width, y)
+	}
+	return template.HTML(fmt.Sprintf(`<svg width="%.0f" height="%.0f" viewBox="-1 -1 %.0f %.0f"><polyline fill="none" stroke="currentColor" points="%s"/></svg>`,
+		width, height, width+2, height+2, strings.Join(points, " ")))
+}
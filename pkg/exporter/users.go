@@ -0,0 +1,42 @@
+package exporter
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+)
+
+type userKey struct{}
+
+// signedIn requires the users, if any, to sign in with basic auth before h
+// serves them. h finds the user with userOf.
+func (hc *Exporter) signedIn(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(hc.users) == 0 {
+			h(w, r)
+			return
+		}
+		name, password, _ := r.BasicAuth()
+		u, ok := hc.users[name]
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(u.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="royalcaribbean-prometheus-exporter"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), userKey{}, u)))
+	}
+}
+
+// userOf returns the user signed in for r, nil without users.
+func userOf(r *http.Request) *config.UserConfig {
+	u, _ := r.Context().Value(userKey{}).(*config.UserConfig)
+	return u
+}
+
+// visibleTo reports whether the watch is listed to the user: every watch
+// without users, else only those the user owns.
+func visibleTo(w config.WatchConfig, u *config.UserConfig) bool {
+	return u == nil || w.User == u.Name
+}
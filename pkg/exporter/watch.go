@@ -0,0 +1,245 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// firingWatch is a price series currently matching a watch.
+type firingWatch struct {
+	watch     string
+	labels    map[string]string
+	price     float64
+	link      string
+	startsAt  time.Time
+	evaluated time.Time
+	notified  time.Time
+}
+
+// watchedPrice is the last price of a series matching a watch, the previous
+// price of the events of the watch and what /calendar.ics shows.
+type watchedPrice struct {
+	labels    map[string]string
+	price     float64
+	min       float64
+	link      string
+	evaluated time.Time
+}
+
+// evaluateWatches updates the firing state of every watch matching the
+// series and notifies about the ones that start firing.
+func (hc *Exporter) evaluateWatches(labels prometheus.Labels, price float64, link string) {
+	now := time.Now()
+	recorded := false
+	watches, limits := hc.currentWatchLimits()
+	for i := range watches {
+		w := &watches[i]
+		if !w.Matches(labels) {
+			continue
+		}
+		key := w.Name + "\x00" + history.Key(labels)
+
+		hc.firingMu.Lock()
+		last, seen := hc.watchedPrices[key]
+		previous := last.price
+		low := price
+		if seen && last.min < low {
+			low = last.min
+		}
+		hc.watchedPrices[key] = watchedPrice{labels: labels, price: price, min: low, link: link, evaluated: now}
+		firing := hc.watchFires(w, labels, price, last, now)
+		f, was := hc.firing[key]
+		remind := false
+		switch {
+		case firing && !was:
+			f = &firingWatch{watch: w.Name, labels: labels, startsAt: now, notified: now}
+			hc.firing[key] = f
+			fallthrough
+		case firing:
+			f.price, f.link, f.evaluated = price, link, now
+			if was && w.ResendInterval > 0 && now.Sub(f.notified) >= w.ResendInterval {
+				f.notified = now
+				remind = true
+			}
+		case was:
+			delete(hc.firing, key)
+			hc.resolved = append(hc.resolved, f)
+		}
+		hc.firingMu.Unlock()
+
+		// the feed shows a series once however many watches match it
+		if !recorded {
+			hc.recordPriceChange(labels, seen, previous, price, link, now)
+			recorded = true
+		}
+		if firing && !was {
+			hc.logger.Printf("watch %s firing for %s %s %s at %.0f", w.Name, labels["ship"], labels["datelabel"], labels["stateroomclass"], price)
+			hc.notifyWatch(w, limits[w.Name], fmt.Sprintf("watch\x00%s\x00%.0f", key, price), notify.Event{
+				Kind:          "watch",
+				Rule:          w.Name,
+				Priority:      w.Priority,
+				Title:         fmt.Sprintf("%s: %s sailing %s at %.0f", w.Name, labels["ship"], labels["datelabel"], price),
+				Text:          fmt.Sprintf("Stateroom class %s of cruise %s is now %.0f", labels["stateroomclass"], labels["cruiseid"], price),
+				Labels:        labels,
+				URL:           hc.absoluteLink(link),
+				Price:         price,
+				PreviousPrice: previous,
+			})
+		}
+		if remind {
+			hc.notifyWatch(w, limits[w.Name], "", notify.Event{
+				Kind:          "watch",
+				Rule:          w.Name,
+				Priority:      w.Priority,
+				Title:         fmt.Sprintf("%s: %s sailing %s still at %.0f", w.Name, labels["ship"], labels["datelabel"], price),
+				Text:          fmt.Sprintf("Stateroom class %s of cruise %s has been below the watch since %s and is now %.0f", labels["stateroomclass"], labels["cruiseid"], f.startsAt.Format("2006-01-02"), price),
+				Labels:        labels,
+				URL:           hc.absoluteLink(link),
+				Price:         price,
+				PreviousPrice: previous,
+			})
+		}
+	}
+}
+
+// watchFires reports whether price is below the watch and its condition, if
+// any, holds. A condition failing to evaluate is logged once per watch and
+// doesn't fire. The caller holds firingMu.
+func (hc *Exporter) watchFires(w *config.WatchConfig, labels map[string]string, price float64, last watchedPrice, now time.Time) bool {
+	if w.Below > 0 && price >= w.Below {
+		return false
+	}
+	if w.Condition == nil {
+		return true
+	}
+	vars := hc.seriesVars(labels, price, now)
+	vars["previous_price"] = last.price
+	vars["min_observed"] = last.min
+	fires, err := w.Condition.Bool(vars)
+	if err != nil {
+		if !hc.conditionFailed[w.Name] {
+			hc.conditionFailed[w.Name] = true
+			hc.logger.Printf("watch %s: error evaluating condition %q: %s", w.Name, w.Condition, err)
+		}
+		return false
+	}
+	return fires
+}
+
+// watchLimit holds the dedup window and rate limit of a watch.
+type watchLimit struct {
+	dedup *notify.Dedup
+	rate  *notify.Limiter
+}
+
+func newWatchLimit(w config.WatchConfig) *watchLimit {
+	l := &watchLimit{}
+	if w.DedupWindow > 0 {
+		l.dedup = notify.NewDedup(w.DedupWindow)
+	}
+	if w.RateLimit != nil {
+		l.rate = notify.NewLimiter(w.RateLimit.Count, w.RateLimit.Interval)
+	}
+	return l
+}
+
+// notifyWatch sends an event of the watch unless dedupKey was notified within
+// its dedup window or it exceeds its rate limit l. An empty dedupKey is never
+// deduplicated. Without l the watch was deleted and notifies nothing.
+func (hc *Exporter) notifyWatch(w *config.WatchConfig, l *watchLimit, dedupKey string, e notify.Event) {
+	if !hc.isLeader() || l == nil {
+		return
+	}
+	now := time.Now()
+	if dedupKey != "" && !l.dedup.Allow(dedupKey, now) {
+		hc.logger.Printf("watch %s: dropping duplicate notification %q", w.Name, e.Title)
+		return
+	}
+	if !l.rate.Allow(now) {
+		hc.logger.Printf("watch %s: rate limit exceeded, dropping notification %q", w.Name, e.Title)
+		return
+	}
+	e.User = w.User
+	hc.notifier.Send(hc.ctx, w.Notify, e)
+}
+
+// flushAlerts resolves watches whose series stopped showing up, exports the
+// firing counts and sends the alerts to Alertmanager.
+func (hc *Exporter) flushAlerts(now time.Time) {
+	watches := hc.currentWatches()
+	// watches managed through the API may have been deleted with alerts
+	// left to resolve
+	if len(watches) == 0 && hc.watchesFile == "" {
+		return
+	}
+	stale := now.Add(-3 * hc.healthcheck_invertval)
+	hold := now.Add(3 * hc.healthcheck_invertval)
+
+	counts := map[string]int{}
+	var alerts []notify.Alert
+	hc.firingMu.Lock()
+	for key, f := range hc.firing {
+		if f.evaluated.Before(stale) {
+			delete(hc.firing, key)
+			hc.resolved = append(hc.resolved, f)
+			continue
+		}
+		counts[f.watch]++
+		alerts = append(alerts, hc.alert(f, hold))
+	}
+	for key, p := range hc.watchedPrices {
+		if p.evaluated.Before(stale) {
+			delete(hc.watchedPrices, key)
+		}
+	}
+	for _, f := range hc.resolved {
+		alerts = append(alerts, hc.alert(f, now))
+	}
+	hc.resolved = nil
+	hc.firingMu.Unlock()
+
+	for _, w := range watches {
+		hc.watchFiring.WithLabelValues(w.Name).Set(float64(counts[w.Name]))
+	}
+	if err := hc.saveWatchState(); err != nil {
+		hc.logger.Printf("error saving watch state: %s", err)
+	}
+	if hc.alertmanager != nil && hc.isLeader() {
+		if err := hc.alertmanager.Post(hc.ctx, alerts); err != nil {
+			hc.logger.Printf("error sending %d alerts to alertmanager: %s", len(alerts), err)
+		}
+	}
+}
+
+func (hc *Exporter) alert(f *firingWatch, endsAt time.Time) notify.Alert {
+	labels := map[string]string{"alertname": "RoyalCaribbeanPriceWatch", "watch": f.watch}
+	for k, v := range f.labels {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+	return notify.Alert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary": fmt.Sprintf("%s sailing %s stateroom class %s at %.0f", f.labels["ship"], f.labels["datelabel"], f.labels["stateroomclass"], f.price),
+			"price":   fmt.Sprintf("%.0f", f.price),
+		},
+		StartsAt:     f.startsAt,
+		EndsAt:       endsAt,
+		GeneratorURL: hc.absoluteLink(f.link),
+	}
+}
+
+// absoluteLink turns a relative booking link into one users can click.
+func (hc *Exporter) absoluteLink(link string) string {
+	if link == "" || strings.Contains(link, "://") || hc.externalURL == "" {
+		return link
+	}
+	return strings.TrimSuffix(hc.externalURL, "/") + "/" + strings.TrimPrefix(link, "/")
+}
@@ -0,0 +1,385 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+)
+
+// APIWatch is a watch as /api/v1/watches shows and takes it. Cruise and
+// StateroomClass are shorthands for the product and the stateroomclass label.
+type APIWatch struct {
+	Name           string            `json:"name"`
+	Cruise         string            `json:"cruise,omitempty"`
+	Itinerary      string            `json:"itinerary,omitempty"`
+	StateroomClass string            `json:"stateroom_class,omitempty"`
+	Match          map[string]string `json:"match,omitempty"`
+	Below          float64           `json:"below,omitempty"`
+	Condition      string            `json:"condition,omitempty"`
+	Notify         []string          `json:"notify,omitempty"`
+	Priority       string            `json:"priority,omitempty"`
+	// User owns the watch, the signed in user for the watches it adds.
+	User string `json:"user,omitempty"`
+	// Managed is false for the watches of the config file, which the API
+	// cannot delete.
+	Managed bool `json:"managed"`
+}
+
+func (a APIWatch) config() (config.WatchConfig, error) {
+	w := config.WatchConfig{Name: a.Name, Product: a.Cruise, Itinerary: a.Itinerary, Below: a.Below, Notify: a.Notify, Priority: a.Priority, User: a.User}
+	if a.Condition != "" {
+		var err error
+		if w.Condition, err = config.NewExpression(a.Condition); err != nil {
+			return w, err
+		}
+	}
+	if len(a.Match) > 0 || a.StateroomClass != "" {
+		w.Match = map[string]string{}
+		for k, v := range a.Match {
+			w.Match[k] = v
+		}
+		if a.StateroomClass != "" {
+			w.Match["stateroomclass"] = a.StateroomClass
+		}
+	}
+	return w, nil
+}
+
+func apiWatch(w config.WatchConfig, managed bool) APIWatch {
+	return APIWatch{
+		Name:      w.Name,
+		Cruise:    w.Product,
+		Itinerary: w.Itinerary,
+		Match:     w.Match,
+		Below:     w.Below,
+		Condition: conditionOf(w),
+		Notify:    w.Notify,
+		Priority:  w.Priority,
+		User:      w.User,
+		Managed:   managed,
+	}
+}
+
+func conditionOf(w config.WatchConfig) string {
+	if w.Condition == nil {
+		return ""
+	}
+	return w.Condition.String()
+}
+
+// currentWatches returns the watches. The slice is replaced rather than
+// modified when watches are added or deleted, callers may keep it.
+func (hc *Exporter) currentWatches() []config.WatchConfig {
+	hc.watchesMu.RLock()
+	defer hc.watchesMu.RUnlock()
+	return hc.watches
+}
+
+// currentWatchLimits returns the watches with their limits, taken together
+// so every watch has its limit. The map is replaced like the slice.
+func (hc *Exporter) currentWatchLimits() ([]config.WatchConfig, map[string]*watchLimit) {
+	hc.watchesMu.RLock()
+	defer hc.watchesMu.RUnlock()
+	return hc.watches, hc.watchLimits
+}
+
+// setWatchLimit replaces the limits with a copy setting the limit of name, or
+// leaving it out when l is nil. Callers hold watchesMu.
+func (hc *Exporter) setWatchLimit(name string, l *watchLimit) {
+	limits := make(map[string]*watchLimit, len(hc.watchLimits)+1)
+	for k, v := range hc.watchLimits {
+		limits[k] = v
+	}
+	if l == nil {
+		delete(limits, name)
+	} else {
+		limits[name] = l
+	}
+	hc.watchLimits = limits
+}
+
+// addWatch validates a watch against the others and starts evaluating it.
+func (hc *Exporter) addWatch(w config.WatchConfig) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	if w.User != "" && len(hc.users) > 0 {
+		u, ok := hc.users[w.User]
+		if !ok {
+			return fmt.Errorf("unknown user %q", w.User)
+		}
+		if err := u.OwnWatch(&w); err != nil {
+			return err
+		}
+	}
+	if hc.notifier != nil {
+		known := map[string]bool{}
+		for _, name := range hc.notifier.Names() {
+			known[name] = true
+		}
+		for _, name := range w.Notify {
+			if !known[name] {
+				return fmt.Errorf("unknown notifier %q", name)
+			}
+		}
+	}
+	hc.watchesMu.Lock()
+	defer hc.watchesMu.Unlock()
+	for _, other := range hc.watches {
+		if other.Name == w.Name {
+			return fmt.Errorf("duplicate watch name %q", w.Name)
+		}
+		// an unscoped watch would only see the scoped products
+		if other.Scoped() != w.Scoped() {
+			return fmt.Errorf("either all watches or none set itinerary or product")
+		}
+	}
+	hc.watches = append(hc.watches[:len(hc.watches):len(hc.watches)], w)
+	hc.managedWatches[w.Name] = true
+	hc.setWatchLimit(w.Name, newWatchLimit(w))
+	return nil
+}
+
+// deleteWatch stops evaluating a watch added through the API, its firing
+// series resolve with the next alerts.
+func (hc *Exporter) deleteWatch(name string) error {
+	hc.watchesMu.Lock()
+	if !hc.managedWatches[name] {
+		hc.watchesMu.Unlock()
+		return errWatchNotManaged
+	}
+	watches := make([]config.WatchConfig, 0, len(hc.watches))
+	for _, w := range hc.watches {
+		if w.Name != name {
+			watches = append(watches, w)
+		}
+	}
+	hc.watches = watches
+	delete(hc.managedWatches, name)
+	hc.setWatchLimit(name, nil)
+	hc.watchesMu.Unlock()
+
+	hc.firingMu.Lock()
+	delete(hc.conditionFailed, name)
+	for key, f := range hc.firing {
+		if f.watch == name {
+			delete(hc.firing, key)
+			hc.resolved = append(hc.resolved, f)
+		}
+	}
+	hc.firingMu.Unlock()
+	hc.watchFiring.DeleteLabelValues(name)
+	return nil
+}
+
+func (hc *Exporter) isManagedWatch(name string) bool {
+	hc.watchesMu.RLock()
+	defer hc.watchesMu.RUnlock()
+	return hc.managedWatches[name]
+}
+
+var errWatchNotManaged = fmt.Errorf("watch is not managed through the API")
+
+// saveWatches writes the watches added through the API to the watches file,
+// leaving out the watch named without.
+func (hc *Exporter) saveWatches(without string) error {
+	var managed []config.WatchConfig
+	hc.watchesMu.RLock()
+	for _, w := range hc.watches {
+		if hc.managedWatches[w.Name] && w.Name != without {
+			managed = append(managed, w)
+		}
+	}
+	hc.watchesMu.RUnlock()
+	return config.SaveWatches(hc.watchesFile, managed)
+}
+
+// mayManageWatches reports whether r may add or delete watches, answering it
+// with an error if not. Without users anyone reaching the port could, so the
+// API then only lists the watches. Browsers send the form of the web UI from
+// other sites too, which the Origin or Referer of its own host rules out.
+func (hc *Exporter) mayManageWatches(w http.ResponseWriter, r *http.Request, form bool) bool {
+	switch {
+	case hc.watchesFile == "":
+		http.Error(w, "watches can only be managed with a watches file", http.StatusForbidden)
+		return false
+	case len(hc.users) == 0:
+		http.Error(w, "watches can only be managed with users", http.StatusForbidden)
+		return false
+	case form && !sameOrigin(r):
+		http.Error(w, "cross-origin request refused", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// sameOrigin reports whether the Origin, or else the Referer, of r is on the
+// host r was sent to. Requests with neither don't come from a browser.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Referer()
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// serveWatches lists the watches on GET and adds one on POST, taking an
+// APIWatch as JSON or as the form of the web UI.
+func (hc *Exporter) serveWatches(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		u := userOf(r)
+		hc.watchesMu.RLock()
+		list := make([]APIWatch, 0, len(hc.watches))
+		for _, watch := range hc.watches {
+			if visibleTo(watch, u) {
+				list = append(list, apiWatch(watch, hc.managedWatches[watch.Name]))
+			}
+		}
+		hc.watchesMu.RUnlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Watches []APIWatch `json:"watches"`
+		}{list})
+	case http.MethodPost:
+		var a APIWatch
+		form := !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+		if !hc.mayManageWatches(w, r, form) {
+			return
+		}
+		if form {
+			var err error
+			if a, err = formWatch(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			http.Error(w, "invalid watch: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.User = ""
+		if u := userOf(r); u != nil {
+			a.User = u.Name
+		}
+		wc, err := a.config()
+		hc.watchesSaveMu.Lock()
+		if err == nil {
+			err = hc.addWatch(wc)
+		}
+		if err != nil {
+			hc.watchesSaveMu.Unlock()
+			http.Error(w, "invalid watch: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := hc.saveWatches(""); err != nil {
+			hc.deleteWatch(a.Name)
+			hc.watchesSaveMu.Unlock()
+			hc.logger.Printf("error saving watches: %s", err)
+			http.Error(w, "error saving watches", http.StatusInternalServerError)
+			return
+		}
+		hc.watchesSaveMu.Unlock()
+		hc.logger.Printf("watch %s added through the API", a.Name)
+		hc.Audit(AuditWatchAdded, a.User, map[string]string{"watch": a.Name})
+		if form {
+			back := r.Referer()
+			if back == "" {
+				back = "/ui/"
+			}
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// formWatch reads a watch from the form of the web UI, match.<label> fields
+// going into the match.
+func formWatch(r *http.Request) (APIWatch, error) {
+	if err := r.ParseForm(); err != nil {
+		return APIWatch{}, err
+	}
+	a := APIWatch{
+		Name:           r.PostForm.Get("name"),
+		Cruise:         r.PostForm.Get("cruise"),
+		Itinerary:      r.PostForm.Get("itinerary"),
+		StateroomClass: r.PostForm.Get("stateroom_class"),
+		Priority:       r.PostForm.Get("priority"),
+		Condition:      r.PostForm.Get("condition"),
+	}
+	for key := range r.PostForm {
+		if label := strings.TrimPrefix(key, "match."); label != key && r.PostForm.Get(key) != "" {
+			if a.Match == nil {
+				a.Match = map[string]string{}
+			}
+			a.Match[label] = r.PostForm.Get(key)
+		}
+	}
+	if below := r.PostForm.Get("below"); below != "" {
+		var err error
+		if a.Below, err = strconv.ParseFloat(below, 64); err != nil {
+			return a, fmt.Errorf("invalid below %q", below)
+		}
+	}
+	for _, name := range strings.Split(r.PostForm.Get("notify"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			a.Notify = append(a.Notify, name)
+		}
+	}
+	return a, nil
+}
+
+// serveWatch deletes the watch named by the path on DELETE.
+func (hc *Exporter) serveWatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !hc.mayManageWatches(w, r, false) {
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/watches/")
+	known := false
+	for _, watch := range hc.currentWatches() {
+		known = known || watch.Name == name && visibleTo(watch, userOf(r))
+	}
+	if !known {
+		http.Error(w, "no such watch", http.StatusNotFound)
+		return
+	}
+	// the file goes first, a watch it still holds would come back on restart
+	hc.watchesSaveMu.Lock()
+	defer hc.watchesSaveMu.Unlock()
+	if !hc.isManagedWatch(name) {
+		http.Error(w, "watch "+name+" is configured in the config file", http.StatusConflict)
+		return
+	}
+	if err := hc.saveWatches(name); err != nil {
+		hc.logger.Printf("error saving watches: %s", err)
+		http.Error(w, "error saving watches", http.StatusInternalServerError)
+		return
+	}
+	hc.deleteWatch(name)
+	hc.logger.Printf("watch %s deleted through the API", name)
+	user := ""
+	if u := userOf(r); u != nil {
+		user = u.Name
+	}
+	hc.Audit(AuditWatchDeleted, user, map[string]string{"watch": name})
+	w.WriteHeader(http.StatusNoContent)
+}
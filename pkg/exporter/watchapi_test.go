@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/notify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func watchesExporter(t *testing.T, watchesFile string, opts ...Option) (*Exporter, *http.ServeMux) {
+	t.Helper()
+	mux := http.NewServeMux()
+	e, err := NewExporter(context.Background(), append([]Option{
+		WithRegistry(prometheus.NewRegistry()),
+		WithServeMux(mux),
+		WithWatchesFile(watchesFile),
+	}, opts...)...)
+	require.NoError(t, err)
+	return e, mux
+}
+
+func watchRequest(method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.SetBasicAuth("alice", "secret")
+	return r
+}
+
+var alice = config.UserConfig{Name: "alice", Password: "secret", Notify: []string{"alice-hook"}}
+
+func TestWatchesAPIRequiresUsersToManage(t *testing.T) {
+	_, mux := watchesExporter(t, filepath.Join(t.TempDir(), "watches.yml"))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, watchRequest(http.MethodPost, "/api/v1/watches", `{"name":"cheap","cruise":"WN07RCI","below":500}`))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, watchRequest(http.MethodGet, "/api/v1/watches", ""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestWatchesAPIRefusesCrossOriginForm(t *testing.T) {
+	e, mux := watchesExporter(t, filepath.Join(t.TempDir(), "watches.yml"), WithUsers(alice))
+
+	r := watchRequest(http.MethodPost, "http://exporter.local/api/v1/watches", "name=cheap&cruise=WN07RCI&below=500")
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Origin", "http://evil.example")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, e.currentWatches())
+
+	r = watchRequest(http.MethodPost, "http://exporter.local/api/v1/watches", "name=cheap&cruise=WN07RCI&below=500")
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Origin", "http://exporter.local")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Len(t, e.currentWatches(), 1)
+}
+
+func TestWatchesAPIRollsBackUnsavedChanges(t *testing.T) {
+	dir := t.TempDir()
+	e, mux := watchesExporter(t, filepath.Join(dir, "watches.yml"), WithUsers(alice))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, watchRequest(http.MethodPost, "/api/v1/watches", `{"name":"cheap","cruise":"WN07RCI","below":500}`))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	require.NoError(t, os.RemoveAll(dir))
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, watchRequest(http.MethodPost, "/api/v1/watches", `{"name":"cheaper","cruise":"WN07RCI","below":400}`))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, watchRequest(http.MethodDelete, "/api/v1/watches/cheap", ""))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	var names []string
+	for _, w := range e.currentWatches() {
+		names = append(names, w.Name)
+	}
+	assert.Equal(t, []string{"cheap"}, names)
+	assert.Contains(t, e.watchLimits, "cheap")
+	assert.NotContains(t, e.watchLimits, "cheaper")
+}
+
+func TestNotifyDeletedWatch(t *testing.T) {
+	e, mux := watchesExporter(t, filepath.Join(t.TempDir(), "watches.yml"), WithUsers(alice))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, watchRequest(http.MethodPost, "/api/v1/watches", `{"name":"cheap","cruise":"WN07RCI","below":500}`))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	watches, limits := e.currentWatchLimits()
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, watchRequest(http.MethodDelete, "/api/v1/watches/cheap", ""))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	assert.NotNil(t, limits["cheap"], "the limits taken with the watches keep the deleted watch")
+	_, current := e.currentWatchLimits()
+	assert.NotPanics(t, func() { e.notifyWatch(&watches[0], current["cheap"], "", notify.Event{}) })
+}
@@ -0,0 +1,97 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/history"
+)
+
+// savedWatch is a firing watch as kept in the watch state file.
+type savedWatch struct {
+	Watch     string            `json:"watch"`
+	Labels    map[string]string `json:"labels"`
+	Price     float64           `json:"price"`
+	Link      string            `json:"link,omitempty"`
+	StartsAt  time.Time         `json:"starts_at"`
+	Evaluated time.Time         `json:"evaluated"`
+	Notified  time.Time         `json:"notified"`
+}
+
+// loadWatchState restores the firing watches saved at path, dropping those of
+// watches no longer configured. A missing file is not an error.
+func (hc *Exporter) loadWatchState(path string) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved []savedWatch
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return err
+	}
+	configured := map[string]bool{}
+	for _, w := range hc.currentWatches() {
+		configured[w.Name] = true
+	}
+	hc.firingMu.Lock()
+	defer hc.firingMu.Unlock()
+	for _, s := range saved {
+		if !configured[s.Watch] {
+			continue
+		}
+		hc.firing[s.Watch+"\x00"+history.Key(s.Labels)] = &firingWatch{
+			watch:     s.Watch,
+			labels:    s.Labels,
+			price:     s.Price,
+			link:      s.Link,
+			startsAt:  s.StartsAt,
+			evaluated: s.Evaluated,
+			notified:  s.Notified,
+		}
+	}
+	return nil
+}
+
+// saveWatchState writes the firing watches to the watch state file.
+func (hc *Exporter) saveWatchState() error {
+	if hc.watchStateFile == "" {
+		return nil
+	}
+	hc.firingMu.Lock()
+	saved := make([]savedWatch, 0, len(hc.firing))
+	for _, f := range hc.firing {
+		saved = append(saved, savedWatch{
+			Watch:     f.watch,
+			Labels:    f.labels,
+			Price:     f.price,
+			Link:      f.link,
+			StartsAt:  f.startsAt,
+			Evaluated: f.evaluated,
+			Notified:  f.notified,
+		})
+	}
+	hc.firingMu.Unlock()
+
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(hc.watchStateFile), filepath.Base(hc.watchStateFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), hc.watchStateFile)
+}
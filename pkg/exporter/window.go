@@ -0,0 +1,28 @@
+package exporter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// inScrapeWindow reports whether the scrape window allows scraping now,
+// logging when it opens or closes.
+func (hc *Exporter) inScrapeWindow() bool {
+	if hc.scrapeWindow == nil {
+		return true
+	}
+	open := hc.scrapeWindow.Allows(time.Now())
+	var state int32
+	if open {
+		state = 1
+	}
+	if atomic.SwapInt32(&hc.windowWasOpen, state) != state {
+		if open {
+			hc.logger.Println("scrape window opened, resuming scrapes")
+		} else {
+			hc.logger.Println("scrape window closed, serving the last metrics until it opens")
+		}
+	}
+	hc.windowOpen.Set(float64(state))
+	return open
+}
@@ -0,0 +1,143 @@
+package exportertest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Doer is the exporter's HTTP client interface, see exporter.Doer.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Fault kinds counted by Chaos.
+const (
+	FaultTooManyRequests = "too_many_requests"
+	FaultServerError     = "server_error"
+	FaultTruncated       = "truncated"
+	FaultDelayed         = "delayed"
+)
+
+// Chaos injects faults into the requests of the exporter, to test retries,
+// backoff and partial results end to end. Pass it to exporter.WithHTTPClient.
+// The rates are the share of requests, from 0 to 1, failing that way, drawn
+// in that order so they add up.
+type Chaos struct {
+	Doer Doer
+	// TooManyRequests answers 429 with a Retry-After of RetryAfter.
+	TooManyRequests float64
+	RetryAfter      time.Duration
+	// ServerErrors answers 503.
+	ServerErrors float64
+	// Truncated passes the request on but cuts the response body in half,
+	// leaving invalid JSON.
+	Truncated float64
+	// Delay holds every request for that long before passing it on, or
+	// until its context is done.
+	Delay time.Duration
+
+	mu     sync.Mutex
+	rand   *rand.Rand
+	faults map[string]int
+}
+
+// NewChaos returns a Chaos passing the requests on to doer, drawing the
+// faults from seed so a test run can be repeated. A Chaos literal uses seed
+// 1.
+func NewChaos(doer Doer, seed int64) *Chaos {
+	return &Chaos{Doer: doer, rand: rand.New(rand.NewSource(seed)), faults: map[string]int{}}
+}
+
+// Faults returns the number of faults injected so far by kind.
+func (c *Chaos) Faults() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	faults := make(map[string]int, len(c.faults))
+	for k, v := range c.faults {
+		faults[k] = v
+	}
+	return faults
+}
+
+func (c *Chaos) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	if c.rand == nil {
+		c.rand, c.faults = rand.New(rand.NewSource(1)), map[string]int{}
+	}
+	draw := c.rand.Float64()
+	fault := ""
+	switch {
+	case draw < c.TooManyRequests:
+		fault = FaultTooManyRequests
+	case draw < c.TooManyRequests+c.ServerErrors:
+		fault = FaultServerError
+	case draw < c.TooManyRequests+c.ServerErrors+c.Truncated:
+		fault = FaultTruncated
+	}
+	if fault != "" {
+		c.faults[fault]++
+	}
+	if c.Delay > 0 {
+		c.faults[FaultDelayed]++
+	}
+	c.mu.Unlock()
+
+	if c.Delay > 0 {
+		if err := sleep(req.Context(), c.Delay); err != nil {
+			return nil, err
+		}
+	}
+	switch fault {
+	case FaultTooManyRequests:
+		resp := response(req, http.StatusTooManyRequests, "rate limited")
+		resp.Header.Set("Retry-After", strconv.Itoa(int(c.RetryAfter.Seconds())))
+		return resp, nil
+	case FaultServerError:
+		return response(req, http.StatusServiceUnavailable, "unavailable"), nil
+	}
+	resp, err := c.Doer.Do(req)
+	if err != nil || fault != FaultTruncated {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body = body[:len(body)/2]
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+func response(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,266 @@
+package exportertest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+)
+
+// Cruise is a fixture for a single cruise in a CruiseSearch response.
+type Cruise struct {
+	ID            string
+	Ship          string
+	ShipCode      string
+	DeparturePort string
+	Destination   string
+	Nights        int
+	Sailings      []Sailing
+	// LowestPrice is the advertised lowest price, the lowest sailing price
+	// when zero.
+	LowestPrice int
+	// Taxes are the taxes and fees per person, included in the prices when
+	// TaxesIncluded is set.
+	Taxes         float64
+	TaxesIncluded bool
+}
+
+// Sailing is a fixture for one sailing of a cruise. Prices maps a stateroom
+// class id to its price.
+type Sailing struct {
+	ID        string
+	Itinerary string
+	SailDate  string
+	Prices    map[string]int
+}
+
+// Server is an httptest server that answers cruiseSearch_Cruises queries with
+// the configured fixtures, honouring the pagination variables of the request.
+// The itinerary and id filters narrow the cruises to the given itinerary
+// codes and cruise ids. pricingCalendar queries get the lowest price of every stateroom class of
+// the sailings of the itinerary in the month.
+// Persisted query hashes are only accepted once the full query was sent.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	cruises   []Cruise
+	requests  int
+	persisted map[string]bool
+	maxPage   int
+}
+
+// NewServer starts a mock CruiseSearch server. Callers should Close it when done.
+func NewServer(cruises ...Cruise) *Server {
+	s := &Server{cruises: cruises, persisted: map[string]bool{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// SetCruises replaces the fixtures served by subsequent requests.
+func (s *Server) SetCruises(cruises ...Cruise) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cruises = cruises
+}
+
+// SetMaxPageSize truncates pages to n cruises like an API with a page size
+// limit would, 0 removes the limit.
+func (s *Server) SetMaxPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxPage = n
+}
+
+// Requests returns the number of requests served so far.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req royalapi.Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.OperationName == royalapi.CalendarOperationName {
+		var creq royalapi.CalendarRequest
+		if err := json.Unmarshal(body, &creq); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.requests++
+		cruises := s.cruises
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CalendarResponse(creq.Variables, cruises...))
+		return
+	}
+
+	s.mu.Lock()
+	s.requests++
+	cruises := s.cruises
+	count := req.Variables.Pagination.Count
+	if s.maxPage > 0 && count > s.maxPage {
+		count = s.maxPage
+	}
+	known := true
+	if req.Extensions != nil {
+		// automatic persisted queries: learn the hash when the query is sent along
+		hash := req.Extensions.PersistedQuery.Sha256Hash
+		if req.Query != "" {
+			s.persisted[hash] = true
+		}
+		known = s.persisted[hash]
+	}
+	s.mu.Unlock()
+
+	if !known {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errors": []interface{}{map[string]interface{}{
+				"message":    "PersistedQueryNotFound",
+				"extensions": map[string]interface{}{"code": "PERSISTED_QUERY_NOT_FOUND"},
+			}},
+		})
+		return
+	}
+
+	cruises = filter(cruises, req.Variables.Filters)
+	page := paginate(cruises, req.Variables.Pagination.Skip, count)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Response(len(cruises), page...))
+}
+
+// CalendarResponse renders a pricingCalendar response body for the sailings
+// of the cruises matching the itinerary and month of v.
+func CalendarResponse(v royalapi.CalendarVariables, cruises ...Cruise) *royalapi.CalendarResponse {
+	resp := &royalapi.CalendarResponse{}
+	cal := &resp.Data.PricingCalendar
+	cal.ItineraryCode = v.ItineraryCode
+	for _, c := range cruises {
+		for _, s := range c.Sailings {
+			if s.Itinerary != v.ItineraryCode || !strings.HasPrefix(s.SailDate, v.Month) {
+				continue
+			}
+			d := royalapi.CalendarSailDate{SailDate: s.SailDate}
+			for class, price := range s.Prices {
+				p := royalapi.CalendarPrice{SuperCategory: class}
+				p.LowestPrice.Value = float64(price)
+				d.Prices = append(d.Prices, p)
+			}
+			cal.SailDates = append(cal.SailDates, d)
+		}
+	}
+	return resp
+}
+
+func filter(cruises []Cruise, filters string) []Cruise {
+	if filters == "" {
+		return cruises
+	}
+	for _, clause := range strings.Split(filters, "|") {
+		i := strings.Index(clause, ":")
+		if i < 0 {
+			continue
+		}
+		values := map[string]bool{}
+		for _, v := range strings.Split(clause[i+1:], ",") {
+			values[v] = true
+		}
+		var kept []Cruise
+		for _, c := range cruises {
+			switch clause[:i] {
+			case royalapi.ItineraryFilter:
+				for _, s := range c.Sailings {
+					if values[s.Itinerary] {
+						kept = append(kept, c)
+						break
+					}
+				}
+			case royalapi.ProductFilter:
+				if values[c.ID] {
+					kept = append(kept, c)
+				}
+			default:
+				kept = append(kept, c)
+			}
+		}
+		cruises = kept
+	}
+	return cruises
+}
+
+func paginate(cruises []Cruise, skip, count int) []Cruise {
+	if skip < 0 || skip >= len(cruises) {
+		return nil
+	}
+	end := len(cruises)
+	if count > 0 && skip+count < end {
+		end = skip + count
+	}
+	return cruises[skip:end]
+}
+
+// Response renders a CruiseSearch response body for the given page of
+// cruises, reporting total as the size of the whole result set.
+func Response(total int, cruises ...Cruise) *royalapi.Response {
+	resp := &royalapi.Response{}
+	results := &resp.Data.CruiseSearch.Results
+	results.Total = total
+	results.Cruises = make([]royalapi.Cruise, 0, len(cruises))
+	for _, c := range cruises {
+		results.Cruises = append(results.Cruises, c.render())
+	}
+	return resp
+}
+
+func (c Cruise) render() royalapi.Cruise {
+	rc := royalapi.Cruise{ID: c.ID}
+	it := &rc.MasterSailing.Itinerary
+	it.Ship.Name = c.Ship
+	it.Ship.Code = c.ShipCode
+	it.DeparturePort.Name = c.DeparturePort
+	it.Destination.Code = c.Destination
+	it.TotalNights = c.Nights
+	it.SailingNights = c.Nights
+
+	for _, s := range c.Sailings {
+		rs := royalapi.Sailing{
+			ID:        s.ID,
+			Itinerary: royalapi.ItineraryRef{Code: s.Itinerary},
+			SailDate:  s.SailDate,
+			StartDate: s.SailDate,
+		}
+		for class, price := range s.Prices {
+			rs.StateroomClassPricing = append(rs.StateroomClassPricing, royalapi.StateroomClassPrice{
+				Price:          royalapi.Price{Value: price},
+				StateroomClass: royalapi.StateroomClassRef{ID: class},
+			})
+		}
+		rc.Sailings = append(rc.Sailings, rs)
+	}
+	rc.LowestPriceSailing.TaxesAndFees.Value = c.Taxes
+	rc.LowestPriceSailing.TaxesAndFeesIncluded = c.TaxesIncluded
+	rc.LowestPriceSailing.LowestStateroomClassPrice.Price.Value = c.LowestPrice
+	if c.LowestPrice == 0 {
+		rc.LowestPriceSailing.LowestStateroomClassPrice.Price.Value = rc.MinSailingPrice()
+	}
+	return rc
+}
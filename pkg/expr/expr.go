@@ -0,0 +1,332 @@
+// Package expr evaluates small CEL like expressions over named values, such
+// as price < 0.9 * min_observed && nights >= 7.
+//
+// Values are numbers, strings, booleans and lists. Expressions support the
+// operators || && ! == != < <= > >= in + - * / %, parentheses, list literals
+// like ["a", "b"] and the functions contains, starts_with, ends_with, lower,
+// abs, min and max. Dividing by zero is an error rather than an infinity, so
+// a watch doesn't fire on it nor a derived metric export it.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Expr is a compiled expression.
+type Expr struct {
+	root node
+	src  string
+}
+
+// Compile parses src.
+func Compile(src string) (*Expr, error) {
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+	root, err := p.parseOr()
+	if err == nil && p.tok.kind != tokEOF {
+		err = p.errorf("unexpected %s", p.tok)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	return &Expr{root: root, src: src}, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression with vars, which hold float64, string, bool
+// or []interface{} values. Referring to a missing variable is an error.
+func (e *Expr) Eval(vars map[string]interface{}) (interface{}, error) {
+	return e.root.eval(vars)
+}
+
+// Bool evaluates an expression that must yield a boolean.
+func (e *Expr) Bool(vars map[string]interface{}) (bool, error) {
+	v, err := e.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression yields %s, not a boolean", typeName(v))
+	}
+	return b, nil
+}
+
+// Number evaluates an expression that must yield a number.
+func (e *Expr) Number(vars map[string]interface{}) (float64, error) {
+	v, err := e.Eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("expression yields %s, not a number", typeName(v))
+	}
+	return f, nil
+}
+
+type node interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type literal struct{ v interface{} }
+
+func (n literal) eval(map[string]interface{}) (interface{}, error) { return n.v, nil }
+
+type ident struct{ name string }
+
+func (n ident) eval(vars map[string]interface{}) (interface{}, error) {
+	v, ok := vars[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %q", n.name)
+	}
+	return v, nil
+}
+
+type list struct{ items []node }
+
+func (n list) eval(vars map[string]interface{}) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+type unary struct {
+	op string
+	x  node
+}
+
+func (n unary) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("! needs a boolean, got %s", typeName(v))
+		}
+		return !b, nil
+	default:
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("- needs a number, got %s", typeName(v))
+		}
+		return -f, nil
+	}
+}
+
+type binary struct {
+	op   string
+	x, y node
+}
+
+func (n binary) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" || n.op == "||" {
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s needs booleans, got %s", n.op, typeName(x))
+		}
+		if b == (n.op == "||") {
+			return b, nil
+		}
+		y, err := n.y.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok = y.(bool); !ok {
+			return nil, fmt.Errorf("%s needs booleans, got %s", n.op, typeName(y))
+		}
+		return b, nil
+	}
+	y, err := n.y.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(x, y), nil
+	case "!=":
+		return !equal(x, y), nil
+	case "in":
+		items, ok := y.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("in needs a list, got %s", typeName(y))
+		}
+		for _, item := range items {
+			if equal(x, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if xs, ok := x.(string); ok {
+		ys, ok := y.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s on string and %s", n.op, typeName(y))
+		}
+		switch n.op {
+		case "+":
+			return xs + ys, nil
+		case "<":
+			return xs < ys, nil
+		case "<=":
+			return xs <= ys, nil
+		case ">":
+			return xs > ys, nil
+		case ">=":
+			return xs >= ys, nil
+		}
+		return nil, fmt.Errorf("%s is not defined on strings", n.op)
+	}
+	xf, ok1 := x.(float64)
+	yf, ok2 := y.(float64)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%s needs numbers, got %s and %s", n.op, typeName(x), typeName(y))
+	}
+	switch n.op {
+	case "+":
+		return xf + yf, nil
+	case "-":
+		return xf - yf, nil
+	case "*":
+		return xf * yf, nil
+	case "/", "%":
+		if yf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if n.op == "%" {
+			return math.Mod(xf, yf), nil
+		}
+		return xf / yf, nil
+	case "<":
+		return xf < yf, nil
+	case "<=":
+		return xf <= yf, nil
+	case ">":
+		return xf > yf, nil
+	default:
+		return xf >= yf, nil
+	}
+}
+
+type call struct {
+	fn   string
+	args []node
+}
+
+// functions maps the function names to their number of arguments, -1 for
+// one or more.
+var functions = map[string]int{
+	"contains":    2,
+	"starts_with": 2,
+	"ends_with":   2,
+	"lower":       1,
+	"abs":         1,
+	"min":         -1,
+	"max":         -1,
+}
+
+func (n call) eval(vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	switch n.fn {
+	case "contains", "starts_with", "ends_with", "lower":
+		s := make([]string, len(args))
+		for i, arg := range args {
+			var ok bool
+			if s[i], ok = arg.(string); !ok {
+				return nil, fmt.Errorf("%s needs strings, got %s", n.fn, typeName(arg))
+			}
+		}
+		switch n.fn {
+		case "contains":
+			return strings.Contains(s[0], s[1]), nil
+		case "starts_with":
+			return strings.HasPrefix(s[0], s[1]), nil
+		case "ends_with":
+			return strings.HasSuffix(s[0], s[1]), nil
+		default:
+			return strings.ToLower(s[0]), nil
+		}
+	default:
+		f := make([]float64, len(args))
+		for i, arg := range args {
+			var ok bool
+			if f[i], ok = arg.(float64); !ok {
+				return nil, fmt.Errorf("%s needs numbers, got %s", n.fn, typeName(arg))
+			}
+		}
+		switch n.fn {
+		case "abs":
+			return math.Abs(f[0]), nil
+		case "min":
+			m := f[0]
+			for _, v := range f[1:] {
+				m = math.Min(m, v)
+			}
+			return m, nil
+		default:
+			m := f[0]
+			for _, v := range f[1:] {
+				m = math.Max(m, v)
+			}
+			return m, nil
+		}
+	}
+}
+
+func equal(x, y interface{}) bool {
+	switch x := x.(type) {
+	case []interface{}:
+		ys, ok := y.([]interface{})
+		if !ok || len(x) != len(ys) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], ys[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return x == y
+	}
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "list"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
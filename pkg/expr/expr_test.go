@@ -0,0 +1,146 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var vars = map[string]interface{}{
+	"price":          899.0,
+	"min_observed":   1000.0,
+	"nights":         7.0,
+	"ship":           "Wonder of the Seas",
+	"stateroomclass": "I",
+	"zero":           0.0,
+	"classes":        []interface{}{"I", "O"},
+}
+
+func TestEval(t *testing.T) {
+	for src, want := range map[string]interface{}{
+		// precedence
+		"1 + 2 * 3":                7.0,
+		"(1 + 2) * 3":              9.0,
+		"-2 * 3":                   -6.0,
+		"10 - 4 % 3":               9.0,
+		"1 + 2 < 4":                true,
+		"true || false && false":   true,
+		"(true || false) && false": false,
+		"!false && false":          false,
+		"price < 0.9 * min_observed && nights >= 7": true,
+		// associativity
+		"8 - 4 - 2":       2.0,
+		"16 / 4 / 2":      2.0,
+		"2 * 3 % 4":       2.0,
+		"- -3":            3.0,
+		"!!true":          true,
+		"'a' + 'b' + 'c'": "abc",
+		// operators and functions
+		"stateroomclass in classes":       true,
+		"'B' in ['I', 'O']":               false,
+		"[1, 2] == [1, 2]":                true,
+		"'a' < 'b'":                       true,
+		"contains(lower(ship), 'wonder')": true,
+		"starts_with(ship, 'Wonder')":     true,
+		"ends_with(ship, 'Seas')":         true,
+		"abs(-3)":                         3.0,
+		"min(price, min_observed, 950)":   899.0,
+		"max(price, 950)":                 950.0,
+		"1 != 'a'":                        true,
+		"5 % 3":                           2.0,
+	} {
+		t.Run(src, func(t *testing.T) {
+			e, err := Compile(src)
+			require.NoError(t, err)
+			got, err := e.Eval(vars)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	for _, src := range []string{
+		"false && missing",
+		"true || missing",
+		"false && 1 / zero > 0",
+		"true || 'a' + 1",
+	} {
+		e, err := Compile(src)
+		require.NoError(t, err)
+		_, err = e.Eval(vars)
+		assert.NoError(t, err, src)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		"missing > 1":         `unknown variable "missing"`,
+		"price / zero":        "division by zero",
+		"price % zero":        "division by zero",
+		"price / 0 > 100":     "division by zero",
+		"ship + 1":            "+ on string and number",
+		"ship * ship":         "* is not defined on strings",
+		"price + true":        "+ needs numbers, got number and boolean",
+		"!price":              "! needs a boolean, got number",
+		"-ship":               "- needs a number, got string",
+		"price && true":       "&& needs booleans, got number",
+		"true && price":       "&& needs booleans, got number",
+		"1 in ship":           "in needs a list, got string",
+		"lower(price)":        "lower needs strings, got number",
+		"abs(ship)":           "abs needs numbers, got string",
+		"true || missing > 1": "",
+	} {
+		t.Run(src, func(t *testing.T) {
+			e, err := Compile(src)
+			require.NoError(t, err)
+			_, err = e.Eval(vars)
+			if want == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, want)
+		})
+	}
+}
+
+func TestResultTypes(t *testing.T) {
+	e, err := Compile("price * 2")
+	require.NoError(t, err)
+	_, err = e.Bool(vars)
+	assert.EqualError(t, err, "expression yields number, not a boolean")
+	f, err := e.Number(vars)
+	require.NoError(t, err)
+	assert.Equal(t, 1798.0, f)
+
+	e, err = Compile("price > 2")
+	require.NoError(t, err)
+	_, err = e.Number(vars)
+	assert.EqualError(t, err, "expression yields boolean, not a number")
+}
+
+func TestCompileErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		"":       `invalid expression "": unexpected end of expression at 0`,
+		"1 +":    `invalid expression "1 +": unexpected end of expression at 3`,
+		"(1 + 2": `invalid expression "(1 + 2": expected ")", got end of expression at 6`,
+		"1 2":    `invalid expression "1 2": unexpected "2" at 2`,
+		// comparisons don't chain
+		"1 < 2 < 3": `invalid expression "1 < 2 < 3": unexpected "<" at 6`,
+		"'open":     `invalid expression "'open": unterminated string at 0`,
+		"price # 2": `invalid expression "price # 2": unexpected '#' at 6`,
+		"1..2":      `invalid expression "1..2": invalid number "1..2" at 0`,
+		"nope(1)":   `invalid expression "nope(1)": unknown function "nope" at 0`,
+		"abs(1, 2)": `invalid expression "abs(1, 2)": wrong number of arguments to abs at 0`,
+		"min()":     `invalid expression "min()": wrong number of arguments to min at 0`,
+		"[1, 2":     `invalid expression "[1, 2": expected ",", got end of expression at 5`,
+		"[1 2]":     `invalid expression "[1 2]": expected ",", got "2" at 3`,
+		"in":        `invalid expression "in": unexpected "in" at 0`,
+	} {
+		t.Run(src, func(t *testing.T) {
+			_, err := Compile(src)
+			assert.EqualError(t, err, want)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sample is one recorded value of a series.
+type Sample struct {
+	Time  time.Time `json:"t"`
+	Value float64   `json:"v"`
+}
+
+// Series is the recorded history of one label set, oldest sample first.
+type Series struct {
+	Labels  map[string]string `json:"labels"`
+	Samples []Sample          `json:"samples"`
+}
+
+// Store keeps a downsampled history of every series it is given, at most one
+// sample per resolution and nothing older than the retention.
+type Store struct {
+	mu         sync.RWMutex
+	retention  time.Duration
+	resolution time.Duration
+	series     map[string]*Series
+}
+
+func NewStore(retention, resolution time.Duration) *Store {
+	return &Store{retention: retention, resolution: resolution, series: map[string]*Series{}}
+}
+
+// Key identifies a label set.
+func Key(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(labels[name])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// Add records a value. Within one resolution step the latest value replaces
+// the previous one.
+func (s *Store) Add(labels map[string]string, t time.Time, v float64) {
+	key := Key(labels)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	series, ok := s.series[key]
+	if !ok {
+		copied := make(map[string]string, len(labels))
+		for k, v := range labels {
+			copied[k] = v
+		}
+		series = &Series{Labels: copied}
+		s.series[key] = series
+	}
+	if n := len(series.Samples); n > 0 && t.Sub(series.Samples[n-1].Time) < s.resolution {
+		series.Samples[n-1] = Sample{Time: t, Value: v}
+	} else {
+		series.Samples = append(series.Samples, Sample{Time: t, Value: v})
+	}
+	series.Samples = trim(series.Samples, t.Add(-s.retention))
+}
+
+func trim(samples []Sample, cutoff time.Time) []Sample {
+	i := sort.Search(len(samples), func(i int) bool { return !samples[i].Time.Before(cutoff) })
+	if i == 0 {
+		return samples
+	}
+	return append(samples[:0:0], samples[i:]...)
+}
+
+// Samples returns a copy of the samples of the label set recorded since from.
+func (s *Store) Samples(labels map[string]string, from time.Time) []Sample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	series, ok := s.series[Key(labels)]
+	if !ok {
+		return nil
+	}
+	var samples []Sample
+	for _, sample := range series.Samples {
+		if !sample.Time.Before(from) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// Range calls fn with a copy of every series.
+func (s *Store) Range(fn func(Series)) {
+	s.mu.RLock()
+	all := make([]Series, 0, len(s.series))
+	for _, series := range s.series {
+		all = append(all, Series{Labels: series.Labels, Samples: append([]Sample(nil), series.Samples...)})
+	}
+	s.mu.RUnlock()
+	for _, series := range all {
+		fn(series)
+	}
+}
+
+// Expire drops the samples older than the retention and the series left
+// without any.
+func (s *Store) Expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, series := range s.series {
+		series.Samples = trim(series.Samples, now.Add(-s.retention))
+		if len(series.Samples) == 0 {
+			delete(s.series, key)
+		}
+	}
+}
+
+// Save writes the store to path, replacing the file atomically.
+func (s *Store) Save(path string) error {
+	var all []Series
+	s.Range(func(series Series) { all = append(all, series) })
+	b, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load adds the series saved at path. A missing file is not an error.
+func (s *Store) Load(path string) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var all []Series
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range all {
+		s.series[Key(all[i].Labels)] = &all[i]
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Package jsonpath selects values from decoded JSON with dotted paths such as
+// data.calendar.months[*].days[0].price.
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is a key, optionally followed by an index, -1 meaning every element.
+type step struct {
+	key     string
+	indexed bool
+	index   int
+}
+
+// Path is a parsed path. The zero Path selects the value itself.
+type Path struct {
+	steps []step
+	src   string
+}
+
+// Parse parses a path of dot separated keys, each optionally followed by
+// [N] or [*]. An empty path selects the whole value.
+func Parse(s string) (Path, error) {
+	p := Path{src: s}
+	if s == "" {
+		return p, nil
+	}
+	for _, part := range strings.Split(s, ".") {
+		st := step{key: part}
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return Path{}, fmt.Errorf("invalid path %q: unclosed [ in %q", s, part)
+			}
+			st.key, st.indexed = part[:i], true
+			switch idx := part[i+1 : len(part)-1]; idx {
+			case "*":
+				st.index = -1
+			default:
+				n, err := strconv.Atoi(idx)
+				if err != nil || n < 0 {
+					return Path{}, fmt.Errorf("invalid path %q: index must be a number or *, got %q", s, idx)
+				}
+				st.index = n
+			}
+		}
+		if st.key == "" && !st.indexed {
+			return Path{}, fmt.Errorf("invalid path %q: empty key", s)
+		}
+		p.steps = append(p.steps, st)
+	}
+	return p, nil
+}
+
+func (p Path) String() string {
+	return p.src
+}
+
+// Select returns every value the path leads to in v, as decoded by
+// encoding/json. Missing keys and out of range indexes select nothing.
+func (p Path) Select(v interface{}) []interface{} {
+	values := []interface{}{v}
+	for _, st := range p.steps {
+		var next []interface{}
+		for _, v := range values {
+			if st.key != "" {
+				m, ok := v.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if v, ok = m[st.key]; !ok {
+					continue
+				}
+			}
+			if !st.indexed {
+				next = append(next, v)
+				continue
+			}
+			list, ok := v.([]interface{})
+			if !ok {
+				continue
+			}
+			if st.index < 0 {
+				next = append(next, list...)
+			} else if st.index < len(list) {
+				next = append(next, list[st.index])
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// First returns the first value the path leads to in v.
+func (p Path) First(v interface{}) (interface{}, bool) {
+	values := p.Select(v)
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
@@ -0,0 +1,77 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const calendar = `{"data":{"calendar":{"months":[
+	{"month":"2036-01","days":[{"price":899},{"price":949}]},
+	{"month":"2036-02","days":[{"price":799}]},
+	{"month":"2036-03","days":[]}
+]}}}`
+
+func TestSelect(t *testing.T) {
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(calendar), &v))
+
+	for path, want := range map[string][]interface{}{
+		"data.calendar.months[*].month":         {"2036-01", "2036-02", "2036-03"},
+		"data.calendar.months[*].days[0].price": {899.0, 799.0},
+		"data.calendar.months[0].days[*].price": {899.0, 949.0},
+		"data.calendar.months[1].month":         {"2036-02"},
+		"data.calendar.months[3].month":         nil,
+		"data.calendar.missing":                 nil,
+		"data.calendar.months.month":            nil,
+		"data.calendar.months[*].month[0]":      nil,
+	} {
+		t.Run(path, func(t *testing.T) {
+			p, err := Parse(path)
+			require.NoError(t, err)
+			assert.Equal(t, want, p.Select(v))
+		})
+	}
+}
+
+func TestSelectRoot(t *testing.T) {
+	p, err := Parse("")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{1.0}, p.Select(1.0))
+
+	p, err = Parse("[1]")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"b"}, p.Select([]interface{}{"a", "b"}))
+}
+
+func TestFirst(t *testing.T) {
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(calendar), &v))
+	p, err := Parse("data.calendar.months[*].days[*].price")
+	require.NoError(t, err)
+	first, ok := p.First(v)
+	assert.True(t, ok)
+	assert.Equal(t, 899.0, first)
+
+	p, err = Parse("data.nothing")
+	require.NoError(t, err)
+	_, ok = p.First(v)
+	assert.False(t, ok)
+}
+
+func TestParseErrors(t *testing.T) {
+	for path, want := range map[string]string{
+		"data..price": `invalid path "data..price": empty key`,
+		"days[0":      `invalid path "days[0": unclosed [ in "days[0"`,
+		"days[-1]":    `invalid path "days[-1]": index must be a number or *, got "-1"`,
+		"days[first]": `invalid path "days[first]": index must be a number or *, got "first"`,
+		"days[0].":    `invalid path "days[0].": empty key`,
+	} {
+		t.Run(path, func(t *testing.T) {
+			_, err := Parse(path)
+			assert.EqualError(t, err, want)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// Elector decides which of several replicas scrapes the upstream API.
+type Elector interface {
+	// Run campaigns for leadership until ctx is cancelled.
+	Run(ctx context.Context)
+	IsLeader() bool
+}
+
+// state tracks whether an elector currently holds its lease.
+type state struct {
+	leader   int32
+	identity string
+	lease    string
+	logger   *log.Logger
+}
+
+func (s *state) IsLeader() bool {
+	return atomic.LoadInt32(&s.leader) == 1
+}
+
+func (s *state) setLeader(leader bool) {
+	var v int32
+	if leader {
+		v = 1
+	}
+	if atomic.SwapInt32(&s.leader, v) != v {
+		if leader {
+			s.logger.Printf("leader election: %s acquired the lease %s", s.identity, s.lease)
+		} else {
+			s.logger.Printf("leader election: %s lost the lease %s", s.identity, s.lease)
+		}
+	}
+}
+
+type lease struct {
+	Holder    string    `json:"holder"`
+	RenewedAt time.Time `json:"renewed_at"`
+}
+
+// FileLease elects a leader through a lease file on storage shared by the
+// replicas. The holder renews the lease every third of its duration, the
+// others take it over once it has expired. Replicas read and replace the
+// lease while holding a lock file created with O_EXCL, which the storage
+// must create atomically, as local file systems and NFSv3 or later do.
+type FileLease struct {
+	state
+	path     string
+	duration time.Duration
+}
+
+func NewFileLease(path, identity string, duration time.Duration, logger *log.Logger) *FileLease {
+	return &FileLease{state: state{identity: identity, lease: path, logger: logger}, path: path, duration: duration}
+}
+
+// Acquire makes a single attempt to take or renew the lease.
+func (l *FileLease) Acquire() bool {
+	l.setLeader(l.tryAcquire(time.Now()))
+	return l.IsLeader()
+}
+
+func (l *FileLease) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.duration / 3)
+	defer ticker.Stop()
+	for {
+		l.Acquire()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if l.IsLeader() {
+				// let another replica take over right away
+				l.release()
+				l.setLeader(false)
+			}
+			return
+		}
+	}
+}
+
+func (l *FileLease) tryAcquire(now time.Time) bool {
+	unlock, ok := l.lock(now)
+	if !ok {
+		return false
+	}
+	defer unlock()
+	if current, err := l.read(); err == nil && current.Holder != l.identity && now.Before(current.RenewedAt.Add(l.duration)) {
+		return false
+	} else if err != nil && !os.IsNotExist(err) {
+		l.logger.Printf("leader election: reading %s: %s", l.path, err)
+		return false
+	}
+	if err := l.write(lease{Holder: l.identity, RenewedAt: now}); err != nil {
+		l.logger.Printf("leader election: writing %s: %s", l.path, err)
+		return false
+	}
+	return true
+}
+
+// release removes the lease if this replica still holds it.
+func (l *FileLease) release() {
+	unlock, ok := l.lock(time.Now())
+	if !ok {
+		return
+	}
+	defer unlock()
+	if current, err := l.read(); err == nil && current.Holder == l.identity {
+		os.Remove(l.path)
+	}
+}
+
+// lock takes the lock file guarding the lease, failing while another replica
+// holds it. A lock left for longer than the lease by a replica that died
+// holding it is removed, and taken at the next attempt.
+func (l *FileLease) lock(now time.Time) (func(), bool) {
+	name := l.path + ".lock"
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err == nil {
+		f.Close()
+		return func() { os.Remove(name) }, true
+	}
+	if !os.IsExist(err) {
+		l.logger.Printf("leader election: locking %s: %s", l.path, err)
+	} else if fi, err := os.Stat(name); err == nil && now.Sub(fi.ModTime()) > l.duration {
+		l.logger.Printf("leader election: removing the abandoned lock of %s", l.path)
+		os.Remove(name)
+	}
+	return nil, false
+}
+
+func (l *FileLease) read() (lease, error) {
+	var current lease
+	b, err := os.ReadFile(l.path)
+	if err != nil {
+		return current, err
+	}
+	err = json.Unmarshal(b, &current)
+	return current, err
+}
+
+func (l *FileLease) write(current lease) error {
+	b, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
@@ -0,0 +1,85 @@
+package leader
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discard = log.New(io.Discard, "", 0)
+
+func TestFileLeaseTakeover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	a := NewFileLease(path, "a", time.Minute, discard)
+	b := NewFileLease(path, "b", time.Minute, discard)
+	now := time.Now()
+
+	assert.True(t, a.tryAcquire(now))
+	assert.False(t, b.tryAcquire(now.Add(30*time.Second)), "the lease is held")
+	assert.True(t, a.tryAcquire(now.Add(30*time.Second)), "the holder renews")
+	assert.False(t, b.tryAcquire(now.Add(80*time.Second)), "the renewal counts from when it happened")
+	assert.True(t, b.tryAcquire(now.Add(91*time.Second)), "an expired lease is taken over")
+	assert.False(t, a.tryAcquire(now.Add(92*time.Second)))
+}
+
+func TestFileLeaseWaitsForTheLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	a := NewFileLease(path, "a", time.Minute, discard)
+	now := time.Now()
+
+	unlock, ok := NewFileLease(path, "b", time.Minute, discard).lock(now)
+	require.True(t, ok)
+	assert.False(t, a.tryAcquire(now), "another replica is replacing the lease")
+	unlock()
+	assert.True(t, a.tryAcquire(now))
+	_, err := os.Stat(path + ".lock")
+	assert.True(t, os.IsNotExist(err), "the lock is removed")
+}
+
+func TestFileLeaseBreaksAbandonedLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	require.NoError(t, os.WriteFile(path+".lock", nil, 0644))
+	a := NewFileLease(path, "a", time.Minute, discard)
+	now := time.Now()
+
+	assert.False(t, a.tryAcquire(now), "a recent lock is respected")
+	assert.False(t, a.tryAcquire(now.Add(2*time.Minute)), "an abandoned lock is removed")
+	assert.True(t, a.tryAcquire(now.Add(2*time.Minute)), "and taken at the next attempt")
+}
+
+func TestFileLeaseReleasesOnlyItsOwnLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	a := NewFileLease(path, "a", time.Minute, discard)
+	b := NewFileLease(path, "b", time.Minute, discard)
+	now := time.Now()
+
+	require.True(t, a.tryAcquire(now))
+	b.release()
+	assert.FileExists(t, path)
+	a.release()
+	assert.NoFileExists(t, path)
+}
+
+func TestFileLeaseRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	a := NewFileLease(path, "a", time.Minute, discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, a.IsLeader, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	assert.False(t, a.IsLeader())
+	assert.NoFileExists(t, path, "stopping hands the lease over right away")
+}
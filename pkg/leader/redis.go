@@ -0,0 +1,69 @@
+package leader
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/redis"
+)
+
+// only the holder may renew or release the lease
+const (
+	renewScript   = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+// RedisLease elects a leader through a key holding the identity of the
+// leader, expiring unless it is renewed.
+type RedisLease struct {
+	state
+	client   *redis.Client
+	key      string
+	duration time.Duration
+}
+
+func NewRedisLease(client *redis.Client, key, identity string, duration time.Duration, logger *log.Logger) *RedisLease {
+	return &RedisLease{state: state{identity: identity, lease: "redis:" + key, logger: logger}, client: client, key: key, duration: duration}
+}
+
+// Acquire makes a single attempt to take or renew the lease.
+func (l *RedisLease) Acquire(ctx context.Context) bool {
+	l.setLeader(l.tryAcquire(ctx))
+	return l.IsLeader()
+}
+
+func (l *RedisLease) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.duration / 3)
+	defer ticker.Stop()
+	for {
+		l.Acquire(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if l.IsLeader() {
+				release, cancel := context.WithTimeout(context.Background(), time.Second)
+				l.client.Do(release, "EVAL", releaseScript, "1", l.key, l.identity)
+				cancel()
+				l.setLeader(false)
+			}
+			return
+		}
+	}
+}
+
+func (l *RedisLease) tryAcquire(ctx context.Context) bool {
+	ttl := strconv.FormatInt(l.duration.Milliseconds(), 10)
+	ok, err := l.client.SetNX(ctx, l.key, l.identity, l.duration)
+	if err == nil && !ok {
+		var reply interface{}
+		reply, err = l.client.Do(ctx, "EVAL", renewScript, "1", l.key, l.identity, ttl)
+		ok = reply == int64(1)
+	}
+	if err != nil {
+		l.logger.Printf("leader election: %s", err)
+		return false
+	}
+	return ok
+}
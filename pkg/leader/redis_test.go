@@ -0,0 +1,144 @@
+package leader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis keeps the keys the lease commands and scripts set, expiring only
+// when the test says so.
+type fakeRedis struct {
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{keys: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			line, _ = r.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			b := make([]byte, size+2)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return
+			}
+			args[i] = string(b[:size])
+		}
+		io.WriteString(conn, f.do(args))
+	}
+}
+
+func (f *fakeRedis) do(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case args[0] == "SET" && len(args) == 6 && args[3] == "NX":
+		if _, ok := f.keys[args[1]]; ok {
+			return "$-1\r\n"
+		}
+		f.keys[args[1]] = args[2]
+		return "+OK\r\n"
+	case args[0] == "EVAL" && (args[1] == renewScript || args[1] == releaseScript):
+		if f.keys[args[3]] != args[4] {
+			return ":0\r\n"
+		}
+		if args[1] == releaseScript {
+			delete(f.keys, args[3])
+		}
+		return ":1\r\n"
+	}
+	return fmt.Sprintf("-ERR unexpected %q\r\n", args)
+}
+
+func (f *fakeRedis) expire(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.keys, key)
+}
+
+func (f *fakeRedis) get(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.keys[key]
+	return v, ok
+}
+
+func TestRedisLeaseTakeover(t *testing.T) {
+	f, addr := startFakeRedis(t)
+	ctx := context.Background()
+	a := NewRedisLease(redis.NewClient(addr, "", 0), "exporter:leader", "a", time.Minute, discard)
+	b := NewRedisLease(redis.NewClient(addr, "", 0), "exporter:leader", "b", time.Minute, discard)
+
+	assert.True(t, a.Acquire(ctx))
+	assert.False(t, b.Acquire(ctx), "the lease is held")
+	assert.True(t, a.Acquire(ctx), "the holder renews")
+
+	f.expire("exporter:leader")
+	assert.True(t, b.Acquire(ctx), "an expired lease is taken over")
+	assert.False(t, a.Acquire(ctx))
+	assert.False(t, a.IsLeader())
+}
+
+func TestRedisLeaseReleasesOnStop(t *testing.T) {
+	f, addr := startFakeRedis(t)
+	a := NewRedisLease(redis.NewClient(addr, "", 0), "exporter:leader", "a", time.Minute, discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, a.IsLeader, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	assert.False(t, a.IsLeader())
+	_, held := f.get("exporter:leader")
+	assert.False(t, held, "stopping hands the lease over right away")
+}
+
+func TestRedisLeaseUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	a := NewRedisLease(redis.NewClient(addr, "", 0), "exporter:leader", "a", time.Minute, discard)
+	assert.False(t, a.Acquire(context.Background()))
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Alert is an alert in the format of the Alertmanager v2 API.
+type Alert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// Alertmanager posts alerts to an Alertmanager. Firing alerts have to be
+// sent again before their EndsAt or Alertmanager resolves them.
+type Alertmanager struct {
+	url    string
+	client *http.Client
+}
+
+func NewAlertmanager(url string, timeout time.Duration) *Alertmanager {
+	return &Alertmanager{url: strings.TrimSuffix(url, "/") + "/api/v2/alerts", client: &http.Client{Timeout: timeout}}
+}
+
+func (a *Alertmanager) Post(ctx context.Context, alerts []Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("alertmanager returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertmanagerPost(t *testing.T) {
+	var got []Alert
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/prefix/api/v2/alerts", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	am := NewAlertmanager(srv.URL+"/prefix/", time.Second)
+	require.NoError(t, am.Post(context.Background(), nil))
+	assert.Zero(t, calls, "no alerts aren't posted")
+
+	startsAt := time.Date(2036, 1, 12, 9, 0, 0, 0, time.UTC)
+	alerts := []Alert{{
+		Labels:      map[string]string{"alertname": "CheapCruise", "ship": "Wonder of the Seas"},
+		Annotations: map[string]string{"summary": "899"},
+		StartsAt:    startsAt,
+		EndsAt:      startsAt.Add(time.Hour),
+	}}
+	require.NoError(t, am.Post(context.Background(), alerts))
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, alerts, got)
+}
+
+func TestAlertmanagerPostFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad alerts", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	err := NewAlertmanager(srv.URL, time.Second).Post(context.Background(), []Alert{{Labels: map[string]string{"alertname": "CheapCruise"}}})
+	assert.EqualError(t, err, "alertmanager returned 400 Bad Request")
+}
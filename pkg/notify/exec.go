@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// maxExecOutput bounds the output of a failed command kept in its error.
+const maxExecOutput = 512
+
+// Exec runs a command for every event, with the event as JSON, or as
+// rendered by its body template, on stdin.
+type Exec struct {
+	name      string
+	command   []string
+	templates *Templates
+}
+
+func NewExec(name string, command []string) *Exec {
+	return &Exec{name: name, command: command}
+}
+
+// WithTemplates renders the events with t before running the command.
+func (x *Exec) WithTemplates(t *Templates) *Exec {
+	x.templates = t
+	return x
+}
+
+func (x *Exec) Name() string {
+	return x.name
+}
+
+// Notify runs the command until it exits or ctx is done, a non-zero exit
+// status is an error.
+func (x *Exec) Notify(ctx context.Context, e Event) error {
+	if x.templates != nil {
+		var err error
+		if e, err = x.templates.apply(e); err != nil {
+			return fmt.Errorf("rendering event: %w", err)
+		}
+	}
+	var stdin []byte
+	var err error
+	if x.templates != nil && x.templates.body != nil {
+		stdin, err = execute(x.templates.body, e)
+	} else {
+		stdin, err = json.Marshal(e)
+	}
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, x.command[0], x.command[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if len(msg) > maxExecOutput {
+			msg = msg[:maxExecOutput] + "..."
+		}
+		if msg == "" {
+			return fmt.Errorf("%s: %w", x.command[0], err)
+		}
+		return fmt.Errorf("%s: %w: %s", x.command[0], err, msg)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecWritesEventToStdin(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "event.json")
+	x := NewExec("script", []string{"sh", "-c", `cat > "$0"`, out})
+	require.NoError(t, x.Notify(context.Background(), templateEvent))
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var got Event
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, templateEvent.Title, got.Title)
+	assert.Equal(t, templateEvent.Labels, got.Labels)
+}
+
+func TestExecRendersBody(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "body")
+	tmpl, err := ParseTemplates(`{{.Rule}}!`, "", `{{.Title}} on {{.Labels.datelabel}}`, "text/plain")
+	require.NoError(t, err)
+	x := NewExec("script", []string{"sh", "-c", `cat > "$0"`, out}).WithTemplates(tmpl)
+	require.NoError(t, x.Notify(context.Background(), templateEvent))
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "cheap! on 2036-01-12", string(b), "the body sees the rendered title")
+}
+
+func TestExecFailure(t *testing.T) {
+	err := NewExec("script", []string{"sh", "-c", "echo no route to ship >&2; exit 3"}).Notify(context.Background(), templateEvent)
+	assert.EqualError(t, err, "sh: exit status 3: no route to ship")
+
+	err = NewExec("script", []string{"false"}).Notify(context.Background(), templateEvent)
+	assert.EqualError(t, err, "false: exit status 1")
+
+	err = NewExec("script", []string{"sh", "-c", "head -c 2000 /dev/zero | tr '\\0' x; exit 1"}).Notify(context.Background(), templateEvent)
+	require.Error(t, err)
+	assert.Equal(t, "sh: exit status 1: "+strings.Repeat("x", maxExecOutput)+"...", err.Error(), "the output is truncated")
+}
+
+func TestExecHonoursContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := NewExec("script", []string{"sleep", "10"}).Notify(ctx, templateEvent)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
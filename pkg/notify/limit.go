@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// Limiter allows at most count events per sliding interval.
+type Limiter struct {
+	count    int
+	interval time.Duration
+
+	mu   sync.Mutex
+	sent []time.Time
+}
+
+func NewLimiter(count int, interval time.Duration) *Limiter {
+	return &Limiter{count: count, interval: interval}
+}
+
+// Allow reports whether another event may be sent at now, and counts it if
+// so. A nil Limiter allows everything.
+func (l *Limiter) Allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cutoff := now.Add(-l.interval)
+	i := 0
+	for i < len(l.sent) && !l.sent[i].After(cutoff) {
+		i++
+	}
+	l.sent = l.sent[i:]
+	if len(l.sent) >= l.count {
+		return false
+	}
+	l.sent = append(l.sent, now)
+	return true
+}
+
+// Dedup suppresses events whose key was already seen within the window.
+type Dedup struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewDedup(window time.Duration) *Dedup {
+	return &Dedup{window: window, seen: map[string]time.Time{}}
+}
+
+// Allow reports whether key wasn't seen within the window before now, and
+// records it if so. A nil Dedup allows everything.
+func (d *Dedup) Allow(key string, now time.Time) bool {
+	if d == nil {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, t := range d.seen {
+		if now.Sub(t) >= d.window {
+			delete(d.seen, k)
+		}
+	}
+	if _, ok := d.seen[key]; ok {
+		return false
+	}
+	d.seen[key] = now
+	return true
+}
+
+// rateLimited drops the events of a notifier beyond its limit.
+type rateLimited struct {
+	Notifier
+	limiter *Limiter
+}
+
+// RateLimit wraps n so it sends at most count events per interval, the
+// others fail with ErrRateLimited.
+func RateLimit(n Notifier, count int, interval time.Duration) Notifier {
+	return &rateLimited{Notifier: n, limiter: NewLimiter(count, interval)}
+}
+
+func (n *rateLimited) Notify(ctx context.Context, e Event) error {
+	if !n.limiter.Allow(time.Now()) {
+		return ErrRateLimited
+	}
+	return n.Notifier.Notify(ctx, e)
+}
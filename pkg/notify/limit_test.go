@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterSlidingWindow(t *testing.T) {
+	l := NewLimiter(2, time.Minute)
+	start := time.Date(2036, 1, 12, 9, 0, 0, 0, time.UTC)
+	assert.True(t, l.Allow(start))
+	assert.True(t, l.Allow(start.Add(20*time.Second)))
+	assert.False(t, l.Allow(start.Add(40*time.Second)), "the third within a minute is dropped")
+	assert.True(t, l.Allow(start.Add(time.Minute)), "the first left the window after a minute")
+	assert.False(t, l.Allow(start.Add(79*time.Second)), "dropped events don't count but the others do")
+	assert.True(t, l.Allow(start.Add(80*time.Second)))
+
+	var none *Limiter
+	assert.True(t, none.Allow(start))
+}
+
+func TestDedupWindow(t *testing.T) {
+	d := NewDedup(time.Hour)
+	start := time.Date(2036, 1, 12, 9, 0, 0, 0, time.UTC)
+	assert.True(t, d.Allow("WN 899", start))
+	assert.False(t, d.Allow("WN 899", start.Add(59*time.Minute)))
+	assert.True(t, d.Allow("WN 849", start.Add(59*time.Minute)), "keys are deduplicated separately")
+	assert.True(t, d.Allow("WN 899", start.Add(time.Hour)), "the window counts from the first event")
+
+	var none *Dedup
+	assert.True(t, none.Allow("WN 899", start))
+}
+
+func TestRateLimit(t *testing.T) {
+	r := &recorder{name: "hook"}
+	n := RateLimit(r, 1, time.Hour)
+	assert.Equal(t, "hook", n.Name())
+	assert.NoError(t, n.Notify(context.Background(), Event{Title: "first"}))
+	assert.ErrorIs(t, n.Notify(context.Background(), Event{Title: "second"}), ErrRateLimited)
+	assert.Equal(t, []string{"first"}, r.titles())
+}
@@ -0,0 +1,260 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// deliveryTimeout bounds every delivery, which outlives the context passed to
+// Send so events sent just before shutdown still go out.
+const deliveryTimeout = 30 * time.Second
+
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// Event is something worth telling a user about, like a price anomaly. Rule
+// names the watch or other rule that raised it.
+type Event struct {
+	Kind     string            `json:"kind"`
+	Rule     string            `json:"rule,omitempty"`
+	Title    string            `json:"title"`
+	Text     string            `json:"text"`
+	Priority string            `json:"priority"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	// User owns the watch that raised the event, if any.
+	User string `json:"user,omitempty"`
+	// Price and PreviousPrice are set by price events, 0 when unknown.
+	Price         float64   `json:"price,omitempty"`
+	PreviousPrice float64   `json:"previous_price,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// Notifier delivers events to one channel.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, e Event) error
+}
+
+// Dispatcher sends events to notifiers by name.
+type Dispatcher struct {
+	notifiers map[string]Notifier
+	routes    []Route
+	policies  map[string]Policy
+	logger    *log.Logger
+	delivered func(notifier string, e Event, err error)
+	outbox    *Outbox
+	pending   sync.WaitGroup
+	inFlight  int64
+}
+
+func NewDispatcher(logger *log.Logger, notifiers ...Notifier) (*Dispatcher, error) {
+	d := &Dispatcher{notifiers: map[string]Notifier{}, policies: map[string]Policy{}, logger: logger}
+	for _, n := range notifiers {
+		if _, ok := d.notifiers[n.Name()]; ok {
+			return nil, fmt.Errorf("duplicate notifier name %q", n.Name())
+		}
+		d.notifiers[n.Name()] = n
+	}
+	return d, nil
+}
+
+// Names returns the names of the notifiers, sorted.
+func (d *Dispatcher) Names() []string {
+	names := make([]string, 0, len(d.notifiers))
+	for name := range d.notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddRoutes sends the events matching the routes to their notifiers too. Like
+// SetPolicy it must be called before the first Send.
+func (d *Dispatcher) AddRoutes(routes ...Route) error {
+	for _, r := range routes {
+		for _, name := range r.Notify {
+			if _, ok := d.notifiers[name]; !ok {
+				return fmt.Errorf("unknown notifier %q", name)
+			}
+		}
+	}
+	d.routes = append(d.routes, routes...)
+	return nil
+}
+
+// SetPolicy restricts the events sent to the named notifier.
+func (d *Dispatcher) SetPolicy(name string, p Policy) {
+	d.policies[name] = p
+}
+
+// OnDelivery calls fn with the notifier, the event and the error, nil on
+// success, of every delivery once it is done. Like SetPolicy it must be called
+// before the first Send.
+func (d *Dispatcher) OnDelivery(fn func(notifier string, e Event, err error)) {
+	d.delivered = fn
+}
+
+// SetOutbox queues the failed deliveries in o for Retry, except those dropped
+// by a rate limit. Like SetPolicy it must be called before the first Send.
+func (d *Dispatcher) SetOutbox(o *Outbox) {
+	d.outbox = o
+}
+
+// Queued returns the number of deliveries waiting in the outbox.
+func (d *Dispatcher) Queued() int {
+	if d == nil {
+		return 0
+	}
+	return d.outbox.Len()
+}
+
+// recipients returns the named notifiers plus those of the matching routes,
+// or every notifier when there are none.
+func (d *Dispatcher) recipients(names []string, e Event) []string {
+	seen := map[string]bool{}
+	var to []string
+	add := func(names []string) {
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				to = append(to, name)
+			}
+		}
+	}
+	add(names)
+	for i := range d.routes {
+		if d.routes[i].matches(e) {
+			add(d.routes[i].Notify)
+		}
+	}
+	if len(to) == 0 {
+		return d.Names()
+	}
+	return to
+}
+
+// Send delivers e to the named notifiers and those of the routes it matches,
+// or to every notifier when there are none, as long as their policy accepts
+// it. Delivery happens in the background and failures are logged and queued
+// in the outbox, if any. Flush waits for it.
+func (d *Dispatcher) Send(ctx context.Context, names []string, e Event) {
+	if d == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Priority == "" {
+		e.Priority = PriorityNormal
+	}
+	for _, name := range d.recipients(names, e) {
+		n, ok := d.notifiers[name]
+		if !ok {
+			d.logger.Printf("notify: unknown notifier %q", name)
+			continue
+		}
+		if p, ok := d.policies[name]; ok && !p.accepts(e) {
+			continue
+		}
+		d.deliver(ctx, n, queued{Notifier: name, Event: e})
+	}
+}
+
+// deliver sends q in the background, queueing it in the outbox again when
+// that fails.
+func (d *Dispatcher) deliver(ctx context.Context, n Notifier, q queued) {
+	d.pending.Add(1)
+	atomic.AddInt64(&d.inFlight, 1)
+	go func() {
+		defer d.pending.Done()
+		defer atomic.AddInt64(&d.inFlight, -1)
+		ctx, cancel := context.WithTimeout(detached{ctx}, deliveryTimeout)
+		defer cancel()
+		err := n.Notify(ctx, q.Event)
+		if err != nil {
+			d.logger.Printf("notify: sending %s to %s: %s", q.Event.Kind, n.Name(), err)
+		}
+		if d.delivered != nil {
+			d.delivered(n.Name(), q.Event, err)
+		}
+		if err == nil || d.outbox == nil || (q.Attempts == 0 && errors.Is(err, ErrRateLimited)) {
+			return
+		}
+		q.Attempts++
+		q.Error = err.Error()
+		kept, err := d.outbox.push(q, time.Now())
+		if err != nil {
+			d.logger.Printf("notify: error saving outbox: %s", err)
+		}
+		if !kept {
+			d.logger.Printf("notify: giving up on sending %s to %s after %d attempts", q.Event.Kind, n.Name(), q.Attempts)
+		}
+	}()
+}
+
+// Retry sends the deliveries of the outbox again once they are due, until
+// ctx is done.
+func (d *Dispatcher) Retry(ctx context.Context, interval time.Duration) {
+	if d == nil || d.outbox == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		due, err := d.outbox.due(time.Now())
+		if err != nil {
+			d.logger.Printf("notify: error saving outbox: %s", err)
+		}
+		for _, q := range due {
+			n, ok := d.notifiers[q.Notifier]
+			if !ok {
+				d.logger.Printf("notify: dropping queued %s for unknown notifier %q", q.Event.Kind, q.Notifier)
+				continue
+			}
+			d.deliver(ctx, n, q)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush waits until every event sent so far was delivered or failed, or ctx
+// is done.
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d notifications still pending: %w", atomic.LoadInt64(&d.inFlight), ctx.Err())
+	}
+}
+
+// detached keeps the values of a context but not its cancellation.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a notifier keeping the events it was sent, failing with err.
+type recorder struct {
+	name   string
+	err    error
+	block  chan struct{}
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Notify(ctx context.Context, e Event) error {
+	if r.block != nil {
+		<-r.block
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return r.err
+}
+
+func (r *recorder) titles() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var titles []string
+	for _, e := range r.events {
+		titles = append(titles, e.Title)
+	}
+	return titles
+}
+
+var discard = log.New(io.Discard, "", 0)
+
+func flush(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, d.Flush(ctx))
+}
+
+func TestDispatcherSendsToNamedOrEveryNotifier(t *testing.T) {
+	a, b := &recorder{name: "a"}, &recorder{name: "b"}
+	var logs bytes.Buffer
+	d, err := NewDispatcher(log.New(&logs, "", 0), b, a)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, d.Names())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.Send(ctx, []string{"a"}, Event{Title: "to a"})
+	d.Send(ctx, nil, Event{Title: "to all"})
+	d.Send(ctx, []string{"missing"}, Event{Title: "to nobody"})
+	flush(t, d)
+
+	assert.Equal(t, []string{"to a", "to all"}, a.titles(), "a cancelled context doesn't stop delivery")
+	assert.Equal(t, []string{"to all"}, b.titles())
+	assert.Contains(t, logs.String(), `notify: unknown notifier "missing"`)
+	assert.Equal(t, PriorityNormal, a.events[0].Priority)
+	assert.False(t, a.events[0].Time.IsZero())
+}
+
+func TestDispatcherDuplicateName(t *testing.T) {
+	_, err := NewDispatcher(discard, &recorder{name: "a"}, &recorder{name: "a"})
+	assert.EqualError(t, err, `duplicate notifier name "a"`)
+}
+
+func TestDispatcherOnDelivery(t *testing.T) {
+	failing := &recorder{name: "failing", err: errors.New("boom")}
+	d, err := NewDispatcher(discard, failing, &recorder{name: "ok"})
+	require.NoError(t, err)
+	var mu sync.Mutex
+	results := map[string]string{}
+	d.OnDelivery(func(notifier string, e Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[notifier] = "ok"
+		if err != nil {
+			results[notifier] = err.Error()
+		}
+	})
+	d.Send(context.Background(), nil, Event{Title: "price drop"})
+	flush(t, d)
+	assert.Equal(t, map[string]string{"failing": "boom", "ok": "ok"}, results)
+}
+
+func TestDispatcherFlushTimesOut(t *testing.T) {
+	slow := &recorder{name: "slow", block: make(chan struct{})}
+	defer close(slow.block)
+	d, err := NewDispatcher(discard, slow)
+	require.NoError(t, err)
+	d.Send(context.Background(), nil, Event{Title: "stuck"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = d.Flush(ctx)
+	assert.EqualError(t, err, "1 notifications still pending: context deadline exceeded")
+}
+
+func TestNilDispatcher(t *testing.T) {
+	var d *Dispatcher
+	d.Send(context.Background(), nil, Event{})
+	assert.NoError(t, d.Flush(context.Background()))
+	assert.Zero(t, d.Queued())
+}
+
+func TestWebhook(t *testing.T) {
+	var got []Event
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var e Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		got = append(got, e)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	w := NewWebhook("hook", srv.URL)
+	e := Event{Kind: "watch", Title: "cheap", Labels: map[string]string{"ship": "Wonder of the Seas"}, Price: 899, Time: time.Date(2036, 1, 12, 0, 0, 0, 0, time.UTC)}
+	require.NoError(t, w.Notify(context.Background(), e))
+	assert.Equal(t, []Event{e}, got)
+
+	status = http.StatusBadGateway
+	assert.EqualError(t, w.Notify(context.Background(), e), "webhook returned 502 Bad Gateway")
+}
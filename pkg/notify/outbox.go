@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	minRetryBackoff = 30 * time.Second
+	maxRetryBackoff = time.Hour
+)
+
+// queued is a failed delivery waiting to be retried.
+type queued struct {
+	Notifier  string    `json:"notifier"`
+	Event     Event     `json:"event"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error"`
+	Queued    time.Time `json:"queued"`
+	NextRetry time.Time `json:"next_retry"`
+}
+
+// Outbox keeps failed deliveries until a retry succeeds or they are older
+// than maxAge. With a path it is saved on every change, so the deliveries
+// survive a restart.
+type Outbox struct {
+	path   string
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	entries []queued
+}
+
+// LoadOutbox returns the outbox saved at path, or an empty one. A missing
+// file is not an error and an empty path keeps the outbox in memory.
+func LoadOutbox(path string, maxAge time.Duration) (*Outbox, error) {
+	o := &Outbox{path: path, maxAge: maxAge}
+	if path == "" {
+		return o, nil
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &o.entries); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Len returns the number of deliveries waiting, 0 for a nil Outbox.
+func (o *Outbox) Len() int {
+	if o == nil {
+		return 0
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// push queues q for another attempt, backing off exponentially with its
+// attempts. It reports false when q is too old to be retried.
+func (o *Outbox) push(q queued, now time.Time) (bool, error) {
+	if q.Queued.IsZero() {
+		q.Queued = now
+	}
+	if o.maxAge > 0 && now.Sub(q.Queued) >= o.maxAge {
+		return false, nil
+	}
+	backoff := minRetryBackoff
+	for i := 1; i < q.Attempts && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	q.NextRetry = now.Add(backoff)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.entries = append(o.entries, q)
+	return true, o.save()
+}
+
+// due removes and returns the deliveries to retry at now.
+func (o *Outbox) due(now time.Time) ([]queued, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var due []queued
+	kept := o.entries[:0]
+	for _, q := range o.entries {
+		if now.Before(q.NextRetry) {
+			kept = append(kept, q)
+		} else {
+			due = append(due, q)
+		}
+	}
+	o.entries = kept
+	if len(due) == 0 {
+		return nil, nil
+	}
+	return due, o.save()
+}
+
+// save writes the entries to the outbox file, the caller holds o.mu.
+func (o *Outbox) save() error {
+	if o.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(o.entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), filepath.Base(o.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), o.path)
+}
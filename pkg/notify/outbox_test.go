@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxBacksOff(t *testing.T) {
+	o, err := LoadOutbox("", time.Hour*24)
+	require.NoError(t, err)
+	now := time.Date(2036, 1, 12, 9, 0, 0, 0, time.UTC)
+	for attempts, backoff := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 9: time.Hour, 20: time.Hour} {
+		kept, err := o.push(queued{Notifier: "hook", Attempts: attempts}, now)
+		require.NoError(t, err)
+		require.True(t, kept)
+		due, err := o.due(now.Add(backoff - time.Second))
+		require.NoError(t, err)
+		assert.Empty(t, due, "attempt %d", attempts)
+		due, err = o.due(now.Add(backoff))
+		require.NoError(t, err)
+		assert.Len(t, due, 1, "attempt %d", attempts)
+		assert.Equal(t, now, due[0].Queued)
+	}
+}
+
+func TestOutboxGivesUpAfterMaxAge(t *testing.T) {
+	o, err := LoadOutbox("", time.Hour)
+	require.NoError(t, err)
+	queuedAt := time.Date(2036, 1, 12, 9, 0, 0, 0, time.UTC)
+	kept, err := o.push(queued{Attempts: 3, Queued: queuedAt}, queuedAt.Add(59*time.Minute))
+	require.NoError(t, err)
+	assert.True(t, kept)
+	kept, err = o.push(queued{Attempts: 4, Queued: queuedAt}, queuedAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, kept)
+	assert.Equal(t, 1, o.Len())
+}
+
+func TestOutboxSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	o, err := LoadOutbox(path, time.Hour)
+	require.NoError(t, err)
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = o.push(queued{Notifier: "hook", Event: Event{Title: "cheap"}, Attempts: 1, Error: "boom"}, now)
+	require.NoError(t, err)
+
+	reloaded, err := LoadOutbox(path, time.Hour)
+	require.NoError(t, err)
+	due, err := reloaded.due(now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "cheap", due[0].Event.Title)
+	assert.Equal(t, "boom", due[0].Error)
+
+	reloaded, err = LoadOutbox(path, time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, reloaded.Len(), "taking the due deliveries saves the outbox")
+}
+
+func TestDispatcherQueuesFailedDeliveries(t *testing.T) {
+	failing := &recorder{name: "failing", err: errors.New("boom")}
+	limited := RateLimit(&recorder{name: "limited"}, 0, time.Hour)
+	d, err := NewDispatcher(discard, failing, limited)
+	require.NoError(t, err)
+	o, err := LoadOutbox("", time.Hour)
+	require.NoError(t, err)
+	d.SetOutbox(o)
+
+	d.Send(context.Background(), nil, Event{Title: "cheap"})
+	flush(t, d)
+	assert.Equal(t, 1, d.Queued(), "rate limited deliveries are dropped rather than queued")
+
+	due, err := o.due(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "failing", due[0].Notifier)
+	assert.Equal(t, 1, due[0].Attempts)
+	assert.Equal(t, "boom", due[0].Error)
+}
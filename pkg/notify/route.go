@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+)
+
+// priorityLevels orders the priorities, an event without one counts as
+// normal.
+var priorityLevels = map[string]int{
+	PriorityLow:    0,
+	"":             1,
+	PriorityNormal: 1,
+	PriorityHigh:   2,
+}
+
+// ValidatePriority checks that p is one of low, normal or high, or empty.
+func ValidatePriority(p string) error {
+	if _, ok := priorityLevels[p]; !ok {
+		return fmt.Errorf("invalid priority %q, must be low, normal or high", p)
+	}
+	return nil
+}
+
+// atLeast reports whether p is min or higher, any priority when min is empty.
+func atLeast(p, min string) bool {
+	return min == "" || priorityLevels[p] >= priorityLevels[min]
+}
+
+// Route sends the events matching it to more notifiers. Empty fields match
+// every event.
+type Route struct {
+	Kinds       []string
+	Rules       []string
+	MinPriority string
+	Notify      []string
+}
+
+func (r *Route) matches(e Event) bool {
+	return contains(r.Kinds, e.Kind) && contains(r.Rules, e.Rule) && atLeast(e.Priority, r.MinPriority)
+}
+
+func contains(list []string, s string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy decides which events a notifier accepts.
+type Policy struct {
+	// MinPriority drops the events of lower priority.
+	MinPriority string
+	// Quiet reports whether the notifier is in its quiet hours, when only
+	// high priority events are sent.
+	Quiet func(time.Time) bool
+}
+
+func (p *Policy) accepts(e Event) bool {
+	if !atLeast(e.Priority, p.MinPriority) {
+		return false
+	}
+	return p.Quiet == nil || e.Priority == PriorityHigh || !p.Quiet(e.Time)
+}
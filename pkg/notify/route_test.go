@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteMatches(t *testing.T) {
+	r := Route{Kinds: []string{"watch", "anomaly"}, Rules: []string{"cheap"}, MinPriority: PriorityNormal}
+	assert.True(t, r.matches(Event{Kind: "watch", Rule: "cheap", Priority: PriorityHigh}))
+	assert.True(t, r.matches(Event{Kind: "anomaly", Rule: "cheap"}), "an empty priority counts as normal")
+	assert.False(t, r.matches(Event{Kind: "digest", Rule: "cheap", Priority: PriorityHigh}))
+	assert.False(t, r.matches(Event{Kind: "watch", Rule: "other", Priority: PriorityHigh}))
+	assert.False(t, r.matches(Event{Kind: "watch", Rule: "cheap", Priority: PriorityLow}))
+	assert.True(t, (&Route{}).matches(Event{Kind: "digest", Priority: PriorityLow}), "empty fields match every event")
+}
+
+func TestValidatePriority(t *testing.T) {
+	for _, p := range []string{"", PriorityLow, PriorityNormal, PriorityHigh} {
+		assert.NoError(t, ValidatePriority(p))
+	}
+	assert.EqualError(t, ValidatePriority("urgent"), `invalid priority "urgent", must be low, normal or high`)
+}
+
+func TestPolicyAccepts(t *testing.T) {
+	night := time.Date(2036, 1, 12, 2, 0, 0, 0, time.UTC)
+	day := time.Date(2036, 1, 12, 14, 0, 0, 0, time.UTC)
+	p := Policy{MinPriority: PriorityNormal, Quiet: func(t time.Time) bool { return t.Hour() < 7 }}
+	assert.True(t, p.accepts(Event{Priority: PriorityNormal, Time: day}))
+	assert.False(t, p.accepts(Event{Priority: PriorityLow, Time: day}))
+	assert.False(t, p.accepts(Event{Priority: PriorityNormal, Time: night}), "quiet hours hold back normal events")
+	assert.True(t, p.accepts(Event{Priority: PriorityHigh, Time: night}), "high priority events go through quiet hours")
+}
+
+func TestDispatcherRoutesAndPolicies(t *testing.T) {
+	owner, oncall, pager := &recorder{name: "owner"}, &recorder{name: "oncall"}, &recorder{name: "pager"}
+	d, err := NewDispatcher(discard, owner, oncall, pager)
+	require.NoError(t, err)
+	require.NoError(t, d.AddRoutes(
+		Route{Kinds: []string{"anomaly"}, Notify: []string{"oncall", "owner"}},
+		Route{MinPriority: PriorityHigh, Notify: []string{"pager"}},
+	))
+	assert.EqualError(t, d.AddRoutes(Route{Notify: []string{"missing"}}), `unknown notifier "missing"`)
+	d.SetPolicy("oncall", Policy{MinPriority: PriorityHigh})
+
+	d.Send(context.Background(), []string{"owner"}, Event{Kind: "watch", Title: "watch"})
+	d.Send(context.Background(), []string{"owner"}, Event{Kind: "anomaly", Title: "anomaly"})
+	d.Send(context.Background(), []string{"owner"}, Event{Kind: "anomaly", Title: "urgent anomaly", Priority: PriorityHigh})
+	flush(t, d)
+
+	sorted := func(r *recorder) []string {
+		titles := r.titles()
+		sort.Strings(titles)
+		return titles
+	}
+	assert.Equal(t, []string{"anomaly", "urgent anomaly", "watch"}, sorted(owner), "a notifier named and routed to gets the event once")
+	assert.Equal(t, []string{"urgent anomaly"}, sorted(oncall), "the policy drops what the route sends")
+	assert.Equal(t, []string{"urgent anomaly"}, sorted(pager))
+}
+
+func TestPolicyWithoutMinimumAcceptsLowPriority(t *testing.T) {
+	p := Policy{}
+	assert.True(t, p.accepts(Event{Priority: PriorityLow}))
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are available to every template besides the builtins, html
+// among them for email bodies.
+var templateFuncs = template.FuncMap{
+	// json quotes a value for a JSON body, e.g. {"text": {{json .Text}}}.
+	"json": func(v interface{}) (string, error) {
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(b.String(), "\n"), nil
+	},
+	"price": func(v float64) string {
+		return fmt.Sprintf("%.0f", v)
+	},
+}
+
+// Templates customize the events of a channel. Every template is executed
+// with the Event, whose Labels hold the cruise and sailing, and an empty one
+// keeps the default.
+type Templates struct {
+	title       *template.Template
+	text        *template.Template
+	body        *template.Template
+	contentType string
+}
+
+// ParseTemplates parses the title, text and body templates. The body replaces
+// the JSON encoding of the event and is sent as contentType.
+func ParseTemplates(title, text, body, contentType string) (*Templates, error) {
+	t := &Templates{contentType: contentType}
+	for _, tmpl := range []struct {
+		name string
+		src  string
+		dst  **template.Template
+	}{
+		{"title", title, &t.title},
+		{"text", text, &t.text},
+		{"body", body, &t.body},
+	} {
+		if tmpl.src == "" {
+			continue
+		}
+		parsed, err := template.New(tmpl.name).Funcs(templateFuncs).Option("missingkey=zero").Parse(tmpl.src)
+		if err != nil {
+			return nil, err
+		}
+		*tmpl.dst = parsed
+	}
+	if t.contentType == "" {
+		t.contentType = "application/json"
+	}
+	return t, nil
+}
+
+func execute(tmpl *template.Template, e Event) ([]byte, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, e); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// apply renders the title and text of e.
+func (t *Templates) apply(e Event) (Event, error) {
+	if t.title != nil {
+		b, err := execute(t.title, e)
+		if err != nil {
+			return e, err
+		}
+		e.Title = string(b)
+	}
+	if t.text != nil {
+		b, err := execute(t.text, e)
+		if err != nil {
+			return e, err
+		}
+		e.Text = string(b)
+	}
+	return e, nil
+}
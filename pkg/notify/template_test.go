@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var templateEvent = Event{
+	Kind:   "watch",
+	Rule:   "cheap",
+	Title:  "cheap: Wonder of the Seas",
+	Text:   `Stateroom class I is now 899`,
+	Labels: map[string]string{"ship": "Wonder of the Seas", "datelabel": "2036-01-12"},
+	Price:  899.5,
+}
+
+func TestTemplatesApply(t *testing.T) {
+	tmpl, err := ParseTemplates(`{{.Labels.ship}} at {{price .Price}}`, `{{.Text}} on {{.Labels.datelabel}}{{.Labels.missing}}`, "", "")
+	require.NoError(t, err)
+	e, err := tmpl.apply(templateEvent)
+	require.NoError(t, err)
+	assert.Equal(t, "Wonder of the Seas at 900", e.Title)
+	assert.Equal(t, "Stateroom class I is now 899 on 2036-01-12", e.Text, "a missing label renders empty")
+	assert.Equal(t, "application/json", tmpl.contentType)
+
+	tmpl, err = ParseTemplates("", `{{.Rule}}`, "", "")
+	require.NoError(t, err)
+	e, err = tmpl.apply(templateEvent)
+	require.NoError(t, err)
+	assert.Equal(t, templateEvent.Title, e.Title, "an empty template keeps the default")
+	assert.Equal(t, "cheap", e.Text)
+}
+
+func TestParseTemplatesErrors(t *testing.T) {
+	_, err := ParseTemplates(`{{.Title`, "", "", "")
+	assert.Error(t, err)
+	_, err = ParseTemplates("", "", `{{nope .Title}}`, "")
+	assert.EqualError(t, err, `template: body:1: function "nope" not defined`)
+
+	tmpl, err := ParseTemplates(`{{.Title.Missing}}`, "", "", "")
+	require.NoError(t, err)
+	_, err = tmpl.apply(templateEvent)
+	assert.Error(t, err, "a template failing to execute fails the event")
+}
+
+func TestWebhookBodyTemplate(t *testing.T) {
+	var body, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, contentType = string(b), r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	tmpl, err := ParseTemplates(`{{.Labels.ship}}`, "", `{"text": {{json .Title}}, "html": "{{html "<b>"}}"}`, "application/vnd.chat+json")
+	require.NoError(t, err)
+	e := templateEvent
+	e.Labels = map[string]string{"ship": `Wonder "of" the Seas & <co>`}
+	require.NoError(t, NewWebhook("chat", srv.URL).WithTemplates(tmpl).Notify(context.Background(), e))
+	assert.Equal(t, `{"text": "Wonder \"of\" the Seas & <co>", "html": "&lt;b&gt;"}`, body, "the body sees the rendered title")
+	assert.Equal(t, "application/vnd.chat+json", contentType)
+}
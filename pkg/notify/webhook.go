@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Webhook POSTs every event as JSON to a URL, or as rendered by its body
+// template.
+type Webhook struct {
+	name      string
+	url       string
+	client    *http.Client
+	templates *Templates
+}
+
+func NewWebhook(name, url string) *Webhook {
+	return &Webhook{name: name, url: url, client: &http.Client{}}
+}
+
+// WithTemplates renders the events with t before sending them.
+func (w *Webhook) WithTemplates(t *Templates) *Webhook {
+	w.templates = t
+	return w
+}
+
+func (w *Webhook) Name() string {
+	return w.name
+}
+
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	contentType := "application/json"
+	if w.templates != nil {
+		var err error
+		if e, err = w.templates.apply(e); err != nil {
+			return fmt.Errorf("rendering event: %w", err)
+		}
+		contentType = w.templates.contentType
+	}
+	var body []byte
+	var err error
+	if w.templates != nil && w.templates.body != nil {
+		body, err = execute(w.templates.body, e)
+	} else {
+		body, err = json.Marshal(e)
+	}
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer answers every command with the raw RESP reply of its reply func,
+// recording the commands and the connections they came on.
+type fakeServer struct {
+	ln      net.Listener
+	reply   func(args []string) string
+	mu      sync.Mutex
+	conns   int
+	history []string
+}
+
+func newFakeServer(t *testing.T, reply func(args []string) string) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeServer{ln: ln, reply: reply}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.history = append(s.history, strings.Join(args, " "))
+		s.mu.Unlock()
+		io.WriteString(conn, s.reply(args))
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func (s *fakeServer) commands() ([]string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.history...), s.conns
+}
+
+func TestClientCommands(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch strings.Join(args, " ") {
+		case "GET missing":
+			return "$-1\r\n"
+		case "GET catalog":
+			return "$12\r\n{\"a\":\"b\r\nc\"}\r\n"
+		case "SET lease me NX PX 1000":
+			return "$-1\r\n"
+		case "INCR n":
+			return ":42\r\n"
+		case "LRANGE l 0 -1":
+			return "*3\r\n$1\r\na\r\n:2\r\n$-1\r\n"
+		case "WRONG":
+			return "-ERR unknown command 'WRONG'\r\n"
+		}
+		return "+OK\r\n"
+	})
+	c := NewClient(s.ln.Addr().String(), "s3cret", 2)
+	defer c.Close()
+	ctx := context.Background()
+
+	_, err := c.Get(ctx, "missing")
+	assert.Equal(t, ErrNil, err)
+	v, err := c.Get(ctx, "catalog")
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":\"b\r\nc\"}", v, "bulk strings may hold CRLF")
+
+	require.NoError(t, c.Set(ctx, "catalog", "{}", 1500*time.Millisecond))
+	require.NoError(t, c.Set(ctx, "forever", "1", 0))
+	ok, err := c.SetNX(ctx, "lease", "me", time.Second)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	n, err := c.Do(ctx, "INCR", "n")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+	l, err := c.Do(ctx, "LRANGE", "l", "0", "-1")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", int64(2), nil}, l)
+
+	_, err = c.Do(ctx, "WRONG")
+	assert.EqualError(t, err, "redis: ERR unknown command 'WRONG'")
+
+	commands, conns := s.commands()
+	assert.Equal(t, []string{
+		"AUTH s3cret", "SELECT 2",
+		"GET missing", "GET catalog",
+		"SET catalog {} PX 1500", "SET forever 1",
+		"SET lease me NX PX 1000",
+		"INCR n", "LRANGE l 0 -1", "WRONG",
+	}, commands)
+	assert.Equal(t, 1, conns, "an error reply keeps the connection")
+}
+
+func TestClientReconnectsAfterProtocolError(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "GET" && args[1] == "garbled" {
+			return "?what\r\n"
+		}
+		return "+PONG\r\n"
+	})
+	c := NewClient(s.ln.Addr().String(), "", 0)
+	defer c.Close()
+	ctx := context.Background()
+
+	_, err := c.Get(ctx, "garbled")
+	assert.EqualError(t, err, `redis: unknown reply type '?'`)
+	reply, err := c.Do(ctx, "PING")
+	require.NoError(t, err)
+	assert.Equal(t, "PONG", reply)
+
+	commands, conns := s.commands()
+	assert.Equal(t, []string{"GET garbled", "PING"}, commands, "no AUTH or SELECT without a password or database")
+	assert.Equal(t, 2, conns)
+}
+
+func TestClientFailedAuth(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "AUTH" {
+			return "-WRONGPASS invalid username-password pair\r\n"
+		}
+		return "+OK\r\n"
+	})
+	c := NewClient(s.ln.Addr().String(), "wrong", 0)
+	defer c.Close()
+
+	_, err := c.Do(context.Background(), "PING")
+	assert.EqualError(t, err, "redis: WRONGPASS invalid username-password pair")
+	commands, _ := s.commands()
+	assert.Equal(t, []string{"AUTH wrong"}, commands)
+}
+
+func TestClientTimesOut(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}
+	}()
+	c := NewClient(ln.Addr().String(), "", 0)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.Do(ctx, "PING")
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+}
@@ -0,0 +1,86 @@
+package royalapi
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CalendarOperationName is the month view pricing calendar of an itinerary,
+// one lowest price per sail date and stateroom super category.
+const CalendarOperationName = "pricingCalendar"
+
+const calendarQuery = `query pricingCalendar($itineraryCode: String!, $month: String!) {
+  pricingCalendar(itineraryCode: $itineraryCode, month: $month) {
+    itineraryCode
+    sailDates {
+      sailDate
+      prices {
+        superCategory
+        lowestPrice {
+          value
+          __typename
+        }
+        __typename
+      }
+      __typename
+    }
+    __typename
+  }
+}`
+
+// CalendarRequest is the POST body of a pricingCalendar call.
+type CalendarRequest struct {
+	OperationName string            `json:"operationName"`
+	Variables     CalendarVariables `json:"variables"`
+	Query         string            `json:"query"`
+}
+
+type CalendarVariables struct {
+	ItineraryCode string `json:"itineraryCode"`
+	// Month is formatted like 2025-01.
+	Month string `json:"month"`
+}
+
+// NewCalendarRequest asks for the prices of the itinerary sailing in the
+// month of t.
+func NewCalendarRequest(itinerary string, t time.Time) CalendarRequest {
+	return CalendarRequest{
+		OperationName: CalendarOperationName,
+		Variables:     CalendarVariables{ItineraryCode: itinerary, Month: t.Format("2006-01")},
+		Query:         calendarQuery,
+	}
+}
+
+type CalendarResponse struct {
+	Data struct {
+		PricingCalendar Calendar `json:"pricingCalendar"`
+	} `json:"data"`
+	Errors []Error `json:"errors"`
+}
+
+type Calendar struct {
+	ItineraryCode string             `json:"itineraryCode"`
+	SailDates     []CalendarSailDate `json:"sailDates"`
+}
+
+type CalendarSailDate struct {
+	SailDate string          `json:"sailDate"`
+	Prices   []CalendarPrice `json:"prices"`
+}
+
+type CalendarPrice struct {
+	SuperCategory string `json:"superCategory"`
+	LowestPrice   struct {
+		Value float64 `json:"value"`
+	} `json:"lowestPrice"`
+}
+
+// ParseCalendar decodes a pricingCalendar response. The responses are
+// small, unlike search pages they go through encoding/json.
+func ParseCalendar(body []byte) (*CalendarResponse, error) {
+	resp := &CalendarResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
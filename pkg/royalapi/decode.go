@@ -0,0 +1,495 @@
+package royalapi
+
+// The decoders below fill the model without reflection, they are what Parse
+// uses. A field added to the model needs a case here too, in lower case as
+// object folds the keys the way encoding/json matches them.
+
+func (r *Response) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "data":
+			return d.object(func(key []byte) error {
+				if string(key) == "cruisesearch" {
+					return r.Data.CruiseSearch.decode(d)
+				}
+				return d.skip()
+			})
+		case "errors":
+			r.Errors = nil
+			if d.peek() == '[' {
+				r.Errors = []Error{}
+			}
+			return d.array(func() error {
+				var e Error
+				err := e.decode(d)
+				r.Errors = append(r.Errors, e)
+				return err
+			})
+		}
+		return d.skip()
+	})
+}
+
+func (e *Error) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "message":
+			return d.str(&e.Message)
+		case "extensions":
+			return d.object(func(key []byte) error {
+				if string(key) == "code" {
+					return d.str(&e.Extensions.Code)
+				}
+				return d.skip()
+			})
+		}
+		return d.skip()
+	})
+}
+
+func (c *CruiseSearch) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "results":
+			return c.Results.decode(d)
+		case "__typename":
+			return d.str(&c.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (r *Results) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "cruises":
+			r.Cruises = nil
+			if d.peek() == '[' {
+				r.Cruises = []Cruise{}
+			}
+			return d.array(func() error {
+				var c Cruise
+				err := c.decode(d)
+				r.Cruises = append(r.Cruises, c)
+				return err
+			})
+		case "cruiserecommendationid":
+			return d.str(&r.CruiseRecommendationID)
+		case "total":
+			return d.int(&r.Total)
+		case "__typename":
+			return d.str(&r.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (c *Cruise) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "id":
+			return d.str(&c.ID)
+		case "productviewlink":
+			return d.str(&c.ProductViewLink)
+		case "lowestpricesailing":
+			return c.LowestPriceSailing.decode(d)
+		case "mastersailing":
+			return d.object(func(key []byte) error {
+				switch string(key) {
+				case "itinerary":
+					return c.MasterSailing.Itinerary.decode(d)
+				case "__typename":
+					return d.str(&c.MasterSailing.Typename)
+				}
+				return d.skip()
+			})
+		case "sailings":
+			c.Sailings = nil
+			if d.peek() == '[' {
+				c.Sailings = []Sailing{}
+			}
+			return d.array(func() error {
+				var s Sailing
+				err := s.decode(d)
+				c.Sailings = append(c.Sailings, s)
+				return err
+			})
+		case "__typename":
+			return d.str(&c.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (s *LowestPriceSailing) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "bookinglink":
+			return d.str(&s.BookingLink)
+		case "id":
+			return d.str(&s.ID)
+		case "loweststateroomclassprice":
+			return s.LowestStateroomClassPrice.decode(d)
+		case "saildate":
+			return d.str(&s.SailDate)
+		case "startdate":
+			return d.str(&s.StartDate)
+		case "enddate":
+			return d.str(&s.EndDate)
+		case "taxesandfees":
+			return d.object(func(key []byte) error {
+				switch string(key) {
+				case "value":
+					return d.float(&s.TaxesAndFees.Value)
+				case "__typename":
+					return d.str(&s.TaxesAndFees.Typename)
+				}
+				return d.skip()
+			})
+		case "taxesandfeesincluded":
+			return d.boolean(&s.TaxesAndFeesIncluded)
+		case "__typename":
+			return d.str(&s.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (it *Itinerary) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "code":
+			return d.str(&it.Code)
+		case "media":
+			return it.Media.decode(d)
+		case "days":
+			it.Days = nil
+			if d.peek() == '[' {
+				it.Days = []Day{}
+			}
+			return d.array(func() error {
+				var day Day
+				err := day.decode(d)
+				it.Days = append(it.Days, day)
+				return err
+			})
+		case "departureport":
+			return d.object(func(key []byte) error {
+				switch string(key) {
+				case "code":
+					return d.str(&it.DeparturePort.Code)
+				case "name":
+					return d.str(&it.DeparturePort.Name)
+				case "region":
+					return d.str(&it.DeparturePort.Region)
+				case "__typename":
+					return d.str(&it.DeparturePort.Typename)
+				}
+				return d.skip()
+			})
+		case "destination":
+			return d.object(func(key []byte) error {
+				switch string(key) {
+				case "code":
+					return d.str(&it.Destination.Code)
+				case "name":
+					return d.str(&it.Destination.Name)
+				case "__typename":
+					return d.str(&it.Destination.Typename)
+				}
+				return d.skip()
+			})
+		case "name":
+			return d.str(&it.Name)
+		case "posttour":
+			return d.raw(&it.PostTour)
+		case "pretour":
+			return d.raw(&it.PreTour)
+		case "sailingnights":
+			return d.int(&it.SailingNights)
+		case "ship":
+			return it.Ship.decode(d)
+		case "totalnights":
+			return d.int(&it.TotalNights)
+		case "type":
+			return d.str(&it.Type)
+		case "__typename":
+			return d.str(&it.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (day *Day) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "number":
+			return d.int(&day.Number)
+		case "type":
+			return d.str(&day.Type)
+		case "ports":
+			day.Ports = nil
+			if d.peek() == '[' {
+				day.Ports = []PortCall{}
+			}
+			return d.array(func() error {
+				var p PortCall
+				err := p.decode(d)
+				day.Ports = append(day.Ports, p)
+				return err
+			})
+		case "__typename":
+			return d.str(&day.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (p *PortCall) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "activity":
+			return d.str(&p.Activity)
+		case "arrivaltime":
+			return d.str(&p.ArrivalTime)
+		case "departuretime":
+			return d.str(&p.DepartureTime)
+		case "port":
+			return d.object(func(key []byte) error {
+				switch string(key) {
+				case "code":
+					return d.str(&p.Port.Code)
+				case "name":
+					return d.str(&p.Port.Name)
+				case "region":
+					return d.str(&p.Port.Region)
+				case "media":
+					return p.Port.Media.decode(d)
+				case "__typename":
+					return d.str(&p.Port.Typename)
+				}
+				return d.skip()
+			})
+		case "__typename":
+			return d.str(&p.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (s *Ship) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "code":
+			return d.str(&s.Code)
+		case "name":
+			return d.str(&s.Name)
+		case "stateroomclasses":
+			s.StateroomClasses = nil
+			if d.peek() == '[' {
+				s.StateroomClasses = []StateroomClass{}
+			}
+			return d.array(func() error {
+				var c StateroomClass
+				err := c.decode(d)
+				s.StateroomClasses = append(s.StateroomClasses, c)
+				return err
+			})
+		case "media":
+			return s.Media.decode(d)
+		case "__typename":
+			return d.str(&s.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (c *StateroomClass) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "id":
+			return d.str(&c.ID)
+		case "name":
+			return d.str(&c.Name)
+		case "content":
+			return c.Content.decode(d)
+		case "__typename":
+			return d.str(&c.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (c *StateroomContent) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "amenities":
+			c.Amenities = nil
+			if d.peek() == '[' {
+				c.Amenities = []string{}
+			}
+			return d.array(func() error {
+				var a string
+				err := d.str(&a)
+				c.Amenities = append(c.Amenities, a)
+				return err
+			})
+		case "area":
+			return d.raw(&c.Area)
+		case "code":
+			return d.str(&c.Code)
+		case "maxcapacity":
+			return d.str(&c.MaxCapacity)
+		case "media":
+			return d.object(func(key []byte) error {
+				switch string(key) {
+				case "images":
+					c.Media.Images = nil
+					if d.peek() == '[' {
+						c.Media.Images = []StateroomImage{}
+					}
+					return d.array(func() error {
+						var img StateroomImage
+						err := img.decode(d)
+						c.Media.Images = append(c.Media.Images, img)
+						return err
+					})
+				case "__typename":
+					return d.str(&c.Media.Typename)
+				}
+				return d.skip()
+			})
+		case "supercategory":
+			return d.str(&c.SuperCategory)
+		case "__typename":
+			return d.str(&c.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (img *StateroomImage) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "path":
+			return d.str(&img.Path)
+		case "meta":
+			return d.object(func(key []byte) error {
+				switch string(key) {
+				case "description":
+					return d.str(&img.Meta.Description)
+				case "title":
+					return d.str(&img.Meta.Title)
+				case "location":
+					return d.str(&img.Meta.Location)
+				case "__typename":
+					return d.str(&img.Meta.Typename)
+				}
+				return d.skip()
+			})
+		case "__typename":
+			return d.str(&img.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (m *Media) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "images":
+			m.Images = nil
+			if d.peek() == '[' {
+				m.Images = []Image{}
+			}
+			return d.array(func() error {
+				var img Image
+				err := d.object(func(key []byte) error {
+					switch string(key) {
+					case "path":
+						return d.str(&img.Path)
+					case "__typename":
+						return d.str(&img.Typename)
+					}
+					return d.skip()
+				})
+				m.Images = append(m.Images, img)
+				return err
+			})
+		case "__typename":
+			return d.str(&m.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (s *Sailing) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "bookinglink":
+			return d.str(&s.BookingLink)
+		case "id":
+			return d.str(&s.ID)
+		case "itinerary":
+			return d.object(func(key []byte) error {
+				switch string(key) {
+				case "code":
+					return d.str(&s.Itinerary.Code)
+				case "__typename":
+					return d.str(&s.Itinerary.Typename)
+				}
+				return d.skip()
+			})
+		case "saildate":
+			return d.str(&s.SailDate)
+		case "startdate":
+			return d.str(&s.StartDate)
+		case "enddate":
+			return d.str(&s.EndDate)
+		case "stateroomclasspricing":
+			s.StateroomClassPricing = nil
+			if d.peek() == '[' {
+				s.StateroomClassPricing = []StateroomClassPrice{}
+			}
+			return d.array(func() error {
+				var p StateroomClassPrice
+				err := p.decode(d)
+				s.StateroomClassPricing = append(s.StateroomClassPricing, p)
+				return err
+			})
+		case "__typename":
+			return d.str(&s.Typename)
+		}
+		return d.skip()
+	})
+}
+
+func (p *StateroomClassPrice) decode(d *decoder) error {
+	return d.object(func(key []byte) error {
+		switch string(key) {
+		case "price":
+			return d.object(func(key []byte) error {
+				switch string(key) {
+				case "value":
+					return d.int(&p.Price.Value)
+				case "__typename":
+					return d.str(&p.Price.Typename)
+				}
+				return d.skip()
+			})
+		case "stateroomclass":
+			return d.object(func(key []byte) error {
+				switch string(key) {
+				case "id":
+					return d.str(&p.StateroomClass.ID)
+				case "__typename":
+					return d.str(&p.StateroomClass.Typename)
+				}
+				return d.skip()
+			})
+		case "__typename":
+			return d.str(&p.Typename)
+		}
+		return d.skip()
+	})
+}
@@ -0,0 +1,117 @@
+package royalapi
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFixture(t *testing.T, name string) *Response {
+	t.Helper()
+	body, err := os.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+	resp, err := Parse(body)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestParseCruiseSearch(t *testing.T) {
+	resp := parseFixture(t, "cruise_search.json")
+
+	assert.Equal(t, 1184, resp.Total())
+	assert.False(t, resp.PersistedQueryNotFound())
+	assert.Equal(t, "b6c1a0e2-7f3e-4c59-9d1e-2f0f6a7a1c42", resp.Data.CruiseSearch.Results.CruiseRecommendationID)
+	require.Len(t, resp.Cruises(), 2)
+
+	wonder := resp.Cruises()[0]
+	assert.Equal(t, "WN07RCI-1734476400000", wonder.ID)
+	assert.Equal(t, "CruiseSearchCruise", wonder.Typename)
+	assert.Equal(t, 899, wonder.LowestPriceSailing.LowestStateroomClassPrice.Price.Value)
+	assert.Equal(t, "I", wonder.LowestPriceSailing.LowestStateroomClassPrice.StateroomClass.ID)
+	assert.Equal(t, 163.12, wonder.LowestPriceSailing.TaxesAndFees.Value)
+	assert.False(t, wonder.LowestPriceSailing.TaxesAndFeesIncluded)
+	assert.Equal(t, "2036-01-19", wonder.LowestPriceSailing.EndDate)
+
+	it := wonder.MasterSailing.Itinerary
+	assert.Equal(t, "WN07W375", it.Code)
+	assert.Equal(t, `7 Night Western Caribbean "Holiday" Cruise`, it.Name)
+	assert.Equal(t, 7, it.SailingNights)
+	assert.Equal(t, 7, it.TotalNights)
+	assert.Equal(t, "PCV", it.DeparturePort.Code)
+	assert.Equal(t, "CARIB", it.Destination.Code)
+	assert.Equal(t, "Wonder of the Seas", it.Ship.Name)
+	assert.Equal(t, []string{"PCV", "CZM"}, it.PortCodes())
+	require.Len(t, it.Days, 3)
+	assert.Equal(t, "CRUISING", it.Days[1].Type)
+	assert.Empty(t, it.Days[1].Ports)
+	assert.Equal(t, "Cozumel, México", it.Days[2].Ports[0].Port.Name)
+	assert.Equal(t, "07:00", it.Days[2].Ports[0].ArrivalTime)
+	assert.Len(t, it.Media.Images, 1)
+
+	assert.Equal(t, json.RawMessage("null"), it.PostTour)
+	assert.JSONEq(t, `{"code":"ORL","nights":2}`, string(it.PreTour))
+
+	require.Len(t, it.Ship.StateroomClasses, 1)
+	content := it.Ship.StateroomClasses[0].Content
+	assert.Equal(t, []string{"Two twin beds", "Private bathroom"}, content.Amenities)
+	assert.JSONEq(t, `{"value":149,"unit":"sq. ft."}`, string(content.Area))
+	assert.Equal(t, "4", content.MaxCapacity)
+	assert.Equal(t, "INTERIOR", content.SuperCategory)
+	require.Len(t, content.Media.Images, 1)
+	assert.Equal(t, "Deck 6", content.Media.Images[0].Meta.Location)
+
+	require.Len(t, wonder.Sailings, 2)
+	pricing := wonder.Sailings[0].StateroomClassPricing
+	require.Len(t, pricing, 3)
+	assert.Equal(t, 1049, pricing[1].Price.Value)
+	assert.Equal(t, Price{}, pricing[2].Price, "a null price decodes to the zero value")
+	assert.Equal(t, "S", pricing[2].StateroomClass.ID)
+	assert.Equal(t, 899, wonder.MinSailingPrice())
+
+	icon := resp.Cruises()[1]
+	assert.Equal(t, 125.0, icon.LowestPriceSailing.TaxesAndFees.Value)
+	assert.True(t, icon.LowestPriceSailing.TaxesAndFeesIncluded)
+	assert.Nil(t, icon.MasterSailing.Itinerary.Days)
+	assert.Empty(t, icon.MasterSailing.Itinerary.PortCodes())
+	assert.Equal(t, "Bahamas 🌴", icon.MasterSailing.Itinerary.Destination.Name)
+	assert.Empty(t, icon.MasterSailing.Itinerary.Ship.StateroomClasses)
+	assert.Equal(t, 529, icon.MinSailingPrice())
+	assert.Equal(t, "IC03M001", icon.Sailings[0].Itinerary.Code)
+}
+
+func TestParsePersistedQueryNotFound(t *testing.T) {
+	resp := parseFixture(t, "persisted_query_not_found.json")
+
+	assert.True(t, resp.PersistedQueryNotFound())
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "PERSISTED_QUERY_NOT_FOUND", resp.Errors[0].Extensions.Code)
+	assert.Empty(t, resp.Cruises())
+	assert.Zero(t, resp.Total())
+}
+
+func TestMinSailingPriceIgnoresUnpriced(t *testing.T) {
+	c := Cruise{Sailings: []Sailing{
+		{StateroomClassPricing: []StateroomClassPrice{{Price: Price{Value: 0}}, {Price: Price{Value: 700}}}},
+		{StateroomClassPricing: []StateroomClassPrice{{Price: Price{Value: 650}}}},
+	}}
+	assert.Equal(t, 650, c.MinSailingPrice())
+	assert.Zero(t, Cruise{}.MinSailingPrice())
+}
+
+func TestParseRejectsWrongTypes(t *testing.T) {
+	for name, body := range map[string]string{
+		"string total":  `{"data":{"cruiseSearch":{"results":{"total":"1184"}}}}`,
+		"number id":     `{"data":{"cruiseSearch":{"results":{"cruises":[{"id":1}]}}}}`,
+		"object price":  `{"data":{"cruiseSearch":{"results":{"cruises":[{"sailings":[{"stateroomClassPricing":[{"price":{"value":{}}}]}]}]}}}}`,
+		"string bool":   `{"data":{"cruiseSearch":{"results":{"cruises":[{"lowestPriceSailing":{"taxesAndFeesIncluded":"true"}}]}}}}`,
+		"fraction days": `{"data":{"cruiseSearch":{"results":{"cruises":[{"masterSailing":{"itinerary":{"days":[{"number":1.5}]}}}]}}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(body))
+			assert.Error(t, err)
+		})
+	}
+}
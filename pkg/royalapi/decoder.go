@@ -0,0 +1,450 @@
+package royalapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decoder is a minimal JSON reader for the hand-written decoders of the
+// model. Unlike encoding/json it stops at the first value of the wrong type
+// rather than skipping it, either way Parse fails.
+type decoder struct {
+	data []byte
+	pos  int
+	// folded holds the last key folded to lower case.
+	folded []byte
+	// interned holds the short strings decoded so far, the type names, codes
+	// and ids a page repeats over and over.
+	interned map[string]string
+}
+
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("royalapi: offset %d: %s", d.pos, fmt.Sprintf(format, args...))
+}
+
+func (d *decoder) ws() {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next non-space byte without consuming it, 0 at the end.
+func (d *decoder) peek() byte {
+	d.ws()
+	if d.pos >= len(d.data) {
+		return 0
+	}
+	return d.data[d.pos]
+}
+
+func (d *decoder) literal(lit string) error {
+	if len(d.data)-d.pos < len(lit) || string(d.data[d.pos:d.pos+len(lit)]) != lit {
+		return d.errorf("invalid literal, expected %s", lit)
+	}
+	d.pos += len(lit)
+	return nil
+}
+
+// null consumes a null, reporting whether there was one. Like encoding/json
+// a null leaves the destination untouched.
+func (d *decoder) null() (bool, error) {
+	if d.peek() != 'n' {
+		return false, nil
+	}
+	return true, d.literal("null")
+}
+
+// end checks that nothing but whitespace follows the top-level value.
+func (d *decoder) end() error {
+	if d.peek() != 0 {
+		return d.errorf("invalid character %q after top-level value", d.data[d.pos])
+	}
+	return nil
+}
+
+// object calls field for every key of an object, which must consume the
+// value of the key, using skip when it isn't of interest.
+func (d *decoder) object(field func(key []byte) error) error {
+	if null, err := d.null(); null || err != nil {
+		return err
+	}
+	if d.peek() != '{' {
+		return d.unexpected("object")
+	}
+	d.pos++
+	if d.peek() == '}' {
+		d.pos++
+		return nil
+	}
+	for {
+		if d.peek() != '"' {
+			return d.unexpected("object key")
+		}
+		key, err := d.rawString()
+		if err != nil {
+			return err
+		}
+		if d.peek() != ':' {
+			return d.unexpected("':'")
+		}
+		d.pos++
+		if err := field(d.fold(key)); err != nil {
+			return err
+		}
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case '}':
+			d.pos++
+			return nil
+		default:
+			return d.unexpected("',' or '}'")
+		}
+	}
+}
+
+// fold returns key in lower case, so the decoders match keys to fields
+// case-insensitively like encoding/json does.
+func (d *decoder) fold(key []byte) []byte {
+	upper := false
+	for _, c := range key {
+		if c >= utf8.RuneSelf {
+			return []byte(strings.ToLower(strings.ToUpper(string(key))))
+		}
+		upper = upper || 'A' <= c && c <= 'Z'
+	}
+	if !upper {
+		return key
+	}
+	d.folded = append(d.folded[:0], key...)
+	for i, c := range d.folded {
+		if 'A' <= c && c <= 'Z' {
+			d.folded[i] = c + 'a' - 'A'
+		}
+	}
+	return d.folded
+}
+
+// array calls elem for every element of an array, which must consume it.
+func (d *decoder) array(elem func() error) error {
+	if null, err := d.null(); null || err != nil {
+		return err
+	}
+	if d.peek() != '[' {
+		return d.unexpected("array")
+	}
+	d.pos++
+	if d.peek() == ']' {
+		d.pos++
+		return nil
+	}
+	for {
+		if err := elem(); err != nil {
+			return err
+		}
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case ']':
+			d.pos++
+			return nil
+		default:
+			return d.unexpected("',' or ']'")
+		}
+	}
+}
+
+func (d *decoder) unexpected(want string) error {
+	if d.pos >= len(d.data) {
+		return d.errorf("unexpected end of input, expected %s", want)
+	}
+	return d.errorf("invalid character %q, expected %s", d.data[d.pos], want)
+}
+
+func (d *decoder) str(s *string) error {
+	if null, err := d.null(); null || err != nil {
+		return err
+	}
+	if d.peek() != '"' {
+		return d.unexpected("string")
+	}
+	b, err := d.rawString()
+	if err != nil {
+		return err
+	}
+	*s = d.intern(b)
+	return nil
+}
+
+// maxInterned is the length up to which strings are interned.
+const maxInterned = 64
+
+func (d *decoder) intern(b []byte) string {
+	if len(b) > maxInterned {
+		return string(b)
+	}
+	if s, ok := d.interned[string(b)]; ok {
+		return s
+	}
+	if d.interned == nil {
+		d.interned = map[string]string{}
+	}
+	s := string(b)
+	d.interned[s] = s
+	return s
+}
+
+// rawString reads a string and returns its unescaped bytes, which alias the
+// input when there was nothing to unescape.
+func (d *decoder) rawString() ([]byte, error) {
+	d.pos++ // opening quote
+	start := d.pos
+	ascii := true
+	for d.pos < len(d.data) {
+		c := d.data[d.pos]
+		switch {
+		case c == '"':
+			s := d.data[start:d.pos]
+			d.pos++
+			if !ascii && !utf8.Valid(s) {
+				return unescape(s), nil
+			}
+			return s, nil
+		case c >= utf8.RuneSelf:
+			ascii = false
+		case c == '\\':
+			return d.escapedString(start)
+		case c < 0x20:
+			return nil, d.errorf("invalid control character in string")
+		}
+		d.pos++
+	}
+	return nil, d.unexpected("closing quote")
+}
+
+// escapedString finishes a string starting at start that contains escapes.
+func (d *decoder) escapedString(start int) ([]byte, error) {
+	for d.pos < len(d.data) {
+		switch c := d.data[d.pos]; {
+		case c == '"':
+			s := d.data[start:d.pos]
+			d.pos++
+			return unescape(s), nil
+		case c == '\\':
+			if d.pos+1 >= len(d.data) {
+				return nil, d.unexpected("escape")
+			}
+			switch d.data[d.pos+1] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				d.pos += 2
+			case 'u':
+				if d.pos+6 > len(d.data) {
+					return nil, d.unexpected("unicode escape")
+				}
+				if _, err := strconv.ParseUint(string(d.data[d.pos+2:d.pos+6]), 16, 16); err != nil {
+					return nil, d.errorf("invalid unicode escape")
+				}
+				d.pos += 6
+			default:
+				return nil, d.errorf("invalid escape %q", d.data[d.pos+1])
+			}
+		case c < 0x20:
+			return nil, d.errorf("invalid control character in string")
+		default:
+			d.pos++
+		}
+	}
+	return nil, d.unexpected("closing quote")
+}
+
+// unescape decodes the escapes of a validated string and replaces invalid
+// UTF-8 the way encoding/json does.
+func unescape(s []byte) []byte {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c != '\\' {
+			if c < utf8.RuneSelf {
+				b = append(b, c)
+				i++
+				continue
+			}
+			r, size := utf8.DecodeRune(s[i:])
+			b = appendRune(b, r)
+			i += size
+			continue
+		}
+		switch s[i+1] {
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'u':
+			r := hexRune(s[i+2 : i+6])
+			i += 6
+			if utf16.IsSurrogate(r) {
+				if i+6 <= len(s) && s[i] == '\\' && s[i+1] == 'u' {
+					if pair := utf16.DecodeRune(r, hexRune(s[i+2:i+6])); pair != utf8.RuneError {
+						b = appendRune(b, pair)
+						i += 6
+						continue
+					}
+				}
+				r = utf8.RuneError
+			}
+			b = appendRune(b, r)
+			continue
+		default:
+			b = append(b, s[i+1])
+		}
+		i += 2
+	}
+	return b
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...)
+}
+
+func hexRune(h []byte) rune {
+	n, _ := strconv.ParseUint(string(h), 16, 16)
+	return rune(n)
+}
+
+// number returns the literal of a number, which must follow the JSON
+// grammar.
+func (d *decoder) number() ([]byte, error) {
+	if c := d.peek(); c != '-' && (c < '0' || c > '9') {
+		return nil, d.unexpected("number")
+	}
+	start := d.pos
+	digits := func() bool {
+		n := d.pos
+		for d.pos < len(d.data) && d.data[d.pos] >= '0' && d.data[d.pos] <= '9' {
+			d.pos++
+		}
+		return d.pos > n
+	}
+	next := func(chars string) bool {
+		if d.pos < len(d.data) && strings.IndexByte(chars, d.data[d.pos]) >= 0 {
+			d.pos++
+			return true
+		}
+		return false
+	}
+	next("-")
+	if next("0") {
+		if d.pos < len(d.data) && d.data[d.pos] >= '0' && d.data[d.pos] <= '9' {
+			return nil, d.errorf("invalid number, leading zero")
+		}
+	} else if !digits() {
+		return nil, d.unexpected("digit")
+	}
+	if next(".") && !digits() {
+		return nil, d.unexpected("digit")
+	}
+	if next("eE") {
+		next("+-")
+		if !digits() {
+			return nil, d.unexpected("digit")
+		}
+	}
+	return d.data[start:d.pos], nil
+}
+
+func (d *decoder) int(n *int) error {
+	if null, err := d.null(); null || err != nil {
+		return err
+	}
+	lit, err := d.number()
+	if err != nil {
+		return err
+	}
+	v, err := strconv.Atoi(string(lit))
+	if err != nil {
+		return d.errorf("cannot use number %s as int", lit)
+	}
+	*n = v
+	return nil
+}
+
+func (d *decoder) float(f *float64) error {
+	if null, err := d.null(); null || err != nil {
+		return err
+	}
+	lit, err := d.number()
+	if err != nil {
+		return err
+	}
+	v, err := strconv.ParseFloat(string(lit), 64)
+	if err != nil {
+		return d.errorf("invalid number %s", lit)
+	}
+	*f = v
+	return nil
+}
+
+func (d *decoder) boolean(b *bool) error {
+	switch d.peek() {
+	case 'n':
+		return d.literal("null")
+	case 't':
+		*b = true
+		return d.literal("true")
+	case 'f':
+		*b = false
+		return d.literal("false")
+	}
+	return d.unexpected("boolean")
+}
+
+// raw copies the next value verbatim, keeping null as encoding/json does.
+func (d *decoder) raw(m *json.RawMessage) error {
+	d.ws()
+	start := d.pos
+	if err := d.skip(); err != nil {
+		return err
+	}
+	*m = append((*m)[:0], d.data[start:d.pos]...)
+	return nil
+}
+
+// skip consumes the next value of any type.
+func (d *decoder) skip() error {
+	switch c := d.peek(); {
+	case c == '{':
+		return d.object(func([]byte) error { return d.skip() })
+	case c == '[':
+		return d.array(d.skip)
+	case c == '"':
+		_, err := d.rawString()
+		return err
+	case c == 't':
+		return d.literal("true")
+	case c == 'f':
+		return d.literal("false")
+	case c == 'n':
+		return d.literal("null")
+	case c == '-' || (c >= '0' && c <= '9'):
+		_, err := d.number()
+		return err
+	}
+	return d.unexpected("value")
+}
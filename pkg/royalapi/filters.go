@@ -0,0 +1,40 @@
+package royalapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateFilters checks that a cruiseSearch filters string is a | separated
+// list of key:value clauses, e.g. "ship:WN|departurePort:MIA".
+func ValidateFilters(filters string) error {
+	if filters == "" {
+		return nil
+	}
+	for _, clause := range strings.Split(filters, "|") {
+		i := strings.Index(clause, ":")
+		if i <= 0 || i == len(clause)-1 {
+			return fmt.Errorf("filter clause %q is not of the form key:value", clause)
+		}
+		if strings.ContainsAny(clause[:i], " ,") {
+			return fmt.Errorf("filter key %q must not contain spaces or commas", clause[:i])
+		}
+	}
+	return nil
+}
+
+// Filter keys narrowing a search to some itinerary codes or product ids,
+// several values separated by commas.
+const (
+	ItineraryFilter = "itinerary"
+	ProductFilter   = "id"
+)
+
+// AddFilter appends the key:value clause to filters.
+func AddFilter(filters, key string, values ...string) string {
+	clause := key + ":" + strings.Join(values, ",")
+	if filters == "" {
+		return clause
+	}
+	return filters + "|" + clause
+}
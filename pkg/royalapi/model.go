@@ -0,0 +1,198 @@
+package royalapi
+
+import "encoding/json"
+
+// Response is the body returned by the cruiseSearch_Cruises operation.
+type Response struct {
+	Data struct {
+		CruiseSearch CruiseSearch `json:"cruiseSearch"`
+	} `json:"data"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message    string `json:"message"`
+	Extensions struct {
+		Code string `json:"code"`
+	} `json:"extensions"`
+}
+
+type CruiseSearch struct {
+	Results  Results `json:"results"`
+	Typename string  `json:"__typename"`
+}
+
+type Results struct {
+	Cruises                []Cruise `json:"cruises"`
+	CruiseRecommendationID string   `json:"cruiseRecommendationId"`
+	Total                  int      `json:"total"`
+	Typename               string   `json:"__typename"`
+}
+
+type Cruise struct {
+	ID                 string             `json:"id"`
+	ProductViewLink    string             `json:"productViewLink"`
+	LowestPriceSailing LowestPriceSailing `json:"lowestPriceSailing"`
+	MasterSailing      MasterSailing      `json:"masterSailing"`
+	Sailings           []Sailing          `json:"sailings"`
+	Typename           string             `json:"__typename"`
+}
+
+type LowestPriceSailing struct {
+	BookingLink               string              `json:"bookingLink"`
+	ID                        string              `json:"id"`
+	LowestStateroomClassPrice StateroomClassPrice `json:"lowestStateroomClassPrice"`
+	SailDate                  string              `json:"sailDate"`
+	StartDate                 string              `json:"startDate"`
+	EndDate                   string              `json:"endDate"`
+	TaxesAndFees              TaxesAndFees        `json:"taxesAndFees"`
+	TaxesAndFeesIncluded      bool                `json:"taxesAndFeesIncluded"`
+	Typename                  string              `json:"__typename"`
+}
+
+type TaxesAndFees struct {
+	Value    float64 `json:"value"`
+	Typename string  `json:"__typename"`
+}
+
+type MasterSailing struct {
+	Itinerary Itinerary `json:"itinerary"`
+	Typename  string    `json:"__typename"`
+}
+
+type Itinerary struct {
+	Code          string          `json:"code"`
+	Media         Media           `json:"media"`
+	Days          []Day           `json:"days"`
+	DeparturePort DeparturePort   `json:"departurePort"`
+	Destination   Destination     `json:"destination"`
+	Name          string          `json:"name"`
+	PostTour      json.RawMessage `json:"postTour"`
+	PreTour       json.RawMessage `json:"preTour"`
+	SailingNights int             `json:"sailingNights"`
+	Ship          Ship            `json:"ship"`
+	TotalNights   int             `json:"totalNights"`
+	Type          string          `json:"type"`
+	Typename      string          `json:"__typename"`
+}
+
+type Day struct {
+	Number   int        `json:"number"`
+	Type     string     `json:"type"`
+	Ports    []PortCall `json:"ports"`
+	Typename string     `json:"__typename"`
+}
+
+type PortCall struct {
+	Activity      string `json:"activity"`
+	ArrivalTime   string `json:"arrivalTime"`
+	DepartureTime string `json:"departureTime"`
+	Port          Port   `json:"port"`
+	Typename      string `json:"__typename"`
+}
+
+type Port struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Region   string `json:"region"`
+	Media    Media  `json:"media"`
+	Typename string `json:"__typename"`
+}
+
+type DeparturePort struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Region   string `json:"region"`
+	Typename string `json:"__typename"`
+}
+
+type Destination struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Typename string `json:"__typename"`
+}
+
+type Ship struct {
+	Code             string           `json:"code"`
+	Name             string           `json:"name"`
+	StateroomClasses []StateroomClass `json:"stateroomClasses"`
+	Media            Media            `json:"media"`
+	Typename         string           `json:"__typename"`
+}
+
+type StateroomClass struct {
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	Content  StateroomContent `json:"content"`
+	Typename string           `json:"__typename"`
+}
+
+type StateroomContent struct {
+	Amenities     []string        `json:"amenities"`
+	Area          json.RawMessage `json:"area"`
+	Code          string          `json:"code"`
+	MaxCapacity   string          `json:"maxCapacity"`
+	Media         StateroomMedia  `json:"media"`
+	SuperCategory string          `json:"superCategory"`
+	Typename      string          `json:"__typename"`
+}
+
+type Media struct {
+	Images   []Image `json:"images"`
+	Typename string  `json:"__typename"`
+}
+
+type Image struct {
+	Path     string `json:"path"`
+	Typename string `json:"__typename"`
+}
+
+type StateroomMedia struct {
+	Images   []StateroomImage `json:"images"`
+	Typename string           `json:"__typename"`
+}
+
+type StateroomImage struct {
+	Path     string    `json:"path"`
+	Meta     ImageMeta `json:"meta"`
+	Typename string    `json:"__typename"`
+}
+
+type ImageMeta struct {
+	Description string `json:"description"`
+	Title       string `json:"title"`
+	Location    string `json:"location"`
+	Typename    string `json:"__typename"`
+}
+
+type Sailing struct {
+	BookingLink           string                `json:"bookingLink"`
+	ID                    string                `json:"id"`
+	Itinerary             ItineraryRef          `json:"itinerary"`
+	SailDate              string                `json:"sailDate"`
+	StartDate             string                `json:"startDate"`
+	EndDate               string                `json:"endDate"`
+	StateroomClassPricing []StateroomClassPrice `json:"stateroomClassPricing"`
+	Typename              string                `json:"__typename"`
+}
+
+type ItineraryRef struct {
+	Code     string `json:"code"`
+	Typename string `json:"__typename"`
+}
+
+type StateroomClassPrice struct {
+	Price          Price             `json:"price"`
+	StateroomClass StateroomClassRef `json:"stateroomClass"`
+	Typename       string            `json:"__typename"`
+}
+
+type Price struct {
+	Value    int    `json:"value"`
+	Typename string `json:"__typename"`
+}
+
+type StateroomClassRef struct {
+	ID       string `json:"id"`
+	Typename string `json:"__typename"`
+}
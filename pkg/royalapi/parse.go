@@ -0,0 +1,62 @@
+package royalapi
+
+// Parse decodes a cruiseSearch_Cruises response body. It gives the same
+// result as encoding/json for the model at a fraction of the CPU time.
+func Parse(body []byte) (*Response, error) {
+	resp := &Response{}
+	d := &decoder{data: body}
+	if err := resp.decode(d); err != nil {
+		return nil, err
+	}
+	if err := d.end(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Cruises returns the cruises of the page.
+func (r *Response) Cruises() []Cruise {
+	return r.Data.CruiseSearch.Results.Cruises
+}
+
+// Total returns the size of the whole result set, not just this page.
+func (r *Response) Total() int {
+	return r.Data.CruiseSearch.Results.Total
+}
+
+// PersistedQueryNotFound reports whether the server didn't know the hash of a
+// persisted query and expects the full document.
+func (r *Response) PersistedQueryNotFound() bool {
+	for _, e := range r.Errors {
+		if e.Message == "PersistedQueryNotFound" || e.Extensions.Code == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+	return false
+}
+
+// MinSailingPrice returns the lowest positive stateroom class price over all
+// sailings of the cruise, or 0 when none is priced.
+func (c Cruise) MinSailingPrice() int {
+	min := 0
+	for _, s := range c.Sailings {
+		for _, p := range s.StateroomClassPricing {
+			if p.Price.Value > 0 && (min == 0 || p.Price.Value < min) {
+				min = p.Price.Value
+			}
+		}
+	}
+	return min
+}
+
+// PortCodes returns the codes of the ports of call day by day. It is empty
+// unless the query requested the ports feature.
+func (it Itinerary) PortCodes() []string {
+	var codes []string
+	for _, d := range it.Days {
+		for _, p := range d.Ports {
+			codes = append(codes, p.Port.Code)
+		}
+	}
+	return codes
+}
@@ -0,0 +1,139 @@
+package royalapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertParsesLikeStdlib checks that Parse fails exactly when encoding/json
+// does and otherwise decodes the same model.
+func assertParsesLikeStdlib(t *testing.T, body []byte) {
+	t.Helper()
+	var want Response
+	wantErr := json.Unmarshal(body, &want)
+	got, err := Parse(body)
+	if wantErr != nil {
+		assert.Error(t, err, "encoding/json failed with %v", wantErr)
+		return
+	}
+	require.NoError(t, err)
+	assert.Equal(t, &want, got)
+}
+
+func TestParseFixturesLikeStdlib(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			body, err := os.ReadFile(file)
+			require.NoError(t, err)
+			assertParsesLikeStdlib(t, body)
+		})
+	}
+}
+
+func TestParseEdgeCasesLikeStdlib(t *testing.T) {
+	results := func(fields string) string {
+		return `{"data":{"cruiseSearch":{"results":{` + fields + `}}}}`
+	}
+	for name, body := range map[string]string{
+		"empty object":         `{}`,
+		"null":                 `null`,
+		"upper case key":       results(`"Total":5`),
+		"mixed case keys":      `{"DATA":{"CruiseSearch":{"RESULTS":{"tOtAl":7}}}}`,
+		"duplicate keys":       results(`"total":1,"Total":2`),
+		"kelvin sign key":      `{"data":{"cruiseSearch":{"results":{"cruises":[{"productViewLinK":"x"}]}}}}`,
+		"long s key":           `{"data":{"cruiseSearch":{"results":{"cruises":[{"ſailingS":[]}]}}}}`,
+		"negative int":         results(`"total":-3`),
+		"negative zero":        results(`"total":-0`),
+		"leading zero":         results(`"total":01`),
+		"float for int":        results(`"total":1.0`),
+		"exponent for int":     results(`"total":1e3`),
+		"int overflow":         results(`"total":99999999999999999999`),
+		"plus sign":            results(`"total":+1`),
+		"lone minus":           results(`"total":-`),
+		"trailing dot":         results(`"total":1.`),
+		"string for int":       results(`"total":"5"`),
+		"null for int":         results(`"total":null`),
+		"exponent float":       `{"data":{"cruiseSearch":{"results":{"cruises":[{"lowestPriceSailing":{"taxesAndFees":{"value":1.5E+2}}}]}}}}`,
+		"bad exponent":         `{"data":{"cruiseSearch":{"results":{"cruises":[{"lowestPriceSailing":{"taxesAndFees":{"value":1e}}}]}}}}`,
+		"bool for string":      results(`"__typename":true`),
+		"escapes":              results(`"__typename":"a\"b\\c\/d\b\f\n\r\té🌴"`),
+		"lone surrogate":       results(`"__typename":"\ud800x"`),
+		"invalid utf8":         results("\"__typename\":\"a\xffb\""),
+		"invalid escape":       results(`"__typename":"\x"`),
+		"control character":    results("\"__typename\":\"a\x01b\""),
+		"unknown fields":       results(`"extra":{"a":[1,2.5,"x",null,true,false,{}]},"total":3`),
+		"null arrays":          results(`"cruises":null`),
+		"empty arrays":         results(`"cruises":[{"sailings":[],"masterSailing":{"itinerary":{"days":[]}}}]`),
+		"null elements":        results(`"cruises":[null,{"id":"x"}]`),
+		"raw messages":         `{"data":{"cruiseSearch":{"results":{"cruises":[{"masterSailing":{"itinerary":{"postTour":  {"a": [1, 2]} ,"preTour":null}}}]}}}}`,
+		"errors":               `{"errors":[{"message":"boom","extensions":{"code":"INTERNAL"}}]}`,
+		"empty errors":         `{"errors":[]}`,
+		"whitespace":           " \n\t{ \"data\" : { } } \r\n",
+		"trailing garbage":     `{}x`,
+		"two values":           `{} {}`,
+		"truncated":            results(`"cruises":[{"id":"x"`)[:40],
+		"missing colon":        `{"data" {}}`,
+		"trailing comma":       results(`"total":1,`),
+		"object for array":     results(`"cruises":{}`),
+		"array for object":     `{"data":[]}`,
+		"unquoted key":         `{data:{}}`,
+		"single quoted string": results(`"__typename":'x'`),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assertParsesLikeStdlib(t, []byte(body))
+		})
+	}
+}
+
+// largePage returns the fixture with its cruises repeated to a full page of
+// n cruises.
+func largePage(b *testing.B, n int) []byte {
+	body, err := os.ReadFile("testdata/cruise_search.json")
+	require.NoError(b, err)
+	var page map[string]interface{}
+	require.NoError(b, json.Unmarshal(body, &page))
+	search := page["data"].(map[string]interface{})["cruiseSearch"].(map[string]interface{})
+	results := search["results"].(map[string]interface{})
+	cruises := results["cruises"].([]interface{})
+	for len(cruises) < n {
+		cruises = append(cruises, cruises[len(cruises)%2])
+	}
+	results["cruises"] = cruises
+	var buf bytes.Buffer
+	require.NoError(b, json.NewEncoder(&buf).Encode(page))
+	return buf.Bytes()
+}
+
+func BenchmarkParse(b *testing.B) {
+	body := largePage(b, 100)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseStdlib(b *testing.B) {
+	body := largePage(b, 100)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp Response
+		if err := json.Unmarshal(body, &resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package royalapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const OperationName = "cruiseSearch_Cruises"
+
+// Features selects the optional parts of the cruise search query. Fields the
+// exporter needs for its metrics are always requested.
+type Features struct {
+	// Media requests image paths for itineraries, ports, ships and staterooms.
+	Media bool
+	// Ports requests the day by day itinerary with its ports of call.
+	Ports bool
+	// Tours requests the pre and post cruise land tours.
+	Tours bool
+	// Staterooms requests the stateroom class descriptions of each ship.
+	Staterooms bool
+	// LowestPrice requests the advertised lowest price sailing per cruise.
+	LowestPrice bool
+}
+
+var featureNames = map[string]func(*Features){
+	"media":        func(f *Features) { f.Media = true },
+	"ports":        func(f *Features) { f.Ports = true },
+	"tours":        func(f *Features) { f.Tours = true },
+	"staterooms":   func(f *Features) { f.Staterooms = true },
+	"lowest-price": func(f *Features) { f.LowestPrice = true },
+}
+
+// AllFeatures requests everything, matching the query the website sends.
+func AllFeatures() Features {
+	return Features{Media: true, Ports: true, Tours: true, Staterooms: true, LowestPrice: true}
+}
+
+// ParseFeatures parses a comma separated list of feature names such as
+// "ports,lowest-price". "all" enables every feature and "" none.
+func ParseFeatures(s string) (Features, error) {
+	var f Features
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case "all":
+			f = AllFeatures()
+			continue
+		}
+		enable, ok := featureNames[name]
+		if !ok {
+			names := make([]string, 0, len(featureNames))
+			for n := range featureNames {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return f, fmt.Errorf("unknown query feature %q, expected one of %s", name, strings.Join(names, ", "))
+		}
+		enable(&f)
+	}
+	return f, nil
+}
+
+// field is a GraphQL selection. Object selections get __typename appended,
+// as the website's Apollo client does.
+type field struct {
+	name     string
+	children []field
+}
+
+func leaf(names ...string) []field {
+	fields := make([]field, len(names))
+	for i, n := range names {
+		fields[i] = field{name: n}
+	}
+	return fields
+}
+
+func obj(name string, children ...[]field) field {
+	f := field{name: name}
+	for _, c := range children {
+		f.children = append(f.children, c...)
+	}
+	return f
+}
+
+func one(f field) []field {
+	return []field{f}
+}
+
+func when(cond bool, fields ...field) []field {
+	if !cond {
+		return nil
+	}
+	return fields
+}
+
+func (f field) write(b *strings.Builder) {
+	b.WriteString(f.name)
+	if len(f.children) == 0 {
+		return
+	}
+	b.WriteString(" {")
+	for _, c := range f.children {
+		b.WriteByte(' ')
+		c.write(b)
+	}
+	b.WriteString(" __typename }")
+}
+
+func (f field) paths(prefix string, into map[string]struct{}) {
+	path := prefix + "." + f.name
+	into[path] = struct{}{}
+	into[path+".__typename"] = struct{}{}
+	for _, c := range f.children {
+		c.paths(path, into)
+	}
+}
+
+// Query is a cruiseSearch_Cruises query assembled for a set of features.
+type Query struct {
+	results field
+	paths   map[string]struct{}
+}
+
+func media() field {
+	return obj("media", one(obj("images", leaf("path"))))
+}
+
+func days(withMedia bool) field {
+	port := obj("port", leaf("code", "name", "region"), when(withMedia, media()))
+	return obj("days", leaf("number", "type"), one(obj("ports", leaf("activity", "arrivalTime", "departureTime"), one(port))))
+}
+
+// NewQuery builds the query for the enabled features.
+func NewQuery(f Features) Query {
+	price := []field{
+		obj("price", leaf("value")),
+		obj("stateroomClass", leaf("id")),
+	}
+	tour := func(name string) field {
+		return obj(name, one(days(false)), leaf("duration"))
+	}
+	stateroomClasses := obj("stateroomClasses", leaf("id", "name"), one(obj("content",
+		leaf("amenities", "area", "code", "maxCapacity"),
+		when(f.Media, obj("media", one(obj("images", leaf("path"), one(obj("meta", leaf("description", "title", "location"))))))),
+		leaf("superCategory"),
+	)))
+
+	itinerary := obj("itinerary",
+		leaf("code"),
+		when(f.Media, media()),
+		when(f.Ports, days(f.Media)),
+		one(obj("departurePort", leaf("code", "name", "region"))),
+		one(obj("destination", leaf("code", "name"))),
+		leaf("name"),
+		when(f.Tours, tour("postTour"), tour("preTour")),
+		leaf("sailingNights"),
+		one(obj("ship", leaf("code", "name"), when(f.Staterooms, stateroomClasses), when(f.Media, media()))),
+		leaf("totalNights", "type"),
+	)
+
+	cruises := obj("cruises",
+		leaf("id", "productViewLink"),
+		when(f.LowestPrice, obj("lowestPriceSailing",
+			leaf("bookingLink", "id"),
+			one(obj("lowestStateroomClassPrice", price)),
+			leaf("sailDate", "startDate", "endDate"),
+			one(obj("taxesAndFees", leaf("value"))),
+			leaf("taxesAndFeesIncluded"),
+		)),
+		one(obj("masterSailing", one(itinerary))),
+		one(obj("sailings",
+			leaf("bookingLink", "id"),
+			one(obj("itinerary", leaf("code"))),
+			leaf("sailDate", "startDate", "endDate"),
+			one(obj("stateroomClassPricing", price)),
+		)),
+	)
+
+	q := Query{
+		results: obj("results", one(cruises), leaf("cruiseRecommendationId", "total")),
+		paths:   map[string]struct{}{},
+	}
+	q.results.paths(".data.cruiseSearch", q.paths)
+	return q
+}
+
+// String renders the query document.
+func (q Query) String() string {
+	var b strings.Builder
+	b.WriteString("query cruiseSearch_Cruises($filters: String, $qualifiers: String, $sort: CruiseSearchSort, $pagination: CruiseSearchPagination) { ")
+	b.WriteString("cruiseSearch( filters: $filters qualifiers: $qualifiers sort: $sort pagination: $pagination ) { ")
+	q.results.write(&b)
+	b.WriteString(" __typename } }")
+	return b.String()
+}
+
+// Requests reports whether the query selects the response path, written as
+// in SchemaDiff with array elements marked by [].
+func (q Query) Requests(path string) bool {
+	path = strings.Replace(path, "[]", "", -1)
+	if !strings.HasPrefix(path, ".data.cruiseSearch.results") {
+		return true
+	}
+	_, ok := q.paths[path]
+	return ok
+}
@@ -0,0 +1,85 @@
+package royalapi
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the query golden files")
+
+// featureCombinations returns every combination of features by the name of
+// its golden file.
+func featureCombinations() map[string]Features {
+	names := []string{"media", "ports", "tours", "staterooms", "lowest-price"}
+	combinations := map[string]Features{}
+	for mask := 0; mask < 1<<len(names); mask++ {
+		var f Features
+		var enabled []string
+		for i, name := range names {
+			if mask&(1<<i) != 0 {
+				featureNames[name](&f)
+				enabled = append(enabled, name)
+			}
+		}
+		name := "none"
+		if len(enabled) > 0 {
+			name = strings.Join(enabled, "+")
+		}
+		combinations["query_"+name+".golden"] = f
+	}
+	return combinations
+}
+
+func TestNewQueryGolden(t *testing.T) {
+	for name, f := range featureCombinations() {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("testdata", name)
+			got := NewQuery(f).String()
+			if *update {
+				require.NoError(t, os.WriteFile(path, []byte(got), 0644))
+			}
+			want, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), got)
+		})
+	}
+}
+
+// TestAllFeaturesMatchesWebsite pins the full query to the one the website
+// sends, which -update never rewrites.
+func TestAllFeaturesMatchesWebsite(t *testing.T) {
+	want, err := os.ReadFile("testdata/website_query.golden")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), NewQuery(AllFeatures()).String())
+}
+
+func TestParseFeatures(t *testing.T) {
+	f, err := ParseFeatures("ports, lowest-price")
+	require.NoError(t, err)
+	assert.Equal(t, Features{Ports: true, LowestPrice: true}, f)
+
+	f, err = ParseFeatures("all")
+	require.NoError(t, err)
+	assert.Equal(t, AllFeatures(), f)
+
+	f, err = ParseFeatures("")
+	require.NoError(t, err)
+	assert.Equal(t, Features{}, f)
+
+	_, err = ParseFeatures("ports,itinerary")
+	assert.EqualError(t, err, `unknown query feature "itinerary", expected one of lowest-price, media, ports, staterooms, tours`)
+}
+
+func TestQueryRequests(t *testing.T) {
+	q := NewQuery(Features{Ports: true})
+	assert.True(t, q.Requests(".data.cruiseSearch.results.cruises[].masterSailing.itinerary.days[].ports[].port.code"))
+	assert.False(t, q.Requests(".data.cruiseSearch.results.cruises[].masterSailing.itinerary.days[].ports[].port.media"))
+	assert.False(t, q.Requests(".data.cruiseSearch.results.cruises[].lowestPriceSailing"))
+	assert.True(t, q.Requests(".errors[].message"), "paths outside the results are always expected")
+}
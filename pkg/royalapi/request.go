@@ -0,0 +1,90 @@
+package royalapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Request is the POST body of a cruiseSearch_Cruises call.
+type Request struct {
+	OperationName string      `json:"operationName"`
+	Variables     Variables   `json:"variables"`
+	Query         string      `json:"query,omitempty"`
+	Extensions    *Extensions `json:"extensions,omitempty"`
+}
+
+type Variables struct {
+	Filters    string     `json:"filters,omitempty"`
+	Sort       Sort       `json:"sort"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type Sort struct {
+	By string `json:"by"`
+}
+
+// The orders search results can be sorted by. RECOMMENDED, the order of the
+// website, reshuffles between requests so a paginated search may miss or
+// repeat cruises, DATE and PRICE are stable.
+const (
+	SortRecommended = "RECOMMENDED"
+	SortDate        = "DATE"
+	SortPrice       = "PRICE"
+)
+
+// Sorts lists the supported sort orders.
+var Sorts = []string{SortRecommended, SortDate, SortPrice}
+
+// ValidateSort checks by is one of Sorts.
+func ValidateSort(by string) error {
+	for _, s := range Sorts {
+		if by == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown sort %q, expected one of %s", by, strings.Join(Sorts, ", "))
+}
+
+type Pagination struct {
+	Count int `json:"count"`
+	Skip  int `json:"skip"`
+}
+
+type Extensions struct {
+	PersistedQuery PersistedQuery `json:"persistedQuery"`
+}
+
+// PersistedQuery identifies a query by hash, as in Apollo's automatic
+// persisted queries.
+type PersistedQuery struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+// Hash returns the hex encoded SHA-256 of the query document.
+func (q Query) Hash() string {
+	sum := sha256.Sum256([]byte(q.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Request returns a request carrying the full query document.
+func (q Query) Request(v Variables) Request {
+	return Request{OperationName: OperationName, Variables: v, Query: q.String()}
+}
+
+// PersistedRequest returns a request identifying the query by its hash only.
+// After a PersistedQueryNotFound response, includeQuery sends the document
+// along so the server can register it.
+func (q Query) PersistedRequest(v Variables, includeQuery bool) Request {
+	r := Request{
+		OperationName: OperationName,
+		Variables:     v,
+		Extensions:    &Extensions{PersistedQuery: PersistedQuery{Version: 1, Sha256Hash: q.Hash()}},
+	}
+	if includeQuery {
+		r.Query = q.String()
+	}
+	return r
+}
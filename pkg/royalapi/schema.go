@@ -0,0 +1,148 @@
+package royalapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// SchemaDiff lists the differences between a response body and the model.
+type SchemaDiff struct {
+	// Missing are keys the model expects but the response lacks.
+	Missing []string
+	// Unexpected are keys in the response the model doesn't know about.
+	Unexpected []string
+	// Empty are fields the exporter relies on that are present but blank.
+	Empty []string
+}
+
+func (d SchemaDiff) Len() int {
+	return len(d.Missing) + len(d.Unexpected) + len(d.Empty)
+}
+
+func (d SchemaDiff) String() string {
+	return "missing=[" + strings.Join(d.Missing, " ") +
+		"] unexpected=[" + strings.Join(d.Unexpected, " ") +
+		"] empty=[" + strings.Join(d.Empty, " ") + "]"
+}
+
+// CheckSchema compares the raw body against the Response model and the
+// already decoded resp against the fields the exporter relies on. Keys the
+// query q didn't select are not reported as missing.
+func CheckSchema(body []byte, resp *Response, q Query) (SchemaDiff, error) {
+	var raw interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return SchemaDiff{}, err
+	}
+
+	missing := map[string]struct{}{}
+	unexpected := map[string]struct{}{}
+	diffKeys(reflect.TypeOf(*resp), raw, "", missing, unexpected)
+	for path := range missing {
+		if !q.Requests(path) {
+			delete(missing, path)
+		}
+	}
+
+	return SchemaDiff{
+		Missing:    sortedKeys(missing),
+		Unexpected: sortedKeys(unexpected),
+		Empty:      emptyFields(resp),
+	}, nil
+}
+
+// diffKeys walks the decoded JSON value alongside the struct type t and
+// records keys the model expects but the response lacks, and vice versa.
+func diffKeys(t reflect.Type, v interface{}, path string, missing, unexpected map[string]struct{}) {
+	if v == nil || t == rawMessageType {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		known := make(map[string]struct{}, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := strings.Split(f.Tag.Get("json"), ",")
+			name := tag[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			known[name] = struct{}{}
+			val, present := obj[name]
+			if !present {
+				// omitempty marks keys the response only carries sometimes
+				if len(tag) > 1 && tag[1] == "omitempty" {
+					continue
+				}
+				missing[path+"."+name] = struct{}{}
+				continue
+			}
+			diffKeys(f.Type, val, path+"."+name, missing, unexpected)
+		}
+		for key := range obj {
+			if _, ok := known[key]; !ok {
+				unexpected[path+"."+key] = struct{}{}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return
+		}
+		for _, elem := range arr {
+			diffKeys(t.Elem(), elem, path+"[]", missing, unexpected)
+		}
+	}
+}
+
+// emptyFields reports the fields used as metric labels or values that are
+// present but carry no data.
+func emptyFields(resp *Response) []string {
+	empty := map[string]struct{}{}
+	check := func(path string, isEmpty bool) {
+		if isEmpty {
+			empty[path] = struct{}{}
+		}
+	}
+
+	results := resp.Data.CruiseSearch.Results
+	check(".data.cruiseSearch.results.total", results.Total == 0)
+	for _, c := range results.Cruises {
+		it := c.MasterSailing.Itinerary
+		check(".data.cruiseSearch.results.cruises[].id", c.ID == "")
+		check(".data.cruiseSearch.results.cruises[].sailings", len(c.Sailings) == 0)
+		check(".data.cruiseSearch.results.cruises[].masterSailing.itinerary.ship.name", it.Ship.Name == "")
+		check(".data.cruiseSearch.results.cruises[].masterSailing.itinerary.ship.code", it.Ship.Code == "")
+		check(".data.cruiseSearch.results.cruises[].masterSailing.itinerary.departurePort.name", it.DeparturePort.Name == "")
+		check(".data.cruiseSearch.results.cruises[].masterSailing.itinerary.destination.code", it.Destination.Code == "")
+		check(".data.cruiseSearch.results.cruises[].masterSailing.itinerary.totalNights", it.TotalNights == 0)
+		for _, s := range c.Sailings {
+			check(".data.cruiseSearch.results.cruises[].sailings[].sailDate", s.SailDate == "")
+			check(".data.cruiseSearch.results.cruises[].sailings[].itinerary.code", s.Itinerary.Code == "")
+			for _, p := range s.StateroomClassPricing {
+				check(".data.cruiseSearch.results.cruises[].sailings[].stateroomClassPricing[].stateroomClass.id", p.StateroomClass.ID == "")
+			}
+		}
+	}
+	return sortedKeys(empty)
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,27 @@
+package royalapi
+
+import "strings"
+
+// The stateroom super categories most people compare prices by.
+const (
+	Interior  = "interior"
+	Oceanview = "oceanview"
+	Balcony   = "balcony"
+	Suite     = "suite"
+)
+
+// SuperCategories are the known super categories, cheapest first.
+var SuperCategories = []string{Interior, Oceanview, Balcony, Suite}
+
+var superCategories = map[string]string{
+	"I": Interior, "INTERIOR": Interior, "INSIDE": Interior,
+	"O": Oceanview, "OUTSIDE": Oceanview, "OCEANVIEW": Oceanview,
+	"B": Balcony, "BALCONY": Balcony, "VERANDA": Balcony,
+	"S": Suite, "D": Suite, "DELUXE": Suite, "SUITE": Suite,
+}
+
+// SuperCategory returns the super category of a stateroom class id, empty
+// when it isn't known.
+func SuperCategory(class string) string {
+	return superCategories[strings.ToUpper(class)]
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exporter"
+)
+
+func flagTargets() []config.Target {
+	targets := make([]config.Target, 0, len(urls))
+	for _, u := range urls {
+		targets = append(targets, config.Target{Name: u, URL: u})
+	}
+	return targets
+}
+
+func readSecretFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// watchConfig reloads the config file, the secret files it names and the
+// debug token file whenever they change, which is how Kubernetes delivers
+// ConfigMap and Secret updates to mounted volumes.
+func watchConfig(ctx context.Context, e *exporter.Exporter, cfg *config.Config) {
+	watched := func(cfg *config.Config) []string {
+		var files []string
+		for _, f := range []string{config_file, debug_token_file} {
+			if f != "" {
+				files = append(files, f)
+			}
+		}
+		if config_file != "" {
+			files = append(files, cfg.SecretFiles(config_file)...)
+		}
+		return files
+	}
+	files := watched(cfg)
+	if len(files) == 0 {
+		return
+	}
+	watcher := config.NewWatcher(files...)
+
+	ticker := time.NewTicker(config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !watcher.Changed() {
+				continue
+			}
+			if config_file != "" {
+				// the reload reads the secret files again, and may name others
+				cfg = reloadConfig(e, cfg)
+				watcher = config.NewWatcher(watched(cfg)...)
+			}
+			if debug_token_file != "" {
+				token, err := readSecretFile(debug_token_file)
+				if err != nil {
+					log.Printf("error reloading debug token: %s\n", err)
+					continue
+				}
+				e.SetDebugToken(token)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func reloadConfig(e *exporter.Exporter, old *config.Config) *config.Config {
+	cfg, err := config.Load(config_file)
+	if err != nil {
+		log.Printf("error reloading config, keeping the previous one: %s\n", err)
+		return old
+	}
+	if err := e.SetTargets(append(flagTargets(), cfg.Targets...)); err != nil {
+		log.Printf("error applying reloaded targets: %s\n", err)
+		return old
+	}
+	for _, section := range restartSections(old, cfg) {
+		log.Printf("%s changes, including to its secret files, only take effect after a restart\n", section)
+	}
+	loadedConfig.Store(cfg)
+	log.Printf("reloaded config from %s\n", config_file)
+	e.Audit(exporter.AuditConfigReload, "", map[string]string{"file": config_file})
+	return cfg
+}
+
+// restartSections returns the config file sections that changed between old
+// and cfg which a reload doesn't apply, every one but targets.
+func restartSections(old, cfg *config.Config) []string {
+	var sections []string
+	o, n := reflect.ValueOf(*old), reflect.ValueOf(*cfg)
+	for i := 0; i < n.NumField(); i++ {
+		name := strings.Split(n.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "targets" {
+			continue
+		}
+		if !reflect.DeepEqual(o.Field(i).Interface(), n.Field(i).Interface()) {
+			sections = append(sections, name)
+		}
+	}
+	return sections
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"gopkg.in/yaml.v2"
+)
+
+// ruleGroups is a Prometheus rule file.
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// writeRules prints Prometheus rules equivalent to the watches: a recording
+// rule with the lowest price the watch sees and an alert with the labels and
+// annotations the exporter sends to Alertmanager. It returns the problems of
+// the watches it had to leave out.
+func writeRules(w io.Writer, cfg *config.Config, watches []config.WatchConfig) ([]string, error) {
+	var problems []string
+	if cfg.Rollups != nil && cfg.Rollups.DropRaw {
+		problems = append(problems, "rollups.drop_raw is set, the rules need royal_external_price")
+	}
+	group := ruleGroup{Name: "royalcaribbean-watches"}
+	for _, watch := range watches {
+		if watch.Condition != nil {
+			problems = append(problems, fmt.Sprintf("watch %s: conditions have no PromQL equivalent, left out", watch.Name))
+			continue
+		}
+		selector := watchSelector(watch)
+		labels := map[string]string{"watch": watch.Name}
+		group.Rules = append(group.Rules, rule{
+			Record: "royal:watch_lowest_price:min",
+			Expr:   "min(" + selector + ")",
+			Labels: labels,
+		})
+		alert := rule{
+			Alert:  "RoyalCaribbeanPriceWatch",
+			Expr:   selector,
+			Labels: map[string]string{"watch": watch.Name},
+			Annotations: map[string]string{
+				"summary": `{{ $labels.ship }} sailing {{ $labels.datelabel }} stateroom class {{ $labels.stateroomclass }} at {{ $value | printf "%.0f" }}`,
+				"price":   `{{ $value | printf "%.0f" }}`,
+			},
+		}
+		if watch.Below > 0 {
+			alert.Expr += " < " + strconv.FormatFloat(watch.Below, 'f', -1, 64)
+		}
+		if watch.Priority != "" {
+			alert.Labels["priority"] = watch.Priority
+		}
+		group.Rules = append(group.Rules, alert)
+	}
+	b, err := yaml.Marshal(ruleGroups{Groups: []ruleGroup{group}})
+	if err != nil {
+		return problems, err
+	}
+	_, err = w.Write(b)
+	return problems, err
+}
+
+// watchSelector returns the royal_external_price series the watch matches.
+func watchSelector(w config.WatchConfig) string {
+	match := map[string]string{}
+	for k, v := range w.Match {
+		match[k] = v
+	}
+	if w.Itinerary != "" {
+		match["itinerary"] = w.Itinerary
+	}
+	if w.Product != "" {
+		match["cruiseid"] = w.Product
+	}
+	names := make([]string, 0, len(match))
+	for name := range match {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	matchers := make([]string, len(names))
+	for i, name := range names {
+		matchers[i] = name + "=" + strconv.Quote(match[name])
+	}
+	return "royal_external_price{" + strings.Join(matchers, ",") + "}"
+}
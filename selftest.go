@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exporter"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exportertest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// selftestGolden is the expected output of the selftest metrics, with the
+// fixture server URL replaced by fixtureURL.
+//
+//go:embed selftest.golden
+var selftestGolden string
+
+const fixtureURL = "http://fixture"
+
+// selftestMetrics are the metrics compared, timings and the like vary.
+var selftestMetrics = []string{
+	"royal_external_price",
+	"royal_external_lowest_price_mismatch",
+	"royal_external_region_sailings",
+	"royal_external_region_lowest_price",
+}
+
+var selftestCruises = []exportertest.Cruise{
+	{
+		ID: "WN07RCI-1", Ship: "Wonder of the Seas", ShipCode: "WN", DeparturePort: "Port Canaveral", Destination: "CARIB", Nights: 7,
+		Sailings: []exportertest.Sailing{
+			{ID: "WN07RCI-1-A", Itinerary: "WN07W375", SailDate: "2036-01-12", Prices: map[string]int{"I": 899, "O": 1049, "B": 1299}},
+			{ID: "WN07RCI-1-B", Itinerary: "WN07W375", SailDate: "2036-01-19", Prices: map[string]int{"I": 949, "B": 1349}},
+		},
+	},
+	{
+		ID: "IC03RCI-1", Ship: "Icon of the Seas", ShipCode: "IC", DeparturePort: "Miami", Destination: "BAHAM", Nights: 3, LowestPrice: 499,
+		Sailings: []exportertest.Sailing{
+			{ID: "IC03RCI-1-A", Itinerary: "IC03M001", SailDate: "2036-02-08", Prices: map[string]int{"I": 529, "S": 2999}},
+		},
+	},
+}
+
+// selftest scrapes the bundled fixture server once, without network access,
+// and compares the exported metrics to the golden output. It writes what
+// differs to w and reports whether everything matched.
+func selftest(w io.Writer) (bool, error) {
+	srv := exportertest.NewServer(selftestCruises...)
+	defer srv.Close()
+
+	mux := http.NewServeMux()
+	e, err := exporter.NewExporter(context.Background(),
+		exporter.WithRegistry(prometheus.NewRegistry()),
+		exporter.WithServeMux(mux),
+		exporter.WithTargets(config.Target{Name: "fixture", URL: srv.URL}),
+	)
+	if err != nil {
+		return false, fmt.Errorf("error creating exporter: %w", err)
+	}
+	report := e.ScrapeOnce()
+	for _, t := range report.Targets {
+		if t.Error != "" {
+			return false, fmt.Errorf("error scraping the fixture: %s", t.Error)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		return false, fmt.Errorf("/metrics returned %d", rec.Code)
+	}
+	got := selftestLines(strings.NewReader(strings.ReplaceAll(rec.Body.String(), srv.URL, fixtureURL)))
+	want := selftestLines(strings.NewReader(selftestGolden))
+
+	ok := true
+	wanted := map[string]bool{}
+	for _, line := range want {
+		wanted[line] = true
+	}
+	found := map[string]bool{}
+	for _, line := range got {
+		found[line] = true
+		if !wanted[line] {
+			fmt.Fprintf(w, "unexpected: %s\n", line)
+			ok = false
+		}
+	}
+	for _, line := range want {
+		if !found[line] {
+			fmt.Fprintf(w, "missing:    %s\n", line)
+			ok = false
+		}
+	}
+	fmt.Fprintf(w, "scraped %d pages, %d cruises and %d sailings, compared %d series\n", report.Pages, report.Cruises, report.Sailings, len(want))
+	return ok, nil
+}
+
+// selftestLines returns the sorted samples of the selftest metrics.
+func selftestLines(r io.Reader) []string {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, name := range selftestMetrics {
+			if strings.HasPrefix(line, name+"{") || strings.HasPrefix(line, name+" ") {
+				lines = append(lines, line)
+				break
+			}
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exporter"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/systemd"
+)
+
+// notifySystemd sends state to systemd when -systemd is set.
+func notifySystemd(state string) {
+	if !use_systemd {
+		return
+	}
+	if sent, err := systemd.Notify(state); err != nil {
+		log.Printf("error notifying systemd: %s", err)
+	} else if !sent {
+		log.Printf("-systemd is set but NOTIFY_SOCKET isn't, is the unit Type=notify?")
+	}
+}
+
+// runWatchdog pings the systemd watchdog at half its interval while no scrape
+// hangs, so systemd restarts the exporter when one does.
+func runWatchdog(ctx context.Context, e *exporter.Exporter) {
+	interval := systemd.WatchdogInterval()
+	if !use_systemd || interval == 0 {
+		return
+	}
+	log.Printf("pinging the systemd watchdog every %s", interval/2)
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if e.Hung() {
+				log.Println("not pinging the systemd watchdog, a scrape hangs")
+				continue
+			}
+			notifySystemd(systemd.Watchdog)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/config"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/exporter"
+	"github.com/invertedorigin/royalcaribbean-prometheus-exporter/pkg/royalapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// checkConfig validates the flags and the config file without scraping
+// anything and returns every problem found.
+func checkConfig() []string {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	cfg := &config.Config{}
+	if config_file != "" {
+		var err error
+		if cfg, err = config.Load(config_file); err != nil {
+			var errs config.Errors
+			if errors.As(err, &errs) {
+				for _, err := range errs {
+					report("-config: %s: %s", config_file, err)
+				}
+			} else {
+				report("-config: %s", err)
+			}
+			cfg = nil
+		}
+	}
+	if cfg != nil && len(urls) == 0 && len(cfg.Targets) == 0 && len(cfg.FileSDConfigs) == 0 {
+		report("no targets: pass -url or add targets or file_sd_configs to the config file")
+	}
+	if healthcheck_interval <= 0 {
+		report("-interval must be positive, got %s", healthcheck_interval)
+	}
+	if err := royalapi.ValidateFilters(filters); err != nil {
+		report("-filters: %s", err)
+	}
+	features, err := royalapi.ParseFeatures(query_features)
+	if err != nil {
+		report("-query-features: %s", err)
+	}
+	if debug_token_file != "" {
+		if _, err := readSecretFile(debug_token_file); err != nil {
+			report("-debug-token-file: %s", err)
+		}
+	}
+	if max_series < 0 {
+		report("-max-series must not be negative, got %d", max_series)
+	}
+	if memory_limit != "" {
+		if _, err := parseBytes(memory_limit); err != nil {
+			report("-memory-limit: %s", err)
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	// Building an exporter catches what only shows up once flags and config
+	// are combined, like duplicate target names or colliding labels.
+	opts := append(exporterOptions(cfg, features),
+		exporter.WithRegistry(prometheus.NewRegistry()),
+		exporter.WithServeMux(http.NewServeMux()),
+	)
+	_, err = exporter.NewExporter(context.Background(), opts...)
+	if err != nil {
+		report("%s", err)
+	}
+	return problems
+}